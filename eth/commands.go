@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"tron_load/ethload"
+)
+
+const (
+	defaultSweepOut = "sweep_result.json"
+	sweepTimeout    = 30 * time.Minute
+)
+
+// simulateScenarios are the scenarios the harness runs by default.
+const simulateScenarios = "one-to-one,one-to-many,many-to-one,many-to-many"
+
+// runBootstrap implements `bootstrap`: it deploys the bundled test token to
+// the config's chain (or an in-process simulated chain), mints supply to every
+// sender and writes a TestConfig pointing at the token.
+func runBootstrap(args []string) {
+	opts := ethload.BootstrapOptions{Output: stdout}
+	fs := flag.NewFlagSet("bootstrap", flag.ExitOnError)
+	configPath := fs.String("config", "", "base config; its senders receive the minted tokens")
+	outPath := fs.String("out", "bootstrap.json", "where to write the generated config")
+	fs.StringVar(&opts.DeployerKey, "deployer", "", "hex key that deploys and mints (default: the first sender key)")
+	supply := fs.String("supply", "1000000", "whole tokens minted to each sender")
+	fs.BoolVar(&opts.Simulated, "simulated", false, "run an in-process simulated chain instead of dialing rpcUrl")
+	fs.StringVar(&opts.Listen, "listen", "127.0.0.1:8545", "simulated: HTTP RPC address of the chain")
+	fs.DurationVar(&opts.BlockTime, "block-time", time.Second, "simulated: time between blocks")
+	fs.IntVar(&opts.Senders, "senders", 2, "simulated: sender keys to generate when the config has none")
+	fs.IntVar(&opts.Recipients, "recipients", 2, "simulated: recipients to generate when the config has none")
+	run := fs.Bool("run", false, "run the generated config once bootstrapped")
+	fs.Parse(args)
+
+	var config ethload.TestConfig
+	if *configPath != "" {
+		if err := loadConfig(*configPath, &config); err != nil {
+			log.Fatal(err)
+		}
+	}
+	var ok bool
+	if opts.Supply, ok = new(big.Int).SetString(*supply, 10); !ok || opts.Supply.Sign() <= 0 {
+		log.Fatalf("Invalid supply %q: want a positive number of whole tokens", *supply)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	stopChain, err := ethload.Bootstrap(ctx, &config, opts)
+	cancel()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer stopChain()
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to marshal config: %v", err)
+	}
+	if err := os.WriteFile(*outPath, data, 0600); err != nil {
+		log.Fatalf("Failed to write config: %v", err)
+	}
+	fmt.Fprintf(stdout, "Config written to %s\n", *outPath)
+
+	switch {
+	case *run:
+		runTest(config)
+	case opts.Simulated:
+		fmt.Fprintf(stdout, "Serving the simulated chain on %s; interrupt to stop\n", opts.Listen)
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt)
+		<-sig
+	}
+}
+
+// runSweep implements `sweep`: it sends the whole token balance and then the
+// remaining ETH, less fees, of every given key to a treasury address.
+func runSweep(args []string) {
+	opts := ethload.SweepOptions{Output: stdout}
+	fs := flag.NewFlagSet("sweep", flag.ExitOnError)
+	configPath := fs.String("config", "", "config for rpcUrl/chain, erc20Contract and fees; its senderKeys are swept when no -keys or -keystore is given")
+	treasuryHex := fs.String("treasury", "", "address that receives the swept funds (required)")
+	fs.StringVar(&opts.KeysFile, "keys", "", "file of hex keys to sweep, one per line")
+	fs.StringVar(&opts.KeystoreDir, "keystore", "", "keystore directory whose keys are swept")
+	fs.StringVar(&opts.PassphraseEnv, "passphrase-env", "ETH_KEYSTORE_PASSPHRASE", "env var holding the keystore passphrase")
+	fs.StringVar(&opts.PassphraseFile, "passphrase-file", "", "file holding the keystore passphrase, read when the env var is empty")
+	out := fs.String("out", defaultSweepOut, "where to write the sweep report")
+	fs.IntVar(&opts.Concurrency, "concurrency", 5, "wallets swept at once")
+	fs.Parse(args)
+
+	var config ethload.TestConfig
+	if *configPath != "" {
+		if err := loadConfig(*configPath, &config); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if !common.IsHexAddress(*treasuryHex) {
+		log.Fatalf("Invalid treasury %q: pass -treasury with an address", *treasuryHex)
+	}
+	opts.Treasury = common.HexToAddress(*treasuryHex)
+
+	ctx, cancel := context.WithTimeout(context.Background(), sweepTimeout)
+	defer cancel()
+	results, err := ethload.Sweep(ctx, config, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ethload.PrintSweep(stdout, results)
+	writeResults(*out, results)
+}
+
+// runSimulate implements `simulate`: it runs scenarios end to end against an
+// in-process simulated chain with the test token deployed, and exits with
+// status 1 if any of them fails.
+func runSimulate(args []string) {
+	opts := ethload.SimOptions{Supply: big.NewInt(1_000_000_000), Output: stdout}
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	configPath := fs.String("config", "", "config for the transfer settings (loopCount, amounts, txType, fees...); its chain, senders and token are replaced")
+	scenarios := fs.String("scenarios", simulateScenarios, "comma-separated scenarios to run")
+	fs.IntVar(&opts.Senders, "senders", 3, "sender keys to generate")
+	fs.IntVar(&opts.Recipients, "recipients", 3, "recipients to generate")
+	fs.DurationVar(&opts.BlockTime, "block-time", 100*time.Millisecond, "time between blocks")
+	fs.Parse(args)
+
+	config := ethload.TestConfig{
+		LoopCount:      2,
+		MinAmount:      1,
+		MaxAmount:      5,
+		MaxGoroutines:  5,
+		RetryCount:     2,
+		RetryBackoffMs: 200,
+	}
+	if *configPath != "" {
+		if err := loadConfig(*configPath, &config); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	h, err := ethload.NewSimHarness(ctx, opts)
+	cancel()
+	if err != nil {
+		log.Fatalf("Failed to start simulated chain: %v", err)
+	}
+	defer h.Close()
+
+	var failed []string
+	for _, scenario := range strings.Split(*scenarios, ",") {
+		scenario = strings.ToLower(strings.TrimSpace(scenario))
+		if scenario == "" {
+			continue
+		}
+		fmt.Fprintf(stdout, "\n========== %s ==========\n", strings.ToUpper(scenario))
+		if err := h.Run(context.Background(), config, scenario); err != nil {
+			fmt.Fprintf(stdout, "%s: FAILED: %v\n", scenario, err)
+			failed = append(failed, scenario)
+		}
+	}
+	if len(failed) > 0 {
+		h.Close()
+		log.Fatalf("Simulated scenarios failed: %s", strings.Join(failed, ", "))
+	}
+	fmt.Fprintln(stdout, "\nAll simulated scenarios passed")
+}
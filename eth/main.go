@@ -0,0 +1,133 @@
+// This program demonstrates sending ERC20 transfers on Ethereum-like networks
+// (e.g., Sepolia) using Go. It supports multiple sender keys and multiple
+// recipient addresses, enabling scenarios such as one-to-one, one-to-many,
+// many-to-one, and many-to-many. It includes balance checks, nonce management,
+// and optional receipt waiting. Results are written to results.json for later
+// analysis. The load engine itself is the tron_load/ethload package.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	"tron_load/ethload"
+	"tron_load/secrets"
+)
+
+// Path to store per-run results for quick checks
+const ETH_RESULT_FILENAME = "eth_result.json"
+
+// stdout is where the commands print, with every known secret redacted.
+var stdout = secrets.Writer(os.Stdout)
+
+func main() {
+	log.SetOutput(secrets.Writer(os.Stderr))
+	if len(os.Args) > 1 && os.Args[1] == "bootstrap" {
+		runBootstrap(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "sweep" {
+		runSweep(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "simulate" {
+		runSimulate(os.Args[2:])
+		return
+	}
+	config := ethload.TestConfig{
+		Scenario:      "many-to-many",
+		ERC20Contract: "0xdd13E55209Fd76AfE204dBda4007C227904f0a81",
+		SenderKeys: []string{
+			// Replace with funded Sepolia private keys
+			"REPLACE_WITH_PRIVATE_KEY_1",
+			"REPLACE_WITH_PRIVATE_KEY_2",
+			"REPLACE_WITH_PRIVATE_KEY_3",
+			"REPLACE_WITH_PRIVATE_KEY_4",
+		},
+		Recipients: []string{
+			"0x627306090abaB3A6e1400e9345bC60c78a8BEf57",
+			"0xf17f52151EbEF6C7334FAD080c5704D77216b732",
+		},
+		MaxConcurrent:  5,
+		WaitForReceipt: true,
+		LoopCount:      2,
+		Delay:          100,
+		MinAmount:      3.0,
+		MaxAmount:      10.0,
+		Decimals:       18,
+		MaxGoroutines:  10,
+		RetryCount:     2,
+		RetryBackoffMs: 200,
+	}
+
+	if len(os.Args) > 1 {
+		if err := loadConfig(os.Args[1], &config); err != nil {
+			log.Fatal(err)
+		}
+	}
+	runTest(config)
+}
+
+// loadConfig overlays the JSON config file at path onto config.
+func loadConfig(path string, config *ethload.TestConfig) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Failed to read config: %w", err)
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return fmt.Errorf("Failed to parse config: %w", err)
+	}
+	return nil
+}
+
+// runTest runs the scenario of config and writes the results, exiting with
+// status 1 if reconciliation fails. An interrupt stops sending; the transfers
+// so far are still reported.
+func runTest(config ethload.TestConfig) {
+	runner, err := ethload.NewRunner(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	runner.Output = stdout
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	report, err := runner.Run(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ethload.PrintReport(stdout, report)
+	writeResults(ETH_RESULT_FILENAME, report.Results)
+
+	// Persist results to a configurable path (defaults to ./results/results.json)
+	resultsPath := os.Getenv("RESULTS_PATH")
+	if resultsPath == "" {
+		resultsPath = "results/results.json"
+	}
+	if err := os.MkdirAll(filepath.Dir(resultsPath), 0755); err != nil {
+		log.Printf("warning: could not create results dir: %v", err)
+	}
+	data, _ := json.MarshalIndent(report, "", "  ")
+	if err := os.WriteFile(resultsPath, secrets.RedactBytes(data), 0644); err != nil {
+		log.Printf("failed writing results to %s: %v", resultsPath, err)
+	} else {
+		fmt.Fprintf(stdout, "Results written to %s\n", resultsPath)
+	}
+	if report.Reconciliation != nil && !report.Reconciliation.OK {
+		os.Exit(1)
+	}
+}
+
+// writeResults writes results to path, logging rather than exiting on failure
+// so the rest of the report is still saved.
+func writeResults(path string, results []ethload.TransferResult) {
+	if err := ethload.WriteResults(path, results); err != nil {
+		log.Print(err)
+		return
+	}
+	fmt.Fprintf(stdout, "%s written to %s\n", filepath.Base(path), path)
+}
@@ -0,0 +1,237 @@
+package ethload
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Amount distributions
+const (
+	DistFixed     = "fixed"
+	DistUniform   = "uniform"
+	DistNormal    = "normal"
+	DistLogNormal = "lognormal"
+	DistWeighted  = "weighted"
+)
+
+// maxAmountDraws bounds the resampling of normal and log-normal draws that
+// fall outside [min, max].
+const maxAmountDraws = 100
+
+// AmountConfig picks the token amount of every transfer. Amounts are in whole
+// tokens, as decimal strings so they are exact ("12.5", "0.000001").
+type AmountConfig struct {
+	Distribution string           `json:"distribution"` // fixed, uniform (default), normal, lognormal or weighted
+	Value        string           `json:"value"`        // fixed
+	Min          string           `json:"min"`          // uniform bounds (default minAmount/maxAmount, min 0 when unset), optional clamps for normal/lognormal
+	Max          string           `json:"max"`
+	Mean         float64          `json:"mean"`      // normal/lognormal: mean of the amounts
+	StdDev       float64          `json:"stdDev"`    // normal/lognormal: standard deviation of the amounts
+	Weights      []WeightedAmount `json:"weights"`   // weighted
+	Precision    *int             `json:"precision"` // decimal places kept (default: the token's decimals)
+	Seed         int64            `json:"seed"`      // 0 picks one from the clock
+}
+
+// WeightedAmount is one exact amount of a weighted distribution.
+type WeightedAmount struct {
+	Amount string  `json:"amount"`
+	Weight float64 `json:"weight"`
+}
+
+// amountPicker draws transfer amounts in token base units. It is safe for
+// concurrent use.
+type amountPicker struct {
+	mu        sync.Mutex
+	rng       *rand.Rand
+	dist      string
+	decimals  int
+	precision int
+	step      *big.Int // base units per precision unit
+	value     *big.Int
+	min, max  *big.Int // nil when unbounded
+	mean, std float64
+	amounts   []*big.Int
+	cumWeight []float64
+}
+
+// newAmountPicker validates config.Amounts, falling back to the legacy fields:
+// amount (a fixed amount in base units) and minAmount/maxAmount (uniform).
+func newAmountPicker(config TestConfig, decimals int) (*amountPicker, error) {
+	ac := AmountConfig{}
+	if config.Amounts != nil {
+		ac = *config.Amounts
+	} else if strings.TrimSpace(config.Amount) != "" {
+		v, ok := new(big.Int).SetString(strings.TrimSpace(config.Amount), 10)
+		if !ok || v.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid amount %q: want a positive integer in the token's smallest unit", config.Amount)
+		}
+		return &amountPicker{dist: DistFixed, decimals: decimals, value: v}, nil
+	}
+	p := &amountPicker{dist: strings.ToLower(strings.TrimSpace(ac.Distribution)), decimals: decimals, precision: decimals}
+	if p.dist == "" {
+		p.dist = DistUniform
+	}
+	if ac.Precision != nil {
+		if *ac.Precision < 0 || *ac.Precision > decimals {
+			return nil, fmt.Errorf("amount precision must be between 0 and %d", decimals)
+		}
+		p.precision = *ac.Precision
+	}
+	p.step = new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals-p.precision)), nil)
+	seed := ac.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	p.rng = rand.New(rand.NewSource(seed))
+
+	bound := func(field, s string, legacy float64) (*big.Int, error) {
+		if strings.TrimSpace(s) == "" {
+			if legacy < 0 {
+				return nil, fmt.Errorf("%sAmount must not be negative", field)
+			}
+			if legacy == 0 {
+				return nil, nil
+			}
+			s = strconv.FormatFloat(legacy, 'f', -1, 64)
+		}
+		v, err := p.parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("amount %s: %w", field, err)
+		}
+		return v, nil
+	}
+	// minAmount/maxAmount only default the bounds of uniform amounts; they
+	// would otherwise clamp a normal or log-normal distribution by accident.
+	legacyMin, legacyMax := 0.0, 0.0
+	if p.dist == DistUniform {
+		legacyMin, legacyMax = config.MinAmount, config.MaxAmount
+	}
+	var err error
+	if p.min, err = bound("min", ac.Min, legacyMin); err != nil {
+		return nil, err
+	}
+	if p.max, err = bound("max", ac.Max, legacyMax); err != nil {
+		return nil, err
+	}
+	if p.min != nil && p.max != nil && p.max.Cmp(p.min) < 0 {
+		return nil, fmt.Errorf("amount max is below min")
+	}
+
+	switch p.dist {
+	case DistFixed:
+		if p.value, err = p.parse(ac.Value); err != nil {
+			return nil, fmt.Errorf("amount value: %w", err)
+		}
+	case DistUniform:
+		if p.min == nil {
+			if p.max == nil {
+				return nil, fmt.Errorf("uniform amounts need min or max (or minAmount/maxAmount)")
+			}
+			// As with the legacy minAmount of 0, the range starts at zero.
+			p.min = new(big.Int)
+		}
+		if p.max == nil || p.max.Cmp(p.min) < 0 {
+			p.max = p.min
+		}
+	case DistNormal, DistLogNormal:
+		if ac.Mean <= 0 || ac.StdDev < 0 {
+			return nil, fmt.Errorf("%s amounts need a positive mean and a non-negative stdDev", p.dist)
+		}
+		p.mean, p.std = ac.Mean, ac.StdDev
+		if p.dist == DistLogNormal {
+			// Parameters of the underlying normal giving the requested mean and stddev.
+			sigma2 := math.Log(1 + (ac.StdDev*ac.StdDev)/(ac.Mean*ac.Mean))
+			p.mean, p.std = math.Log(ac.Mean)-sigma2/2, math.Sqrt(sigma2)
+		}
+	case DistWeighted:
+		if len(ac.Weights) == 0 {
+			return nil, fmt.Errorf("weighted amounts need weights")
+		}
+		total := 0.0
+		for i, w := range ac.Weights {
+			v, err := p.parse(w.Amount)
+			if err != nil {
+				return nil, fmt.Errorf("amount weights[%d]: %w", i, err)
+			}
+			if w.Weight <= 0 {
+				return nil, fmt.Errorf("amount weights[%d]: weight must be positive", i)
+			}
+			total += w.Weight
+			p.amounts = append(p.amounts, v)
+			p.cumWeight = append(p.cumWeight, total)
+		}
+	default:
+		return nil, fmt.Errorf("invalid amount distribution %q (want %s, %s, %s, %s or %s)", ac.Distribution, DistFixed, DistUniform, DistNormal, DistLogNormal, DistWeighted)
+	}
+	return p, nil
+}
+
+// parse converts a decimal token amount to base units, rejecting digits
+// beyond the configured precision.
+func (p *amountPicker) parse(s string) (*big.Int, error) {
+	s = strings.TrimSpace(s)
+	whole, frac, _ := strings.Cut(s, ".")
+	if len(frac) > p.precision {
+		return nil, fmt.Errorf("%q has more than %d decimal places", s, p.precision)
+	}
+	v, ok := new(big.Int).SetString(whole+frac+strings.Repeat("0", p.decimals-len(frac)), 10)
+	if !ok || v.Sign() <= 0 || strings.HasPrefix(s, "+") {
+		return nil, fmt.Errorf("%q is not a positive decimal amount", s)
+	}
+	return v, nil
+}
+
+// next draws the amount of the next transfer, in base units.
+func (p *amountPicker) next() *big.Int {
+	if p.dist == DistFixed {
+		return new(big.Int).Set(p.value)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch p.dist {
+	case DistUniform:
+		// A whole number of precision steps in [min, max].
+		lo := new(big.Int).Div(new(big.Int).Add(p.min, new(big.Int).Sub(p.step, big.NewInt(1))), p.step)
+		hi := new(big.Int).Div(p.max, p.step)
+		if hi.Cmp(lo) < 0 {
+			return new(big.Int).Set(p.min)
+		}
+		n := new(big.Int).Rand(p.rng, new(big.Int).Add(new(big.Int).Sub(hi, lo), big.NewInt(1)))
+		return n.Add(n, lo).Mul(n, p.step)
+	case DistWeighted:
+		r := p.rng.Float64() * p.cumWeight[len(p.cumWeight)-1]
+		for i, c := range p.cumWeight {
+			if r < c {
+				return new(big.Int).Set(p.amounts[i])
+			}
+		}
+		return new(big.Int).Set(p.amounts[len(p.amounts)-1])
+	}
+	var v *big.Int
+	for i := 0; i < maxAmountDraws; i++ {
+		x := p.mean + p.std*p.rng.NormFloat64()
+		if p.dist == DistLogNormal {
+			x = math.Exp(x)
+		}
+		v, _ = p.parse(strconv.FormatFloat(x, 'f', p.precision, 64))
+		if v != nil && (p.min == nil || v.Cmp(p.min) >= 0) && (p.max == nil || v.Cmp(p.max) <= 0) {
+			return v
+		}
+	}
+	// Mostly out of range: clamp the last draw.
+	switch {
+	case p.min != nil && (v == nil || v.Cmp(p.min) < 0):
+		return new(big.Int).Set(p.min)
+	case p.max != nil && v != nil && v.Cmp(p.max) > 0:
+		return new(big.Int).Set(p.max)
+	case v == nil:
+		return new(big.Int).Set(p.step)
+	}
+	return v
+}
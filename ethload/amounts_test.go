@@ -0,0 +1,155 @@
+package ethload
+
+import (
+	"math/big"
+	"slices"
+	"testing"
+)
+
+func TestAmountPickerParse(t *testing.T) {
+	tests := []struct {
+		in        string
+		decimals  int
+		precision int
+		want      string // base units; empty when parse must fail
+	}{
+		{in: "1", decimals: 6, precision: 6, want: "1000000"},
+		{in: "1.5", decimals: 6, precision: 6, want: "1500000"},
+		{in: "0.000001", decimals: 6, precision: 6, want: "1"},
+		{in: " 12.25 ", decimals: 6, precision: 6, want: "12250000"},
+		{in: ".5", decimals: 6, precision: 6, want: "500000"},
+		{in: "1.", decimals: 6, precision: 6, want: "1000000"},
+		{in: "3", decimals: 0, precision: 0, want: "3"},
+		{in: "1.23", decimals: 18, precision: 2, want: "1230000000000000000"},
+		{in: "1.234", decimals: 18, precision: 2},
+		{in: "0.0000001", decimals: 6, precision: 6},
+		{in: "0", decimals: 6, precision: 6},
+		{in: "0.000", decimals: 6, precision: 6},
+		{in: "-1", decimals: 6, precision: 6},
+		{in: "+1", decimals: 6, precision: 6},
+		{in: "1e3", decimals: 6, precision: 6},
+		{in: "", decimals: 6, precision: 6},
+		{in: "1.2.3", decimals: 6, precision: 6},
+	}
+	for _, tt := range tests {
+		p := &amountPicker{decimals: tt.decimals, precision: tt.precision}
+		got, err := p.parse(tt.in)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("parse(%q) = %s, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parse(%q): %v", tt.in, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("parse(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestAmountPickerNext(t *testing.T) {
+	precision := 2
+	tests := []struct {
+		name     string
+		config   TestConfig
+		min, max string // inclusive bounds of every draw, in base units
+		step     string // every draw is a multiple of step
+		only     []string
+	}{
+		{name: "legacy amount", config: TestConfig{Amount: "12345"},
+			only: []string{"12345"}},
+		{name: "legacy min/max", config: TestConfig{MinAmount: 1, MaxAmount: 3},
+			min: "1000000", max: "3000000", step: "1"},
+		{name: "legacy zero min", config: TestConfig{MinAmount: 0, MaxAmount: 0.000002},
+			only: []string{"0", "1", "2"}},
+		{name: "uniform max only", config: TestConfig{Amounts: &AmountConfig{Max: "0.02", Precision: &precision, Seed: 1}},
+			only: []string{"0", "10000", "20000"}},
+		{name: "fixed", config: TestConfig{Amounts: &AmountConfig{Distribution: DistFixed, Value: "2.5"}},
+			only: []string{"2500000"}},
+		{name: "uniform with precision", config: TestConfig{Amounts: &AmountConfig{Min: "0.01", Max: "0.05", Precision: &precision, Seed: 1}},
+			min: "10000", max: "50000", step: "10000"},
+		{name: "uniform min only", config: TestConfig{Amounts: &AmountConfig{Distribution: DistUniform, Min: "7", Seed: 1}},
+			only: []string{"7000000"}},
+		{name: "normal clamped", config: TestConfig{Amounts: &AmountConfig{Distribution: DistNormal, Mean: 10, StdDev: 5, Min: "8", Max: "12", Seed: 1}},
+			min: "8000000", max: "12000000", step: "1"},
+		{name: "lognormal", config: TestConfig{Amounts: &AmountConfig{Distribution: DistLogNormal, Mean: 1, StdDev: 0.5, Precision: &precision, Seed: 1}},
+			min: "10000", step: "10000"},
+		{name: "weighted", config: TestConfig{Amounts: &AmountConfig{Distribution: DistWeighted, Seed: 1, Weights: []WeightedAmount{
+			{Amount: "1", Weight: 3}, {Amount: "100", Weight: 1},
+		}}},
+			only: []string{"1000000", "100000000"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := newAmountPicker(tt.config, 6)
+			if err != nil {
+				t.Fatal(err)
+			}
+			seen := map[string]bool{}
+			for i := 0; i < 500; i++ {
+				v := p.next()
+				seen[v.String()] = true
+				if tt.only != nil {
+					if !slices.Contains(tt.only, v.String()) {
+						t.Fatalf("draw %s, want one of %v", v, tt.only)
+					}
+					continue
+				}
+				if v.Cmp(mustBig(tt.min)) < 0 || (tt.max != "" && v.Cmp(mustBig(tt.max)) > 0) {
+					t.Fatalf("draw %s outside [%s, %s]", v, tt.min, tt.max)
+				}
+				if new(big.Int).Mod(v, mustBig(tt.step)).Sign() != 0 {
+					t.Fatalf("draw %s is not a multiple of %s", v, tt.step)
+				}
+			}
+			if len(tt.only) > 1 && len(seen) != len(tt.only) {
+				t.Errorf("drew %v, want every one of %v", seen, tt.only)
+			}
+		})
+	}
+}
+
+func TestAmountPickerNextReturnsCopies(t *testing.T) {
+	p, err := newAmountPicker(TestConfig{Amounts: &AmountConfig{Distribution: DistFixed, Value: "1"}}, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.next().SetInt64(0)
+	if got := p.next(); got.String() != "1000000" {
+		t.Errorf("next() = %s after mutating an earlier draw, want 1000000", got)
+	}
+}
+
+func TestNewAmountPickerRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		config TestConfig
+	}{
+		{"non-integer legacy amount", TestConfig{Amount: "1.5"}},
+		{"max below min", TestConfig{Amounts: &AmountConfig{Min: "2", Max: "1"}}},
+		{"uniform without bounds", TestConfig{Amounts: &AmountConfig{Distribution: DistUniform}}},
+		{"negative legacy min", TestConfig{MinAmount: -1, MaxAmount: 3}},
+		{"fixed without value", TestConfig{Amounts: &AmountConfig{Distribution: DistFixed}}},
+		{"normal without mean", TestConfig{Amounts: &AmountConfig{Distribution: DistNormal, StdDev: 1}}},
+		{"weighted without weights", TestConfig{Amounts: &AmountConfig{Distribution: DistWeighted}}},
+		{"zero weight", TestConfig{Amounts: &AmountConfig{Distribution: DistWeighted, Weights: []WeightedAmount{{Amount: "1"}}}}},
+		{"precision above decimals", TestConfig{Amounts: &AmountConfig{Min: "1", Precision: func() *int { p := 7; return &p }()}}},
+		{"unknown distribution", TestConfig{Amounts: &AmountConfig{Distribution: "poisson"}}},
+	}
+	for _, tt := range tests {
+		if _, err := newAmountPicker(tt.config, 6); err == nil {
+			t.Errorf("%s: accepted", tt.name)
+		}
+	}
+}
+
+func mustBig(s string) *big.Int {
+	v, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic("bad big.Int literal " + s)
+	}
+	return v
+}
@@ -0,0 +1,271 @@
+package ethload
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	gethnode "github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/params"
+
+	"tron_load/ethload/testtoken"
+)
+
+// simulatedFunding is the genesis ETH balance of every generated account on
+// the simulated chain.
+var simulatedFunding = new(big.Int).Mul(big.NewInt(1000), big.NewInt(params.Ether))
+
+// BootstrapOptions configures Bootstrap.
+type BootstrapOptions struct {
+	DeployerKey string        // hex key or reference that deploys and mints (default: the first sender key)
+	Supply      *big.Int      // whole tokens minted to each sender
+	Simulated   bool          // start an in-process simulated chain instead of dialing rpcUrl
+	Listen      string        // simulated: HTTP RPC address of the chain
+	BlockTime   time.Duration // simulated: time between blocks
+	Senders     int           // simulated: sender keys to generate when the config has none
+	Recipients  int           // simulated: recipients to generate when the config has none
+	Output      io.Writer     // progress messages; nil discards them
+}
+
+// Bootstrap deploys the bundled test token to the chain of config (or an
+// in-process simulated chain), mints opts.Supply to every sender and points
+// config at the token. A simulated chain serves until stop is called; stop is
+// a no-op otherwise.
+func Bootstrap(ctx context.Context, config *TestConfig, opts BootstrapOptions) (stop func(), err error) {
+	stop = func() {}
+	if opts.Supply == nil || opts.Supply.Sign() <= 0 {
+		return stop, fmt.Errorf("supply must be a positive number of whole tokens")
+	}
+	supply := new(big.Int).Mul(opts.Supply, new(big.Int).Exp(big.NewInt(10), big.NewInt(testTokenDecimals), nil))
+
+	resolver := newResolver()
+	keys, err := senderKeys(resolver, *config, opts.Output)
+	if err != nil {
+		return stop, fmt.Errorf("failed to load senders: %w", err)
+	}
+	if opts.Simulated && len(keys) == 0 {
+		for i := 0; i < opts.Senders; i++ {
+			config.SenderKeys = append(config.SenderKeys, newKeyHex())
+		}
+		keys = config.SenderKeys
+	}
+	deployerHex := opts.DeployerKey
+	switch {
+	case deployerHex != "":
+		if deployerHex, err = resolver.Resolve(deployerHex); err != nil {
+			return stop, fmt.Errorf("invalid deployer key: %w", err)
+		}
+	case opts.Simulated:
+		deployerHex = newKeyHex()
+	case len(keys) == 0:
+		return stop, fmt.Errorf("no deployer: give a deployer key or list senderKeys in the config")
+	default:
+		deployerHex = keys[0]
+	}
+	deployer, _, err := loadPrivateKey(deployerHex)
+	if err != nil {
+		return stop, fmt.Errorf("invalid deployer key: %w", err)
+	}
+
+	if opts.Simulated {
+		if stop, err = startSimulatedChain(config, opts, append([]string{deployerHex}, keys...)); err != nil {
+			return func() {}, fmt.Errorf("failed to start simulated chain: %w", err)
+		}
+	} else if err := applyChainProfile(config); err != nil {
+		return stop, fmt.Errorf("invalid chain: %w", err)
+	}
+	fail := func(err error) (func(), error) {
+		stop()
+		return func() {}, err
+	}
+
+	client, err := dialConfig(resolver, *config)
+	if err != nil {
+		return fail(err)
+	}
+	defer client.Close()
+	n, err := openNode(ctx, client, *config, opts.Output)
+	if err != nil {
+		return fail(err)
+	}
+	token, err := n.deployTestToken(ctx, deployer, keys, supply)
+	if err != nil {
+		return fail(fmt.Errorf("bootstrap failed: %w", err))
+	}
+	config.ERC20Contract = token.Hex()
+	config.ContractAddr = ""
+	config.Decimals = testTokenDecimals
+	return stop, nil
+}
+
+// startSimulatedChain starts an in-process chain serving HTTP RPC on
+// opts.Listen and sealing a block every opts.BlockTime, with every one of
+// funded keys funded in genesis. Recipients are generated when config has
+// none, and config is pointed at the chain.
+func startSimulatedChain(config *TestConfig, opts BootstrapOptions, funded []string) (func(), error) {
+	host, portStr, err := net.SplitHostPort(opts.Listen)
+	if err != nil {
+		return nil, fmt.Errorf("invalid listen address %q: %w", opts.Listen, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid listen port %q", portStr)
+	}
+	if opts.BlockTime <= 0 {
+		return nil, fmt.Errorf("block-time must be positive")
+	}
+	if len(config.Recipients) == 0 {
+		for i := 0; i < opts.Recipients; i++ {
+			_, addr, _ := loadPrivateKey(newKeyHex())
+			config.Recipients = append(config.Recipients, addr.Hex())
+		}
+	}
+
+	alloc := types.GenesisAlloc{}
+	for _, k := range funded {
+		_, addr, err := loadPrivateKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key: %w", err)
+		}
+		alloc[addr] = types.Account{Balance: simulatedFunding}
+	}
+	sim := simulated.NewBackend(alloc, func(nodeConf *gethnode.Config, ethConf *ethconfig.Config) {
+		nodeConf.HTTPHost, nodeConf.HTTPPort = host, port
+		nodeConf.HTTPModules = []string{"eth", "net", "web3"}
+	})
+
+	stopSealing := sealBlocks(sim, opts.BlockTime)
+	config.Chain = ""
+	config.RPCURL = "http://" + opts.Listen
+	config.ChainID = params.AllDevChainProtocolChanges.ChainID.Uint64()
+	printf(opts.Output, "Simulated chain at %s, a block every %s\n", config.RPCURL, opts.BlockTime)
+	return func() {
+		stopSealing()
+		sim.Close()
+	}, nil
+}
+
+// sealBlocks commits a block on sim every blockTime until the returned stop
+// function is called.
+func sealBlocks(sim *simulated.Backend, blockTime time.Duration) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(blockTime)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				sim.Commit()
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func newKeyHex() string {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(crypto.FromECDSA(key))
+}
+
+// deployTestToken deploys the test token from deployer and mints supply to
+// each of senderKeys, waiting for every transaction to be mined.
+func (n *node) deployTestToken(ctx context.Context, deployer *ecdsa.PrivateKey, senderKeys []string, supply *big.Int) (common.Address, error) {
+	parsed, err := testtoken.TestTokenMetaData.GetAbi()
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to parse ABI: %w", err)
+	}
+	from := crypto.PubkeyToAddress(deployer.PublicKey)
+	nonce, err := n.client.PendingNonceAt(ctx, from)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to get deployer nonce: %w", err)
+	}
+	gasPrice, err := n.client.SuggestGasPrice(ctx)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to get gas price: %w", err)
+	}
+	// Head room for the base fee to rise while the bootstrap txs wait.
+	gasPrice.Mul(gasPrice, big.NewInt(2))
+	send := func(tx *types.Transaction) (*types.Receipt, error) {
+		signed, err := types.SignTx(tx, types.LatestSignerForChainID(n.chainID), deployer)
+		if err != nil {
+			return nil, err
+		}
+		if err := n.client.SendTransaction(ctx, signed); err != nil {
+			return nil, err
+		}
+		nonce++
+		return n.waitMined(ctx, signed.Hash())
+	}
+
+	code := testTokenCode()
+	printf(n.out, "Deploying %s (%s) from %s\n", testTokenName, testTokenSymbol, from.Hex())
+	receipt, err := send(types.NewContractCreation(nonce, new(big.Int), 1_000_000, gasPrice, code))
+	if err != nil {
+		return common.Address{}, fmt.Errorf("deploy failed: %w", err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return common.Address{}, fmt.Errorf("deploy reverted in tx %s", receipt.TxHash.Hex())
+	}
+	token := receipt.ContractAddress
+	printf(n.out, "Token deployed at %s\n", token.Hex())
+
+	for i, k := range senderKeys {
+		_, to, err := loadPrivateKey(k)
+		if err != nil {
+			return token, fmt.Errorf("sender key %d: %w", i+1, err)
+		}
+		data, err := parsed.Pack("mint", to, supply)
+		if err != nil {
+			return token, err
+		}
+		receipt, err := send(types.NewTransaction(nonce, token, new(big.Int), 200_000, gasPrice, data))
+		if err != nil {
+			return token, fmt.Errorf("mint to %s failed: %w", to.Hex(), err)
+		}
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			return token, fmt.Errorf("mint to %s reverted in tx %s", to.Hex(), receipt.TxHash.Hex())
+		}
+		balance, err := n.tokenBalance(ctx, token, to)
+		if err != nil {
+			return token, err
+		}
+		printf(n.out, "Minted to %s (balance %s)\n", to.Hex(), balance)
+	}
+	return token, nil
+}
+
+// waitMined polls for the receipt of hash.
+func (n *node) waitMined(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	for {
+		receipt, err := n.client.TransactionReceipt(ctx, hash)
+		if err == nil {
+			return receipt, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("tx %s not mined: %w", hash.Hex(), ctx.Err())
+		case <-time.After(receiptPoll / 4):
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package ethload
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Placeholders substituted in contract-call args
+const (
+	argRecipient = "{{recipient}}" // the pair's recipient address
+	argAmount    = "{{amount}}"    // the drawn amount, in base units
+	argRandom    = "{{random}}"    // a random value of the argument's type
+	argSequence  = "{{sequence}}"  // 0, 1, 2... across the run
+)
+
+// CallConfig is the contract call sent by txType contract-call.
+type CallConfig struct {
+	Contract string   `json:"contract"` // defaults to the ERC20 contract
+	ABIFile  string   `json:"abiFile"`  // JSON ABI, or a build artifact with an "abi" field
+	Method   string   `json:"method"`
+	Args     []string `json:"args"`  // one per method input, with placeholders substituted
+	Value    string   `json:"value"` // wei sent with every call (default 0); the method must be payable
+}
+
+// callArgs are the values placeholders expand to for one call.
+type callArgs struct {
+	recipient common.Address
+	amount    *big.Int
+	sequence  uint64
+	random    func(abi.Type) string
+}
+
+// contractCall is a validated CallConfig.
+type contractCall struct {
+	contract common.Address
+	method   abi.Method
+	args     []string
+	value    *big.Int
+}
+
+func loadContractCall(cc *CallConfig, token common.Address) (*contractCall, error) {
+	if cc == nil {
+		return nil, fmt.Errorf("txType contract-call needs call")
+	}
+	c := &contractCall{contract: token, args: cc.Args, value: new(big.Int)}
+	if strings.TrimSpace(cc.Contract) != "" {
+		if !common.IsHexAddress(cc.Contract) {
+			return nil, fmt.Errorf("invalid call contract %q", cc.Contract)
+		}
+		c.contract = common.HexToAddress(cc.Contract)
+	}
+	if c.contract == (common.Address{}) {
+		return nil, fmt.Errorf("call needs a contract (or erc20Contract)")
+	}
+	parsed, err := loadABI(cc.ABIFile)
+	if err != nil {
+		return nil, err
+	}
+	method, ok := parsed.Methods[cc.Method]
+	if !ok {
+		return nil, fmt.Errorf("method %q not found in %s", cc.Method, cc.ABIFile)
+	}
+	c.method = method
+	if len(cc.Args) != len(method.Inputs) {
+		return nil, fmt.Errorf("%s takes %d argument(s), call has %d", method.Sig, len(method.Inputs), len(cc.Args))
+	}
+	if strings.TrimSpace(cc.Value) != "" {
+		if _, ok := c.value.SetString(strings.TrimSpace(cc.Value), 10); !ok || c.value.Sign() < 0 {
+			return nil, fmt.Errorf("invalid call value %q: want wei", cc.Value)
+		}
+		if c.value.Sign() > 0 && !method.IsPayable() {
+			return nil, fmt.Errorf("call value set but %s is not payable", method.Sig)
+		}
+	}
+	// Catch bad literals and unsupported types before the run starts.
+	sample := callArgs{amount: big.NewInt(1), random: func(t abi.Type) string { return randomArg(t, make([]byte, 32)) }}
+	if _, err := c.pack(sample); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// loadABI reads a JSON ABI, either bare or in the "abi" field of a compiler
+// artifact (Hardhat, Foundry, Truffle).
+func loadABI(path string) (abi.ABI, error) {
+	if strings.TrimSpace(path) == "" {
+		return abi.ABI{}, fmt.Errorf("call needs an abiFile")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return abi.ABI{}, fmt.Errorf("failed to read ABI: %w", err)
+	}
+	var artifact struct {
+		ABI json.RawMessage `json:"abi"`
+	}
+	if json.Unmarshal(data, &artifact) == nil && len(artifact.ABI) > 0 {
+		data = artifact.ABI
+	}
+	parsed, err := abi.JSON(strings.NewReader(string(data)))
+	if err != nil {
+		return abi.ABI{}, fmt.Errorf("failed to parse ABI %s: %w", path, err)
+	}
+	return parsed, nil
+}
+
+func (c *contractCall) usesAmount() bool {
+	for _, a := range c.args {
+		if strings.Contains(a, argAmount) {
+			return true
+		}
+	}
+	return false
+}
+
+// pack builds the calldata for one call.
+func (c *contractCall) pack(a callArgs) ([]byte, error) {
+	amount := ""
+	if a.amount != nil {
+		amount = a.amount.String()
+	}
+	values := make([]interface{}, len(c.args))
+	for i, input := range c.method.Inputs {
+		s := strings.TrimSpace(c.args[i])
+		if s == argRandom {
+			s = a.random(input.Type)
+		} else {
+			for strings.Contains(s, argRandom) {
+				s = strings.Replace(s, argRandom, a.random(abi.Type{T: abi.UintTy, Size: 64}), 1)
+			}
+			s = strings.NewReplacer(
+				argRecipient, a.recipient.Hex(),
+				argAmount, amount,
+				argSequence, strconv.FormatUint(a.sequence, 10),
+			).Replace(s)
+		}
+		v, err := convertArg(input.Type, s)
+		if err != nil {
+			return nil, fmt.Errorf("%s argument %d (%s): %w", c.method.Name, i, input.Type, err)
+		}
+		values[i] = v
+	}
+	packed, err := c.method.Inputs.Pack(values...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack %s: %w", c.method.Name, err)
+	}
+	return append(append([]byte{}, c.method.ID...), packed...), nil
+}
+
+// convertArg parses s as a value of ABI type t, in the Go type Pack expects.
+func convertArg(t abi.Type, s string) (interface{}, error) {
+	switch t.T {
+	case abi.AddressTy:
+		if !common.IsHexAddress(s) {
+			return nil, fmt.Errorf("%q is not an address", s)
+		}
+		return common.HexToAddress(s), nil
+	case abi.BoolTy:
+		return strconv.ParseBool(s)
+	case abi.StringTy:
+		return s, nil
+	case abi.UintTy, abi.IntTy:
+		n, ok := new(big.Int).SetString(s, 0)
+		if !ok {
+			return nil, fmt.Errorf("%q is not an integer", s)
+		}
+		bits := uint(t.Size)
+		if t.T == abi.IntTy {
+			bits--
+		}
+		limit := new(big.Int).Lsh(big.NewInt(1), bits)
+		if n.Cmp(limit) >= 0 || (t.T == abi.UintTy && n.Sign() < 0) || n.Cmp(new(big.Int).Neg(limit)) < 0 {
+			return nil, fmt.Errorf("%s is out of range for %s", s, t)
+		}
+		if t.Size > 64 {
+			return n, nil
+		}
+		v := reflect.New(t.GetType()).Elem()
+		if t.T == abi.UintTy {
+			v.SetUint(n.Uint64())
+		} else {
+			v.SetInt(n.Int64())
+		}
+		return v.Interface(), nil
+	case abi.BytesTy:
+		return hexutil.Decode(s)
+	case abi.FixedBytesTy:
+		b, err := hexutil.Decode(s)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) != t.Size {
+			return nil, fmt.Errorf("%s needs %d bytes, got %d", t, t.Size, len(b))
+		}
+		v := reflect.New(t.GetType()).Elem()
+		reflect.Copy(v, reflect.ValueOf(b))
+		return v.Interface(), nil
+	}
+	return nil, fmt.Errorf("unsupported argument type %s", t)
+}
+
+// randomArg renders random bytes as a value of type t: an integer that fits,
+// an address, or a byte string.
+func randomArg(t abi.Type, random []byte) string {
+	switch t.T {
+	case abi.UintTy, abi.IntTy:
+		bits := uint(t.Size)
+		if t.T == abi.IntTy {
+			bits--
+		}
+		n := new(big.Int).SetBytes(random)
+		return n.Mod(n, new(big.Int).Lsh(big.NewInt(1), bits)).String()
+	case abi.AddressTy:
+		return common.BytesToAddress(random[:common.AddressLength]).Hex()
+	case abi.FixedBytesTy:
+		return hexutil.Encode(random[:t.Size])
+	case abi.BoolTy:
+		return strconv.FormatBool(random[0]&1 == 1)
+	case abi.BytesTy:
+		return hexutil.Encode(random)
+	}
+	n := new(big.Int).SetBytes(random[:8])
+	return n.String()
+}
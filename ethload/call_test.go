@@ -0,0 +1,280 @@
+package ethload
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const testCallABI = `[
+	{"type":"function","name":"deposit","stateMutability":"payable","inputs":[
+		{"name":"to","type":"address"},{"name":"amount","type":"uint256"},{"name":"seq","type":"uint64"},
+		{"name":"memo","type":"string"},{"name":"tag","type":"bytes32"},{"name":"delta","type":"int8"},
+		{"name":"flag","type":"bool"}],"outputs":[]},
+	{"type":"function","name":"ping","stateMutability":"nonpayable","inputs":[{"name":"n","type":"uint8"}],"outputs":[]},
+	{"type":"function","name":"tick","stateMutability":"nonpayable","inputs":[{"name":"n","type":"uint64"}],"outputs":[]}
+]`
+
+// writeCallABI writes testCallABI to a temporary file, wrapped in a compiler
+// artifact when artifact is set.
+func writeCallABI(t *testing.T, artifact bool) string {
+	t.Helper()
+	data := testCallABI
+	if artifact {
+		data = `{"contractName":"Vault","abi":` + testCallABI + `,"bytecode":"0x"}`
+	}
+	path := filepath.Join(t.TempDir(), "abi.json")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func abiType(t *testing.T, name string) abi.Type {
+	t.Helper()
+	typ, err := abi.NewType(name, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return typ
+}
+
+func TestConvertArg(t *testing.T) {
+	maxUint256 := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	tests := []struct {
+		typ  string
+		in   string
+		want interface{} // nil when conversion must fail
+	}{
+		{"address", "0x1000000000000000000000000000000000000001", common.HexToAddress("0x1000000000000000000000000000000000000001")},
+		{"address", "0x1234", nil},
+		{"bool", "true", true},
+		{"bool", "yes", nil},
+		{"string", "hello {{x}}", "hello {{x}}"},
+		{"uint8", "255", uint8(255)},
+		{"uint8", "256", nil},
+		{"uint8", "-1", nil},
+		{"uint64", "0x10", uint64(16)},
+		{"int8", "-128", int8(-128)},
+		{"int8", "127", int8(127)},
+		{"int8", "128", nil},
+		{"int8", "-129", nil},
+		{"int64", "-5", int64(-5)},
+		{"uint256", maxUint256.String(), maxUint256},
+		{"uint256", new(big.Int).Add(maxUint256, big.NewInt(1)).String(), nil},
+		{"int128", "-7", big.NewInt(-7)},
+		{"uint256", "1.5", nil},
+		{"bytes", "0x0102", []byte{1, 2}},
+		{"bytes", "0102", nil},
+		{"bytes4", "0x01020304", [4]byte{1, 2, 3, 4}},
+		{"bytes4", "0x010203", nil},
+		{"uint256[]", "[1]", nil},
+	}
+	for _, tt := range tests {
+		got, err := convertArg(abiType(t, tt.typ), tt.in)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("convertArg(%s, %q) = %v, want an error", tt.typ, tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("convertArg(%s, %q): %v", tt.typ, tt.in, err)
+			continue
+		}
+		if want, ok := tt.want.(*big.Int); ok {
+			if n, ok := got.(*big.Int); !ok || n.Cmp(want) != 0 {
+				t.Errorf("convertArg(%s, %q) = %v, want %s", tt.typ, tt.in, got, want)
+			}
+			continue
+		}
+		if b, ok := tt.want.([]byte); ok {
+			if g, ok := got.([]byte); !ok || !bytes.Equal(g, b) {
+				t.Errorf("convertArg(%s, %q) = %v, want %v", tt.typ, tt.in, got, b)
+			}
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("convertArg(%s, %q) = %#v, want %#v", tt.typ, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRandomArg(t *testing.T) {
+	inputs := [][]byte{make([]byte, 32), bytes.Repeat([]byte{0xff}, 32), bytes.Repeat([]byte{0x81}, 32)}
+	for _, typ := range []string{"uint8", "uint64", "uint256", "int8", "int64", "int256", "address", "bool", "bytes32", "bytes4", "bytes", "string"} {
+		for _, random := range inputs {
+			s := randomArg(abiType(t, typ), random)
+			if _, err := convertArg(abiType(t, typ), s); err != nil {
+				t.Errorf("randomArg(%s, %x) = %q, which does not convert: %v", typ, random[:1], s, err)
+			}
+		}
+	}
+	// Signed values stay non-negative and below the type's maximum.
+	if got := randomArg(abiType(t, "int8"), bytes.Repeat([]byte{0xff}, 32)); got != "127" {
+		t.Errorf("randomArg(int8, 0xff...) = %s, want 127", got)
+	}
+}
+
+func TestContractCallPack(t *testing.T) {
+	contract := common.HexToAddress("0x4000000000000000000000000000000000000004")
+	recipient := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	cc := &CallConfig{
+		Contract: contract.Hex(),
+		ABIFile:  writeCallABI(t, true),
+		Method:   "deposit",
+		Args:     []string{argRecipient, argAmount, argSequence, "order-{{sequence}}-{{amount}} for {{recipient}}", argRandom, "-3", "{{random}}"},
+		Value:    "5",
+	}
+	c, err := loadContractCall(cc, common.Address{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.contract != contract || c.value.Int64() != 5 || !c.usesAmount() {
+		t.Errorf("call = %+v, want the contract, a value of 5 and {{amount}} used", c)
+	}
+
+	var randomTypes []string
+	random := func(typ abi.Type) string {
+		randomTypes = append(randomTypes, typ.String())
+		return randomArg(typ, bytes.Repeat([]byte{0xab}, 32))
+	}
+	data, err := c.pack(callArgs{recipient: recipient, amount: big.NewInt(1234), sequence: 9, random: random})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data[:4], c.method.ID) {
+		t.Fatalf("calldata selector %x, want %x", data[:4], c.method.ID)
+	}
+	got, err := c.method.Inputs.Unpack(data[4:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0] != recipient {
+		t.Errorf("to = %v, want the recipient", got[0])
+	}
+	if got[1].(*big.Int).Int64() != 1234 {
+		t.Errorf("amount = %v, want 1234", got[1])
+	}
+	if got[2] != uint64(9) {
+		t.Errorf("seq = %v, want 9", got[2])
+	}
+	if want := "order-9-1234 for " + recipient.Hex(); got[3] != want {
+		t.Errorf("memo = %q, want %q", got[3], want)
+	}
+	if got[4] != [32]byte(bytes.Repeat([]byte{0xab}, 32)) {
+		t.Errorf("tag = %x, want the random bytes", got[4])
+	}
+	if got[5] != int8(-3) {
+		t.Errorf("delta = %v, want -3", got[5])
+	}
+	if got[6] != true {
+		t.Errorf("flag = %v, want the random bool", got[6])
+	}
+	if strings.Join(randomTypes, ",") != "bytes32,bool" {
+		t.Errorf("random values drawn for %v, want bytes32 and bool", randomTypes)
+	}
+}
+
+func TestLoadContractCallRejects(t *testing.T) {
+	path := writeCallABI(t, false)
+	token := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	tests := []struct {
+		name string
+		cc   *CallConfig
+	}{
+		{"no call", nil},
+		{"no contract", &CallConfig{ABIFile: path, Method: "ping", Args: []string{"1"}}},
+		{"bad contract", &CallConfig{Contract: "0x12", ABIFile: path, Method: "ping", Args: []string{"1"}}},
+		{"missing method", &CallConfig{Contract: token.Hex(), ABIFile: path, Method: "pong", Args: []string{"1"}}},
+		{"wrong arg count", &CallConfig{Contract: token.Hex(), ABIFile: path, Method: "ping"}},
+		{"bad literal", &CallConfig{Contract: token.Hex(), ABIFile: path, Method: "ping", Args: []string{"300"}}},
+		{"value to a non-payable method", &CallConfig{Contract: token.Hex(), ABIFile: path, Method: "ping", Args: []string{"1"}, Value: "1"}},
+		{"negative value", &CallConfig{Contract: token.Hex(), ABIFile: path, Method: "ping", Args: []string{"1"}, Value: "-1"}},
+		{"missing ABI", &CallConfig{Contract: token.Hex(), ABIFile: filepath.Join(t.TempDir(), "none.json"), Method: "ping", Args: []string{"1"}}},
+	}
+	for _, tt := range tests {
+		contract := token
+		if tt.name == "no contract" {
+			contract = common.Address{}
+		}
+		if _, err := loadContractCall(tt.cc, contract); err == nil {
+			t.Errorf("%s: accepted", tt.name)
+		}
+	}
+	// The call defaults to the ERC20 contract.
+	c, err := loadContractCall(&CallConfig{ABIFile: path, Method: "ping", Args: []string{"{{sequence}}"}}, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.contract != token || c.usesAmount() {
+		t.Errorf("call = %+v, want the token contract without {{amount}}", c)
+	}
+}
+
+func TestWorkloadMix(t *testing.T) {
+	contract := common.HexToAddress("0x4000000000000000000000000000000000000004")
+	config := TestConfig{
+		TxType:        TxTypeMixed,
+		Mix:           []TxWeight{{Type: "Native", Weight: 3}, {Type: TxTypeContractCall, Weight: 1}},
+		NativeAmounts: &AmountConfig{Distribution: DistFixed, Value: "0.001"},
+		Call:          &CallConfig{Contract: contract.Hex(), ABIFile: writeCallABI(t, false), Method: "tick", Args: []string{argSequence}},
+	}
+	w, err := newWorkload(context.Background(), nil, config, 18)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair := testCycler(1).pairs[0]
+	const draws = 4000
+	counts := map[string]int{}
+	var seq uint64
+	for i := 0; i < draws; i++ {
+		req, err := w.next(pair)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[req.txType]++
+		switch req.txType {
+		case TxTypeNative:
+			if req.to != pair.Recipient || req.value.String() != "1000000000000000" || len(req.data) != 0 {
+				t.Fatalf("native request %+v, want 0.001 ETH to the recipient", req)
+			}
+		case TxTypeContractCall:
+			if req.to != contract || req.value.Sign() != 0 || req.amount != nil {
+				t.Fatalf("call request %+v, want a 0-value call to the contract", req)
+			}
+			got, err := w.call.method.Inputs.Unpack(req.data[4:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got[0] != seq {
+				t.Fatalf("call sequence %v, want %d", got[0], seq)
+			}
+			seq++
+		default:
+			t.Fatalf("drew type %q", req.txType)
+		}
+	}
+	if share := float64(counts[TxTypeNative]) / draws; share < 0.7 || share > 0.8 {
+		t.Errorf("native share %.3f of %v, want about 0.75", share, counts)
+	}
+
+	for _, bad := range []TestConfig{
+		{TxType: TxTypeMixed},
+		{TxType: TxTypeMixed, Mix: []TxWeight{{Type: "swap", Weight: 1}}},
+		{TxType: TxTypeMixed, Mix: []TxWeight{{Type: TxTypeNative, Weight: 0}}},
+		{TxType: TxTypeMixed, Mix: []TxWeight{{Type: TxTypeNative, Weight: 1}, {Type: TxTypeContractCall, Weight: 1}}, Call: config.Call},
+		{TxType: "nft"},
+	} {
+		if _, err := newWorkload(context.Background(), nil, bad, 18); err == nil {
+			t.Errorf("newWorkload(%+v) succeeded, want an error", bad)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package ethload
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"tron_load/secrets"
+)
+
+// Client is the node API the runner needs. *ethclient.Client provides it
+// over RPC, and the simulated backend's client in process (see SimHarness).
+type Client interface {
+	bind.ContractBackend
+	ethereum.BlockNumberReader
+	ethereum.ChainReader
+	ethereum.ChainStateReader
+	ethereum.TransactionReader
+	ethereum.ChainIDReader
+	BlockReceipts(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) ([]*types.Receipt, error)
+	Close()
+}
+
+// chainProfile presets the connection and tx settings for a known network.
+type chainProfile struct {
+	rpcURL        string
+	chainID       uint64
+	feeMode       string
+	confirmations uint64
+}
+
+// chainProfiles are selected with the chain field of TestConfig. Fields set
+// explicitly in the config take precedence over the profile. local leaves the
+// chain ID to the node, as dev nodes differ (geth --dev 1337, anvil and
+// hardhat 31337).
+var chainProfiles = map[string]chainProfile{
+	"sepolia":     {rpcURL: "https://rpc.sepolia.org", chainID: 11155111, feeMode: FeeModeEIP1559, confirmations: 2},
+	"holesky":     {rpcURL: "https://ethereum-holesky-rpc.publicnode.com", chainID: 17000, feeMode: FeeModeEIP1559, confirmations: 2},
+	"bsc-testnet": {rpcURL: "https://data-seed-prebsc-1-s1.bnbchain.org:8545", chainID: 97, feeMode: FeeModeLegacy, confirmations: 3},
+	"local":       {rpcURL: "http://127.0.0.1:8545", feeMode: FeeModeEIP1559, confirmations: 1},
+}
+
+// defaultChain is used when the config names neither a chain nor an rpcUrl.
+const defaultChain = "sepolia"
+
+// node is a connected chain and the settings transactions are sent to it
+// with.
+type node struct {
+	client  Client
+	chainID *big.Int // signs every transaction
+	fees    feePolicy
+	gas     gasPolicy
+	limits  *gasEstimator
+	// confirmations is how many blocks (counting the one including it) must
+	// hold a transfer before its receipt counts.
+	confirmations uint64
+	out           io.Writer // progress messages; nil discards them
+}
+
+// newNode returns a node for client with config's fee and gas settings. The
+// chain ID is read by connect.
+func newNode(client Client, config TestConfig) (*node, error) {
+	n := &node{client: client, confirmations: max(config.Confirmations, 1)}
+	var err error
+	if n.gas, err = parseGasPolicy(config); err != nil {
+		return nil, err
+	}
+	if n.fees, err = parseFeePolicy(config); err != nil {
+		return nil, err
+	}
+	n.limits = newGasEstimator(n)
+	return n, nil
+}
+
+// applyChainProfile fills the fields config leaves empty from its chain
+// profile.
+func applyChainProfile(config *TestConfig) error {
+	name := strings.ToLower(strings.TrimSpace(config.Chain))
+	if name == "" {
+		if strings.TrimSpace(config.RPCURL) != "" {
+			return nil
+		}
+		name = defaultChain
+	}
+	if name == "dev" {
+		name = "local"
+	}
+	p, ok := chainProfiles[name]
+	if !ok {
+		names := make([]string, 0, len(chainProfiles))
+		for n := range chainProfiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown chain %q (want one of %s)", config.Chain, strings.Join(names, ", "))
+	}
+	config.Chain = name
+	if strings.TrimSpace(config.RPCURL) == "" {
+		config.RPCURL = p.rpcURL
+	}
+	if config.ChainID == 0 {
+		config.ChainID = p.chainID
+	}
+	if strings.TrimSpace(config.FeeMode) == "" {
+		config.FeeMode = p.feeMode
+	}
+	if config.Confirmations == 0 {
+		config.Confirmations = p.confirmations
+	}
+	return nil
+}
+
+// dialConfig connects to the rpcUrl of config, resolving it first when it is
+// a secret reference. A literal rpcUrl is registered for redaction too, since
+// it may carry a provider key that client errors would echo.
+func dialConfig(resolver *secrets.Resolver, config TestConfig) (Client, error) {
+	url, err := resolver.Resolve(config.RPCURL)
+	if err != nil {
+		return nil, fmt.Errorf("rpcUrl: %w", err)
+	}
+	c, err := ethclient.Dial(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to RPC: %w", err)
+	}
+	return c, nil
+}
+
+// openNode returns a connected node for client with config's settings,
+// printing its progress to out.
+func openNode(ctx context.Context, client Client, config TestConfig, out io.Writer) (*node, error) {
+	n, err := newNode(client, config)
+	if err != nil {
+		return nil, err
+	}
+	n.out = out
+	if err := n.connect(ctx, config); err != nil {
+		return nil, err
+	}
+	printf(n.out, "Connected to %s (chain ID %s)\n", config.RPCURL, n.chainID)
+	return n, nil
+}
+
+// connect reads the chain ID from the node and checks it against the one the
+// config expects, if any.
+func (n *node) connect(ctx context.Context, config TestConfig) error {
+	id, err := n.client.ChainID(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get chain ID: %w", err)
+	}
+	if config.ChainID != 0 && id.Cmp(new(big.Int).SetUint64(config.ChainID)) != 0 {
+		return fmt.Errorf("chain ID mismatch: config expects %d but the node reports %s", config.ChainID, id)
+	}
+	n.chainID = id
+	return nil
+}
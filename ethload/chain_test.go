@@ -0,0 +1,122 @@
+package ethload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestApplyChainProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  TestConfig
+		chain   string       // resolved chain name
+		want    chainProfile // the fields applyChainProfile fills
+		wantErr string
+	}{
+		{
+			name:   "default",
+			config: TestConfig{},
+			chain:  "sepolia",
+			want:   chainProfile{rpcURL: "https://rpc.sepolia.org", chainID: 11155111, feeMode: FeeModeEIP1559, confirmations: 2},
+		},
+		{
+			name:   "bsc testnet",
+			config: TestConfig{Chain: " BSC-Testnet "},
+			chain:  "bsc-testnet",
+			want:   chainProfile{rpcURL: "https://data-seed-prebsc-1-s1.bnbchain.org:8545", chainID: 97, feeMode: FeeModeLegacy, confirmations: 3},
+		},
+		{
+			name:   "dev is local, chain ID from the node",
+			config: TestConfig{Chain: "dev"},
+			chain:  "local",
+			want:   chainProfile{rpcURL: "http://127.0.0.1:8545", feeMode: FeeModeEIP1559, confirmations: 1},
+		},
+		{
+			name:   "explicit fields win",
+			config: TestConfig{Chain: "holesky", RPCURL: "http://holesky.example", ChainID: 5, FeeMode: FeeModeLegacy, Confirmations: 7},
+			chain:  "holesky",
+			want:   chainProfile{rpcURL: "http://holesky.example", chainID: 5, feeMode: FeeModeLegacy, confirmations: 7},
+		},
+		{
+			name:   "rpcUrl without a chain",
+			config: TestConfig{RPCURL: "http://node.example"},
+			want:   chainProfile{rpcURL: "http://node.example"},
+		},
+		{
+			name:    "unknown chain",
+			config:  TestConfig{Chain: "mainnet"},
+			wantErr: `unknown chain "mainnet" (want one of bsc-testnet, holesky, local, sepolia)`,
+		},
+	}
+	for _, tt := range tests {
+		config := tt.config
+		err := applyChainProfile(&config)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("%s: error %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		got := chainProfile{rpcURL: config.RPCURL, chainID: config.ChainID, feeMode: config.FeeMode, confirmations: config.Confirmations}
+		if config.Chain != tt.chain || got != tt.want {
+			t.Errorf("%s: chain %q %+v, want %q %+v", tt.name, config.Chain, got, tt.chain, tt.want)
+		}
+	}
+}
+
+// chainIDClient reports a fixed chain ID, or fails.
+type chainIDClient struct {
+	Client
+	id  int64
+	err error
+}
+
+func (c chainIDClient) ChainID(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(c.id), c.err
+}
+
+func TestConnect(t *testing.T) {
+	tests := []struct {
+		name    string
+		client  chainIDClient
+		want    uint64 // config chain ID
+		wantErr string
+	}{
+		{name: "matching", client: chainIDClient{id: 97}, want: 97},
+		{name: "any chain", client: chainIDClient{id: 31337}},
+		{name: "mismatch", client: chainIDClient{id: 1}, want: 11155111, wantErr: "chain ID mismatch: config expects 11155111 but the node reports 1"},
+		{name: "node error", client: chainIDClient{err: errors.New("connection refused")}, wantErr: "failed to get chain ID: connection refused"},
+	}
+	for _, tt := range tests {
+		config := TestConfig{RPCURL: "http://node.example", ChainID: tt.want}
+		var out bytes.Buffer
+		n, err := openNode(context.Background(), tt.client, config, &out)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("%s: error %v, want %q", tt.name, err, tt.wantErr)
+			}
+			if out.Len() != 0 {
+				t.Errorf("%s: printed %q before failing", tt.name, out.String())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if n.chainID.Int64() != tt.client.id {
+			t.Errorf("%s: chain ID %s, want the node's %d", tt.name, n.chainID, tt.client.id)
+		}
+		if !strings.Contains(out.String(), "Connected to http://node.example (chain ID ") {
+			t.Errorf("%s: output %q", tt.name, out.String())
+		}
+	}
+}
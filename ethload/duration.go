@@ -0,0 +1,85 @@
+package ethload
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultGracePeriod is how long in-flight transfers may keep waiting for
+// receipts once a timed run (or phase) has stopped starting new ones.
+const defaultGracePeriod = 2 * time.Minute
+
+// runTiming is the parsed duration/gracePeriod pair from TestConfig.
+type runTiming struct {
+	duration time.Duration // 0 for loopCount-bounded runs
+	grace    time.Duration
+}
+
+func parseRunTiming(config TestConfig) (runTiming, error) {
+	t := runTiming{grace: defaultGracePeriod}
+	if s := strings.TrimSpace(config.Duration); s != "" {
+		if len(config.Phases) > 0 {
+			return t, fmt.Errorf("duration and phases are mutually exclusive; phases carry their own durations")
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return t, fmt.Errorf("invalid duration %q", config.Duration)
+		}
+		t.duration = d
+	}
+	if s := strings.TrimSpace(config.GracePeriod); s != "" {
+		g, err := time.ParseDuration(s)
+		if err != nil || g < 0 {
+			return t, fmt.Errorf("invalid gracePeriod %q", config.GracePeriod)
+		}
+		t.grace = g
+	}
+	return t, nil
+}
+
+// transferContext derives the context for one transfer: the run's drain
+// deadline when the parent has one, otherwise transferTimeout plus the time
+// the replacement policy may spend re-broadcasting a stuck tx.
+func (r *Runner) transferContext(parent context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := parent.Deadline(); ok {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, transferTimeout+r.replacePolicy.after*time.Duration(r.replacePolicy.max))
+}
+
+// runForDuration keeps every pair sending back to back until deadline, with at
+// most maxGoroutines transfers in flight across all pairs. Transfers still in
+// flight at the deadline finish (or give up) under ctx, which should expire
+// one grace period after it.
+func runForDuration(ctx context.Context, pairs []transferPair, deadline time.Time, maxGoroutines int, delay time.Duration, send sendFunc) []TransferResult {
+	var results []TransferResult
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	sem := make(chan struct{}, maxGoroutines)
+	for _, pair := range pairs {
+		wg.Add(1)
+		go func(p transferPair) {
+			defer wg.Done()
+			for time.Now().Before(deadline) && ctx.Err() == nil {
+				sem <- struct{}{}
+				if !time.Now().Before(deadline) {
+					<-sem
+					return
+				}
+				r := send(ctx, p)
+				<-sem
+				mu.Lock()
+				results = append(results, r)
+				mu.Unlock()
+				if delay > 0 {
+					time.Sleep(delay)
+				}
+			}
+		}(pair)
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,165 @@
+package ethload
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Fee modes
+const (
+	FeeModeLegacy  = "legacy"  // gasPrice from SuggestGasPrice
+	FeeModeEIP1559 = "eip1559" // tip from SuggestGasTipCap on top of the head's base fee
+)
+
+const (
+	defaultTipMultiplier    = 1.0
+	defaultMaxFeeMultiplier = 2.0
+)
+
+// feePolicy is the parsed feeMode/tipMultiplier/maxFeeMultiplier from TestConfig.
+type feePolicy struct {
+	dynamic          bool
+	tipMultiplier    float64
+	maxFeeMultiplier float64
+}
+
+func parseFeePolicy(config TestConfig) (feePolicy, error) {
+	p := feePolicy{tipMultiplier: defaultTipMultiplier, maxFeeMultiplier: defaultMaxFeeMultiplier}
+	switch strings.ToLower(strings.TrimSpace(config.FeeMode)) {
+	case "", FeeModeLegacy:
+	case FeeModeEIP1559, "1559":
+		p.dynamic = true
+	default:
+		return p, fmt.Errorf("invalid feeMode %q (want %s or %s)", config.FeeMode, FeeModeLegacy, FeeModeEIP1559)
+	}
+	if config.TipMultiplier < 0 || config.MaxFeeMultiplier < 0 {
+		return p, fmt.Errorf("tipMultiplier and maxFeeMultiplier must not be negative")
+	}
+	if config.TipMultiplier > 0 {
+		p.tipMultiplier = config.TipMultiplier
+	}
+	if config.MaxFeeMultiplier > 0 {
+		if config.MaxFeeMultiplier < 1 {
+			return p, fmt.Errorf("maxFeeMultiplier must be at least 1 to cover the current base fee")
+		}
+		p.maxFeeMultiplier = config.MaxFeeMultiplier
+	}
+	return p, nil
+}
+
+// txFees is the fee part of a transaction: gasPrice for legacy txs, tipCap and
+// feeCap for EIP-1559 ones, which also carry the chain ID.
+type txFees struct {
+	gasPrice *big.Int
+	tipCap   *big.Int
+	feeCap   *big.Int
+	chainID  *big.Int
+}
+
+func (f txFees) dynamic() bool { return f.feeCap != nil }
+
+// suggestFees prices a new transaction per the node's fee policy. EIP-1559
+// fees are the suggested tip times tipMultiplier, and a fee cap of the latest
+// base fee times maxFeeMultiplier plus that tip.
+func (n *node) suggestFees(ctx context.Context) (txFees, error) {
+	if !n.fees.dynamic {
+		gasPrice, err := n.client.SuggestGasPrice(ctx)
+		if err != nil {
+			return txFees{}, fmt.Errorf("failed to get gas price: %w", err)
+		}
+		return txFees{gasPrice: gasPrice}, nil
+	}
+	tip, err := n.client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return txFees{}, fmt.Errorf("failed to get gas tip cap: %w", err)
+	}
+	head, err := n.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return txFees{}, fmt.Errorf("failed to get latest header: %w", err)
+	}
+	if head.BaseFee == nil {
+		return txFees{}, fmt.Errorf("chain has no base fee; use feeMode %q", FeeModeLegacy)
+	}
+	tip = mulFloat(tip, n.fees.tipMultiplier)
+	feeCap := new(big.Int).Add(mulFloat(head.BaseFee, n.fees.maxFeeMultiplier), tip)
+	return txFees{tipCap: tip, feeCap: feeCap, chainID: n.chainID}, nil
+}
+
+// feesOf returns the fees tx was signed with.
+func feesOf(tx *types.Transaction) txFees {
+	if tx.Type() == types.LegacyTxType {
+		return txFees{gasPrice: tx.GasPrice()}
+	}
+	return txFees{tipCap: tx.GasTipCap(), feeCap: tx.GasFeeCap(), chainID: tx.ChainId()}
+}
+
+// newTx builds an unsigned transaction carrying these fees.
+func (f txFees) newTx(nonce uint64, to *common.Address, value *big.Int, gas uint64, data []byte) *types.Transaction {
+	if f.dynamic() {
+		return types.NewTx(&types.DynamicFeeTx{ChainID: f.chainID, Nonce: nonce, To: to, Value: value, Gas: gas, GasTipCap: f.tipCap, GasFeeCap: f.feeCap, Data: data})
+	}
+	return types.NewTx(&types.LegacyTx{Nonce: nonce, To: to, Value: value, Gas: gas, GasPrice: f.gasPrice, Data: data})
+}
+
+// bump raises every fee by percent.
+func (f txFees) bump(percent int64) txFees {
+	scale := func(v *big.Int) *big.Int {
+		if v == nil {
+			return nil
+		}
+		out := new(big.Int).Mul(v, big.NewInt(100+percent))
+		return out.Div(out, big.NewInt(100))
+	}
+	return txFees{gasPrice: scale(f.gasPrice), tipCap: scale(f.tipCap), feeCap: scale(f.feeCap), chainID: f.chainID}
+}
+
+// atLeast returns f with every fee raised to at least the one in other.
+func (f txFees) atLeast(other txFees) txFees {
+	max := func(a, b *big.Int) *big.Int {
+		if a == nil || (b != nil && b.Cmp(a) > 0) {
+			return b
+		}
+		return a
+	}
+	return txFees{gasPrice: max(f.gasPrice, other.gasPrice), tipCap: max(f.tipCap, other.tipCap), feeCap: max(f.feeCap, other.feeCap), chainID: f.chainID}
+}
+
+// capped limits the price per gas (gasPrice or feeCap) to limit.
+func (f txFees) capped(limit *big.Int) txFees {
+	if limit == nil {
+		return f
+	}
+	if f.gasPrice != nil && f.gasPrice.Cmp(limit) > 0 {
+		f.gasPrice = new(big.Int).Set(limit)
+	}
+	if f.feeCap != nil && f.feeCap.Cmp(limit) > 0 {
+		f.feeCap = new(big.Int).Set(limit)
+		if f.tipCap.Cmp(f.feeCap) > 0 {
+			f.tipCap = new(big.Int).Set(f.feeCap)
+		}
+	}
+	return f
+}
+
+// covers reports whether every fee in f is at least the one in other.
+func (f txFees) covers(other txFees) bool {
+	ge := func(a, b *big.Int) bool { return b == nil || (a != nil && a.Cmp(b) >= 0) }
+	return ge(f.gasPrice, other.gasPrice) && ge(f.tipCap, other.tipCap) && ge(f.feeCap, other.feeCap)
+}
+
+func (f txFees) String() string {
+	if f.dynamic() {
+		return fmt.Sprintf("tip %s / max fee %s wei", f.tipCap, f.feeCap)
+	}
+	return fmt.Sprintf("%s wei", f.gasPrice)
+}
+
+func mulFloat(v *big.Int, m float64) *big.Int {
+	out, _ := new(big.Float).Mul(new(big.Float).SetInt(v), big.NewFloat(m)).Int(nil)
+	return out
+}
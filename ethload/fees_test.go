@@ -0,0 +1,88 @@
+package ethload
+
+import (
+	"math/big"
+	"testing"
+)
+
+// fee builds a txFees from wei amounts; -1 leaves a field nil.
+func fee(gasPrice, tipCap, feeCap int64) txFees {
+	v := func(n int64) *big.Int {
+		if n < 0 {
+			return nil
+		}
+		return big.NewInt(n)
+	}
+	return txFees{gasPrice: v(gasPrice), tipCap: v(tipCap), feeCap: v(feeCap)}
+}
+
+func feesEqual(a, b txFees) bool {
+	eq := func(x, y *big.Int) bool { return (x == nil) == (y == nil) && (x == nil || x.Cmp(y) == 0) }
+	return eq(a.gasPrice, b.gasPrice) && eq(a.tipCap, b.tipCap) && eq(a.feeCap, b.feeCap)
+}
+
+func TestTxFeesBump(t *testing.T) {
+	tests := []struct {
+		name    string
+		fees    txFees
+		percent int64
+		want    txFees
+	}{
+		{"legacy", fee(100, -1, -1), 10, fee(110, -1, -1)},
+		{"dynamic", fee(-1, 20, 200), 10, fee(-1, 22, 220)},
+		{"rounds down", fee(-1, 7, 15), 10, fee(-1, 7, 16)},
+		{"zero percent", fee(-1, 3, 9), 0, fee(-1, 3, 9)},
+		{"doubles", fee(50, -1, -1), 100, fee(100, -1, -1)},
+		{"zero fee stays zero", fee(0, -1, -1), 12, fee(0, -1, -1)},
+	}
+	for _, tt := range tests {
+		if got := tt.fees.bump(tt.percent); !feesEqual(got, tt.want) {
+			t.Errorf("%s: %v bumped %d%% = %v, want %v", tt.name, tt.fees, tt.percent, got, tt.want)
+		}
+	}
+}
+
+func TestTxFeesBumpKeepsChainIDAndOriginal(t *testing.T) {
+	f := fee(-1, 10, 100)
+	f.chainID = big.NewInt(5)
+	got := f.bump(50)
+	if got.chainID.Cmp(big.NewInt(5)) != 0 {
+		t.Errorf("chainID = %v, want 5", got.chainID)
+	}
+	if f.tipCap.Int64() != 10 || f.feeCap.Int64() != 100 {
+		t.Errorf("bump modified its receiver: %v", f)
+	}
+}
+
+func TestTxFeesCapped(t *testing.T) {
+	tests := []struct {
+		name  string
+		fees  txFees
+		limit *big.Int
+		want  txFees
+	}{
+		{"no limit", fee(500, -1, -1), nil, fee(500, -1, -1)},
+		{"legacy under limit", fee(90, -1, -1), big.NewInt(100), fee(90, -1, -1)},
+		{"legacy at limit", fee(100, -1, -1), big.NewInt(100), fee(100, -1, -1)},
+		{"legacy over limit", fee(150, -1, -1), big.NewInt(100), fee(100, -1, -1)},
+		{"dynamic under limit", fee(-1, 10, 90), big.NewInt(100), fee(-1, 10, 90)},
+		{"dynamic fee cap over limit", fee(-1, 10, 150), big.NewInt(100), fee(-1, 10, 100)},
+		{"dynamic tip over the capped fee cap", fee(-1, 120, 150), big.NewInt(100), fee(-1, 100, 100)},
+	}
+	for _, tt := range tests {
+		if got := tt.fees.capped(tt.limit); !feesEqual(got, tt.want) {
+			t.Errorf("%s: %v capped at %v = %v, want %v", tt.name, tt.fees, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestTxFeesCappedCopies(t *testing.T) {
+	f := fee(-1, 120, 150)
+	limit := big.NewInt(100)
+	got := f.capped(limit)
+	got.feeCap.SetInt64(1)
+	got.tipCap.SetInt64(1)
+	if limit.Int64() != 100 || f.tipCap.Int64() != 120 || f.feeCap.Int64() != 150 {
+		t.Errorf("capped shares big.Ints with its inputs: limit %v, fees %v", limit, f)
+	}
+}
@@ -0,0 +1,258 @@
+package ethload
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	defaultETHPerWallet = "0.01"
+	defaultFundingDir   = "wallets"
+	fundingTimeout      = 10 * time.Minute
+)
+
+// FundingConfig generates sender keys and funds them from a master key
+// before the run. The funded keys replace senderKeys.
+type FundingConfig struct {
+	Wallets         int    `json:"wallets"`         // sender keys to fund
+	MasterKey       string `json:"masterKey"`       // hex key that pays for the funding
+	ETHPerWallet    string `json:"ethPerWallet"`    // whole ETH each wallet is topped up to, for gas (default "0.01")
+	TokensPerWallet string `json:"tokensPerWallet"` // whole tokens each wallet is topped up to (default none)
+	KeystoreDir     string `json:"keystoreDir"`     // where the keys are saved encrypted (default "wallets")
+	PassphraseEnv   string `json:"passphraseEnv"`   // env var holding the keystore passphrase (default ETH_KEYSTORE_PASSPHRASE)
+	PassphraseFile  string `json:"passphraseFile"`  // file holding the passphrase, read when the env var is empty
+	Reuse           bool   `json:"reuse"`           // use the keys already in keystoreDir, generating only the shortfall
+}
+
+// fundingPlan is a validated FundingConfig.
+type fundingPlan struct {
+	wallets    int
+	master     *ecdsa.PrivateKey
+	eth        *big.Int // wei
+	tokens     *big.Int // token base units; zero sends none
+	token      common.Address
+	dir        string
+	passphrase string
+	reuse      bool
+}
+
+func parseFunding(config TestConfig, decimals int) (*fundingPlan, error) {
+	fc := config.Funding
+	if fc.Wallets <= 0 {
+		return nil, fmt.Errorf("funding.wallets must be positive")
+	}
+	master, _, err := loadPrivateKey(fc.MasterKey)
+	if err != nil {
+		return nil, fmt.Errorf("funding.masterKey: %w", err)
+	}
+	p := &fundingPlan{wallets: fc.Wallets, master: master, tokens: new(big.Int), token: tokenAddress(config), dir: fc.KeystoreDir, reuse: fc.Reuse}
+	ethAmount := fc.ETHPerWallet
+	if strings.TrimSpace(ethAmount) == "" {
+		ethAmount = defaultETHPerWallet
+	}
+	if p.eth, err = parseWholeUnits(ethAmount, nativeDecimals); err != nil {
+		return nil, fmt.Errorf("funding.ethPerWallet: %w", err)
+	}
+	if strings.TrimSpace(fc.TokensPerWallet) != "" {
+		if p.tokens, err = parseWholeUnits(fc.TokensPerWallet, decimals); err != nil {
+			return nil, fmt.Errorf("funding.tokensPerWallet: %w", err)
+		}
+		if p.token == (common.Address{}) {
+			return nil, fmt.Errorf("funding.tokensPerWallet needs erc20Contract")
+		}
+	}
+	if p.dir == "" {
+		p.dir = defaultFundingDir
+	}
+	if p.passphrase, err = readPassphrase(fc.PassphraseEnv, fc.PassphraseFile); err != nil {
+		return nil, fmt.Errorf("funding: %w", err)
+	}
+	return p, nil
+}
+
+// parseWholeUnits converts a decimal amount of whole units to base units.
+func parseWholeUnits(s string, decimals int) (*big.Int, error) {
+	return (&amountPicker{decimals: decimals, precision: decimals}).parse(s)
+}
+
+// fundSenders loads or generates the funding wallets, tops each up to the
+// configured ETH and token balance from the master key, waits for the
+// top-ups to be confirmed and makes the wallets config's senders.
+func (n *node) fundSenders(ctx context.Context, config *TestConfig) error {
+	decimals := config.Decimals
+	if decimals <= 0 {
+		decimals = 18
+	}
+	plan, err := parseFunding(*config, decimals)
+	if err != nil {
+		return err
+	}
+	keys, err := plan.loadWallets(n.out)
+	if err != nil {
+		return err
+	}
+
+	// Work out the top-ups first, so a short master fails before sending.
+	type topUp struct {
+		to          common.Address
+		eth, tokens *big.Int
+	}
+	var topUps []topUp
+	needETH, needTokens := new(big.Int), new(big.Int)
+	for _, key := range keys {
+		addr := crypto.PubkeyToAddress(key.PublicKey)
+		t := topUp{to: addr, eth: new(big.Int), tokens: new(big.Int)}
+		balance, err := n.client.BalanceAt(ctx, addr, nil)
+		if err != nil {
+			return fmt.Errorf("failed to get ETH balance of %s: %w", addr.Hex(), err)
+		}
+		if balance.Cmp(plan.eth) < 0 {
+			t.eth.Sub(plan.eth, balance)
+		}
+		if plan.tokens.Sign() > 0 {
+			balance, err := n.tokenBalance(ctx, plan.token, addr)
+			if err != nil {
+				return err
+			}
+			if balance.Cmp(plan.tokens) < 0 {
+				t.tokens.Sub(plan.tokens, balance)
+			}
+		}
+		needETH.Add(needETH, t.eth)
+		needTokens.Add(needTokens, t.tokens)
+		if t.eth.Sign() > 0 || t.tokens.Sign() > 0 {
+			topUps = append(topUps, t)
+		}
+	}
+
+	master := crypto.PubkeyToAddress(plan.master.PublicKey)
+	if len(topUps) > 0 {
+		printf(n.out, "Funding %d of %d wallet(s) from %s: %s wei and %s token units\n", len(topUps), len(keys), master.Hex(), needETH, needTokens)
+		if err := n.checkETHBalance(ctx, master, needETH); err != nil {
+			return fmt.Errorf("master %s: %w", master.Hex(), err)
+		}
+		if needTokens.Sign() > 0 {
+			if err := n.checkBalance(ctx, master, plan.token, needTokens); err != nil {
+				return fmt.Errorf("master %s: %w", master.Hex(), err)
+			}
+		}
+	}
+
+	nonce, err := n.client.PendingNonceAt(ctx, master)
+	if err != nil {
+		return fmt.Errorf("failed to get master nonce: %w", err)
+	}
+	fees, err := n.suggestFees(ctx)
+	if err != nil {
+		return err
+	}
+	var sent []common.Hash
+	send := func(to common.Address, value *big.Int, gas uint64, data []byte) error {
+		signed, err := n.sendTransfer(ctx, plan.master, fees.newTx(nonce, &to, value, gas, data))
+		if err != nil {
+			return err
+		}
+		nonce++
+		sent = append(sent, signed.Hash())
+		return nil
+	}
+	for _, t := range topUps {
+		if t.eth.Sign() > 0 {
+			if err := send(t.to, t.eth, 21000, nil); err != nil {
+				return fmt.Errorf("funding %s with ETH: %w", t.to.Hex(), err)
+			}
+		}
+		if t.tokens.Sign() > 0 {
+			data, err := erc20TransferData(t.to, t.tokens)
+			if err != nil {
+				return err
+			}
+			req := txRequest{txType: TxTypeERC20, recipient: t.to, to: plan.token, value: new(big.Int), data: data}
+			gas, err := n.limits.estimate(ctx, master, req)
+			if err != nil {
+				return fmt.Errorf("funding %s with tokens: %w", t.to.Hex(), err)
+			}
+			if err := send(plan.token, new(big.Int), gas, data); err != nil {
+				return fmt.Errorf("funding %s with tokens: %w", t.to.Hex(), err)
+			}
+		}
+	}
+	for _, hash := range sent {
+		receipt, err := n.waitConfirmed(ctx, hash)
+		if err != nil {
+			return fmt.Errorf("funding: %w", err)
+		}
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			return fmt.Errorf("funding tx %s reverted", hash.Hex())
+		}
+	}
+	if len(sent) > 0 {
+		printf(n.out, "Funding confirmed: %d tx(s)\n", len(sent))
+	}
+
+	config.SenderKeys = nil
+	for _, key := range keys {
+		config.SenderKeys = append(config.SenderKeys, registerKey(key))
+	}
+	return nil
+}
+
+// loadWallets returns plan.wallets keys: the ones in the keystore when
+// reusing, then freshly generated ones, which are saved to the keystore.
+func (p *fundingPlan) loadWallets(out io.Writer) ([]*ecdsa.PrivateKey, error) {
+	ks := keystore.NewKeyStore(p.dir, keystore.LightScryptN, keystore.LightScryptP)
+	var keys []*ecdsa.PrivateKey
+	if p.reuse {
+		var err error
+		if keys, err = decryptKeystore(p.dir, p.passphrase, p.wallets); err != nil {
+			return nil, err
+		}
+		printf(out, "Reusing %d wallet(s) from %s\n", len(keys), p.dir)
+	}
+	generated := 0
+	for len(keys) < p.wallets {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := ks.ImportECDSA(key, p.passphrase); err != nil {
+			return nil, fmt.Errorf("failed to save wallet: %w", err)
+		}
+		keys = append(keys, key)
+		generated++
+	}
+	if generated > 0 {
+		printf(out, "Generated %d wallet(s), saved encrypted to %s\n", generated, p.dir)
+	}
+	return keys, nil
+}
+
+// waitConfirmed waits until the tx hash has been mined and is n.confirmations
+// blocks deep.
+func (n *node) waitConfirmed(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	receipt, err := n.waitMined(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		head, err := n.client.BlockNumber(ctx)
+		if err == nil && head+1 >= receipt.BlockNumber.Uint64()+n.confirmations {
+			return receipt, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("tx %s not confirmed: %w", hash.Hex(), ctx.Err())
+		case <-time.After(receiptPoll / 4):
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package ethload
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestParseFundingRejects(t *testing.T) {
+	t.Setenv("ETH_KEYSTORE_PASSPHRASE", "secret")
+	token := "0x2000000000000000000000000000000000000002"
+	tests := []struct {
+		name    string
+		config  TestConfig
+		wantErr string
+	}{
+		{"no wallets", TestConfig{Funding: &FundingConfig{MasterKey: testKeyHex()}}, "wallets must be positive"},
+		{"bad master", TestConfig{Funding: &FundingConfig{Wallets: 1, MasterKey: "0x12"}}, "funding.masterKey"},
+		{"bad ETH amount", TestConfig{Funding: &FundingConfig{Wallets: 1, MasterKey: testKeyHex(), ETHPerWallet: "lots"}}, "funding.ethPerWallet"},
+		{"tokens without a token", TestConfig{Funding: &FundingConfig{Wallets: 1, MasterKey: testKeyHex(), TokensPerWallet: "5"}}, "needs erc20Contract"},
+		{"bad token amount", TestConfig{ERC20Contract: token, Funding: &FundingConfig{Wallets: 1, MasterKey: testKeyHex(), TokensPerWallet: "0"}}, "funding.tokensPerWallet"},
+		{"no passphrase", TestConfig{Funding: &FundingConfig{Wallets: 1, MasterKey: testKeyHex(), PassphraseEnv: "FUNDING_TEST_UNSET"}}, "set FUNDING_TEST_UNSET"},
+	}
+	for _, tt := range tests {
+		if _, err := parseFunding(tt.config, 18); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+
+	p, err := parseFunding(TestConfig{Funding: &FundingConfig{Wallets: 2, MasterKey: testKeyHex()}}, 18)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.eth.String() != "10000000000000000" || p.tokens.Sign() != 0 || p.dir != defaultFundingDir || p.passphrase != "secret" {
+		t.Errorf("plan %+v, want 0.01 ETH, no tokens, the default dir and the env passphrase", p)
+	}
+}
+
+func TestFundSenders(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs a simulated chain")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	h, err := NewSimHarness(ctx, SimOptions{Senders: 1, Recipients: 1, BlockTime: 50 * time.Millisecond, Supply: big.NewInt(1000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	n, err := newNode(h.client, TestConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.connect(ctx, TestConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ETH_KEYSTORE_PASSPHRASE", "secret")
+	_, master, _ := loadPrivateKey(h.senderKeys[0])
+	dir := t.TempDir()
+	config := TestConfig{
+		ERC20Contract: h.token.Hex(),
+		Decimals:      testTokenDecimals,
+		Funding:       &FundingConfig{Wallets: 2, MasterKey: h.senderKeys[0], ETHPerWallet: "0.5", TokensPerWallet: "10", KeystoreDir: dir},
+	}
+	halfETH := new(big.Int).Div(big.NewInt(params.Ether), big.NewInt(2))
+	tenTokens := new(big.Int).Mul(big.NewInt(10), big.NewInt(params.Ether))
+	// checkFunded checks that every sender of config holds exactly the
+	// configured top-up.
+	checkFunded := func(config TestConfig) []common.Address {
+		t.Helper()
+		var addrs []common.Address
+		for _, k := range config.SenderKeys {
+			_, addr, err := loadPrivateKey(k)
+			if err != nil {
+				t.Fatal(err)
+			}
+			addrs = append(addrs, addr)
+		}
+		snap, err := n.snapshotBalances(ctx, h.token, addrs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, a := range addrs {
+			if snap[a].eth.Cmp(halfETH) != 0 || snap[a].token.Cmp(tenTokens) != 0 {
+				t.Errorf("%s holds %s wei and %s tokens, want %s and %s", a.Hex(), snap[a].eth, snap[a].token, halfETH, tenTokens)
+			}
+		}
+		return addrs
+	}
+
+	if err := n.fundSenders(ctx, &config); err != nil {
+		t.Fatal(err)
+	}
+	if len(config.SenderKeys) != 2 {
+		t.Fatalf("%d sender keys after funding, want 2", len(config.SenderKeys))
+	}
+	funded := checkFunded(config)
+	nonce, err := h.client.PendingNonceAt(ctx, master)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reusing the keystore tops up only the new wallet: one ETH and one
+	// token transfer.
+	config.Funding.Wallets, config.Funding.Reuse = 3, true
+	if err := n.fundSenders(ctx, &config); err != nil {
+		t.Fatal(err)
+	}
+	reused := checkFunded(config)
+	if len(reused) != 3 || !sameAddresses(reused[:2], funded) {
+		t.Errorf("senders %v after reuse, want %v plus one", reused, funded)
+	}
+	if after, err := h.client.PendingNonceAt(ctx, master); err != nil || after != nonce+2 {
+		t.Errorf("master nonce %d after reuse (%v), want %d: only the new wallet funded", after, err, nonce+2)
+	}
+
+	// A master that cannot cover the top-ups fails before sending anything.
+	config.Funding.Wallets, config.Funding.ETHPerWallet = 4, "100000"
+	config.Funding.KeystoreDir = t.TempDir()
+	if err := n.fundSenders(ctx, &config); err == nil || !strings.Contains(err.Error(), "insufficient ETH balance") {
+		t.Errorf("short master: %v, want insufficient ETH", err)
+	}
+	if after, _ := h.client.PendingNonceAt(ctx, master); after != nonce+2 {
+		t.Errorf("master nonce %d after a failed funding, want %d", after, nonce+2)
+	}
+}
+
+// sameAddresses reports whether a and b hold the same addresses in any order.
+func sameAddresses(a, b []common.Address) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[common.Address]bool, len(a))
+	for _, x := range a {
+		seen[x] = true
+	}
+	for _, x := range b {
+		if !seen[x] {
+			return false
+		}
+	}
+	return true
+}
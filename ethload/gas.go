@@ -0,0 +1,197 @@
+package ethload
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const defaultGasMultiplier = 1.2
+
+// gasPolicy is the parsed gasLimit/gasMultiplier/estimateEveryTransfer from
+// TestConfig.
+type gasPolicy struct {
+	fixed      uint64 // used as is when set; no estimation
+	multiplier float64
+	cache      bool
+}
+
+func parseGasPolicy(config TestConfig) (gasPolicy, error) {
+	p := gasPolicy{fixed: config.GasLimit, multiplier: defaultGasMultiplier, cache: !config.EstimateEveryTransfer}
+	if config.GasMultiplier != 0 {
+		if config.GasMultiplier < 1 {
+			return p, fmt.Errorf("gasMultiplier must be at least 1")
+		}
+		p.multiplier = config.GasMultiplier
+	}
+	return p, nil
+}
+
+// recipientKind is what makes a token transfer cheaper or dearer on the
+// recipient side: contract recipients may run hooks, and crediting an empty
+// balance writes a fresh storage slot.
+type recipientKind struct {
+	contract bool
+	empty    bool
+}
+
+func (k recipientKind) String() string {
+	s := "eoa"
+	if k.contract {
+		s = "contract"
+	}
+	if k.empty {
+		s = "empty " + s
+	}
+	return s
+}
+
+// gasKey groups transactions expected to cost the same gas: by type, the
+// contract called (the token for erc20), and the recipient kind.
+type gasKey struct {
+	txType string
+	to     common.Address // zero for native transfers
+	kind   recipientKind
+}
+
+func (k gasKey) String() string {
+	switch k.txType {
+	case TxTypeNative:
+		return fmt.Sprintf("native transfers (%s recipient)", k.kind)
+	case TxTypeContractCall:
+		return fmt.Sprintf("calls to %s", k.to.Hex())
+	case TxTypeERC721, TxTypeERC1155:
+		return fmt.Sprintf("%s %s (%s recipient)", k.txType, k.to.Hex(), k.kind)
+	}
+	return fmt.Sprintf("%s (%s recipient)", k.to.Hex(), k.kind)
+}
+
+// gasEstimator sizes transfer gas limits on a node with EstimateGas, caching
+// the result per gasKey.
+type gasEstimator struct {
+	node      *node
+	mu        sync.Mutex
+	limits    map[gasKey]uint64
+	contracts map[common.Address]bool // recipient -> has code
+}
+
+func newGasEstimator(n *node) *gasEstimator {
+	return &gasEstimator{node: n, limits: make(map[gasKey]uint64), contracts: make(map[common.Address]bool)}
+}
+
+// limit returns the gas limit for req sent from from: the policy's fixed limit
+// if set, otherwise the estimate times the multiplier. Contract calls share
+// one estimate per contract, whatever their arguments.
+func (g *gasEstimator) limit(ctx context.Context, from common.Address, req txRequest) (uint64, error) {
+	if g.node.gas.fixed > 0 {
+		return g.node.gas.fixed, nil
+	}
+	if !g.node.gas.cache {
+		return g.estimate(ctx, from, req)
+	}
+	key, err := g.key(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+	g.mu.Lock()
+	limit, ok := g.limits[key]
+	g.mu.Unlock()
+	if ok {
+		return limit, nil
+	}
+	limit, err = g.estimate(ctx, from, req)
+	if err != nil {
+		return 0, err
+	}
+	g.mu.Lock()
+	if _, raced := g.limits[key]; !raced {
+		g.limits[key] = limit
+		printf(g.node.out, "Gas limit for %s: %d\n", key, limit)
+	}
+	g.mu.Unlock()
+	return limit, nil
+}
+
+func (g *gasEstimator) key(ctx context.Context, req txRequest) (gasKey, error) {
+	key := gasKey{txType: req.txType, to: req.to}
+	var err error
+	switch req.txType {
+	case TxTypeERC20:
+		key.kind, err = g.recipientKind(ctx, req.to, req.recipient)
+	case TxTypeNative:
+		// Plain value transfers cost the same to any EOA; only code can change it.
+		key.to = common.Address{}
+		key.kind.contract, err = g.isContract(ctx, req.recipient)
+	case TxTypeERC721, TxTypeERC1155:
+		// safeTransferFrom calls onERC721Received/onERC1155Received on
+		// contract recipients, and a first token writes a fresh balance slot.
+		if key.kind.contract, err = g.isContract(ctx, req.recipient); err != nil {
+			return key, err
+		}
+		var balance *big.Int
+		if req.txType == TxTypeERC721 {
+			balance, err = g.node.erc721Balance(ctx, req.to, req.recipient)
+		} else {
+			balance, err = g.node.erc1155Balance(ctx, req.to, req.recipient, req.tokenID)
+		}
+		if err != nil {
+			return key, err
+		}
+		key.kind.empty = balance.Sign() == 0
+	}
+	return key, err
+}
+
+func (g *gasEstimator) estimate(ctx context.Context, from common.Address, req txRequest) (uint64, error) {
+	gas, err := g.node.client.EstimateGas(ctx, ethereum.CallMsg{From: from, To: &req.to, Value: req.value, Data: req.data})
+	if err != nil {
+		return 0, fmt.Errorf("gas estimation failed: %w", err)
+	}
+	return uint64(math.Ceil(float64(gas) * g.node.gas.multiplier)), nil
+}
+
+func (g *gasEstimator) isContract(ctx context.Context, addr common.Address) (bool, error) {
+	g.mu.Lock()
+	contract, ok := g.contracts[addr]
+	g.mu.Unlock()
+	if ok {
+		return contract, nil
+	}
+	code, err := g.node.client.CodeAt(ctx, addr, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to get recipient code: %w", err)
+	}
+	contract = len(code) > 0
+	g.mu.Lock()
+	g.contracts[addr] = contract
+	g.mu.Unlock()
+	return contract, nil
+}
+
+func (g *gasEstimator) recipientKind(ctx context.Context, token, to common.Address) (recipientKind, error) {
+	contract, err := g.isContract(ctx, to)
+	if err != nil {
+		return recipientKind{}, err
+	}
+	balance, err := g.node.tokenBalance(ctx, token, to)
+	if err != nil {
+		return recipientKind{}, err
+	}
+	return recipientKind{contract: contract, empty: balance.Sign() == 0}, nil
+}
+
+// erc20TransferData packs the calldata of transfer(to, amount).
+func erc20TransferData(to common.Address, amount *big.Int) ([]byte, error) {
+	parsedABI, err := abi.JSON(strings.NewReader(erc20ABI))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ABI: %w", err)
+	}
+	return parsedABI.Pack("transfer", to, amount)
+}
@@ -0,0 +1,169 @@
+package ethload
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// gasChain answers the calls a gasEstimator makes: gas estimates, recipient
+// code and token balances.
+type gasChain struct {
+	Client
+	mu        sync.Mutex
+	estimates int // EstimateGas calls
+	codeReads int // CodeAt calls
+	contracts map[common.Address]bool
+	balances  map[common.Address]int64 // token balances
+}
+
+func (c *gasChain) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.estimates++
+	if len(msg.Data) == 0 {
+		return 21000, nil
+	}
+	return 50000, nil
+}
+
+func (c *gasChain) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.codeReads++
+	if c.contracts[account] {
+		return []byte{0x60}, nil
+	}
+	return nil, nil
+}
+
+func (c *gasChain) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	// balanceOf(address): the owner is the last 20 bytes of the argument.
+	owner := common.BytesToAddress(msg.Data[4:36])
+	return common.LeftPadBytes(big.NewInt(c.balances[owner]).Bytes(), 32), nil
+}
+
+func TestParseGasPolicy(t *testing.T) {
+	p, err := parseGasPolicy(TestConfig{})
+	if err != nil || p.fixed != 0 || p.multiplier != defaultGasMultiplier || !p.cache {
+		t.Errorf("default policy %+v (%v), want cached estimates times %v", p, err, defaultGasMultiplier)
+	}
+	p, err = parseGasPolicy(TestConfig{GasLimit: 90000, GasMultiplier: 1.5, EstimateEveryTransfer: true})
+	if err != nil || p.fixed != 90000 || p.multiplier != 1.5 || p.cache {
+		t.Errorf("policy %+v (%v), want the configured fields", p, err)
+	}
+	if _, err := parseGasPolicy(TestConfig{GasMultiplier: 0.9}); err == nil || !strings.Contains(err.Error(), "at least 1") {
+		t.Errorf("multiplier below 1: %v, want rejected", err)
+	}
+}
+
+func TestGasEstimatorCache(t *testing.T) {
+	token := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	target := common.HexToAddress("0x3000000000000000000000000000000000000003")
+	eoa := common.HexToAddress("0x4000000000000000000000000000000000000004")
+	funded := common.HexToAddress("0x5000000000000000000000000000000000000005")
+	hook := common.HexToAddress("0x6000000000000000000000000000000000000006")
+	from := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	chain := &gasChain{
+		contracts: map[common.Address]bool{token: true, target: true, hook: true},
+		balances:  map[common.Address]int64{funded: 5},
+	}
+	n, err := newNode(chain, TestConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	n.out = &out
+	ctx := context.Background()
+	erc20 := func(to common.Address) txRequest {
+		data, err := erc20TransferData(to, big.NewInt(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return txRequest{txType: TxTypeERC20, recipient: to, to: token, value: new(big.Int), data: data}
+	}
+	call := func(arg byte) txRequest {
+		return txRequest{txType: TxTypeContractCall, recipient: target, to: target, value: new(big.Int), data: []byte{1, 2, 3, 4, arg}}
+	}
+	native := func(to common.Address) txRequest {
+		return txRequest{txType: TxTypeNative, recipient: to, to: to, value: big.NewInt(1)}
+	}
+
+	tests := []struct {
+		name      string
+		req       txRequest
+		want      uint64
+		estimates int // EstimateGas calls so far
+	}{
+		{"native to an EOA", native(eoa), 25200, 1},
+		{"native to another EOA", native(funded), 25200, 1},
+		{"native to a contract", native(hook), 25200, 2},
+		{"erc20 to an empty EOA", erc20(eoa), 60000, 3},
+		{"erc20 to a funded EOA", erc20(funded), 60000, 4},
+		{"erc20 to an empty EOA again", erc20(eoa), 60000, 4},
+		{"erc20 to an empty contract", erc20(hook), 60000, 5},
+		{"contract call", call(1), 60000, 6},
+		{"contract call, other arguments", call(2), 60000, 6},
+	}
+	for _, tt := range tests {
+		got, err := n.limits.limit(ctx, from, tt.req)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want || chain.estimates != tt.estimates {
+			t.Errorf("%s: limit %d after %d estimates, want %d after %d", tt.name, got, chain.estimates, tt.want, tt.estimates)
+		}
+	}
+	// Each key is announced once, when first estimated.
+	if lines := strings.Count(out.String(), "Gas limit for "); lines != 6 {
+		t.Errorf("%d gas limit lines, want one per key:\n%s", lines, out.String())
+	}
+	if !strings.Contains(out.String(), "Gas limit for "+token.Hex()+" (empty eoa recipient): 60000\n") {
+		t.Errorf("output\n%s\nwant the erc20 key and limit", out.String())
+	}
+	// Recipient code is read once per address; contract calls need none.
+	if chain.codeReads != 3 {
+		t.Errorf("%d code reads, want one per recipient", chain.codeReads)
+	}
+}
+
+func TestGasEstimatorPolicy(t *testing.T) {
+	from := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	to := common.HexToAddress("0x4000000000000000000000000000000000000004")
+	req := txRequest{txType: TxTypeNative, recipient: to, to: to, value: big.NewInt(1)}
+	tests := []struct {
+		name      string
+		config    TestConfig
+		want      uint64
+		estimates int // after three transfers
+	}{
+		{"fixed limit", TestConfig{GasLimit: 30000, GasMultiplier: 2}, 30000, 0},
+		{"every transfer", TestConfig{EstimateEveryTransfer: true}, 25200, 3},
+		{"multiplier", TestConfig{GasMultiplier: 1.5}, 31500, 1},
+	}
+	for _, tt := range tests {
+		chain := &gasChain{}
+		n, err := newNode(chain, tt.config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for range 3 {
+			got, err := n.limits.limit(context.Background(), from, req)
+			if err != nil || got != tt.want {
+				t.Errorf("%s: limit %d (%v), want %d", tt.name, got, err, tt.want)
+			}
+		}
+		if chain.estimates != tt.estimates {
+			t.Errorf("%s: %d estimates, want %d", tt.name, chain.estimates, tt.estimates)
+		}
+		if tt.config.GasLimit > 0 && chain.codeReads != 0 {
+			t.Errorf("%s: %d code reads, want none", tt.name, chain.codeReads)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package ethload
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+)
+
+const defaultPassphraseEnv = "ETH_KEYSTORE_PASSPHRASE"
+
+// KeystoreConfig points at encrypted (Web3 Secret Storage) sender keys. The
+// keys are decrypted into memory only.
+type KeystoreConfig struct {
+	Dir            string   `json:"dir"`            // keystore directory; every key in it is a sender
+	Files          []string `json:"files"`          // UTC--... key files, after the ones in dir
+	PassphraseEnv  string   `json:"passphraseEnv"`  // env var holding the passphrase (default ETH_KEYSTORE_PASSPHRASE)
+	PassphraseFile string   `json:"passphraseFile"` // file holding the passphrase, read when the env var is empty
+}
+
+// keys decrypts the keys kc points at.
+func (kc *KeystoreConfig) keys() ([]*ecdsa.PrivateKey, error) {
+	if kc.Dir == "" && len(kc.Files) == 0 {
+		return nil, fmt.Errorf("set dir or files")
+	}
+	passphrase, err := readPassphrase(kc.PassphraseEnv, kc.PassphraseFile)
+	if err != nil {
+		return nil, err
+	}
+	var keys []*ecdsa.PrivateKey
+	if kc.Dir != "" {
+		if keys, err = decryptKeystore(kc.Dir, passphrase, 0); err != nil {
+			return nil, err
+		}
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("no keys in %s", kc.Dir)
+		}
+	}
+	for _, path := range kc.Files {
+		key, err := decryptKeyFile(path, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// readPassphrase returns the value of the env var env (default
+// ETH_KEYSTORE_PASSPHRASE) or, when that is empty, the contents of file
+// without the trailing newline.
+func readPassphrase(env, file string) (string, error) {
+	if env == "" {
+		env = defaultPassphraseEnv
+	}
+	if passphrase := os.Getenv(env); passphrase != "" {
+		return passphrase, nil
+	}
+	if file == "" {
+		return "", fmt.Errorf("set %s to the keystore passphrase, or give a passphrase file", env)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	passphrase := strings.TrimRight(string(data), "\r\n")
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase file %s is empty", file)
+	}
+	return passphrase, nil
+}
+
+// decryptKeystore decrypts up to limit keys (all when limit is 0) of the
+// keystore directory dir, in file name order.
+func decryptKeystore(dir, passphrase string, limit int) ([]*ecdsa.PrivateKey, error) {
+	ks := keystore.NewKeyStore(dir, keystore.LightScryptN, keystore.LightScryptP)
+	var keys []*ecdsa.PrivateKey
+	for _, acc := range ks.Accounts() {
+		if limit > 0 && len(keys) == limit {
+			break
+		}
+		key, err := decryptKeyFile(acc.URL.Path, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// decryptKeyFile decrypts the Web3 Secret Storage key file at path.
+func decryptKeyFile(path, passphrase string) (*ecdsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keystore: %w", err)
+	}
+	key, err := keystore.DecryptKey(data, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", path, err)
+	}
+	return key.PrivateKey, nil
+}
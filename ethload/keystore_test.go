@@ -0,0 +1,133 @@
+package ethload
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func sameKeys(a, b []*ecdsa.PrivateKey) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[string]bool, len(a))
+	for _, k := range a {
+		seen[string(crypto.FromECDSA(k))] = true
+	}
+	for _, k := range b {
+		if !seen[string(crypto.FromECDSA(k))] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestKeystoreRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	plan := &fundingPlan{wallets: 2, dir: dir, passphrase: "correct horse"}
+	saved, err := plan.loadWallets(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "UTC--*"))
+	if err != nil || len(files) != 2 {
+		t.Fatalf("keystore holds %v (%v), want 2 key files", files, err)
+	}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, k := range saved {
+			if strings.Contains(string(data), hex.EncodeToString(crypto.FromECDSA(k))) {
+				t.Fatalf("%s holds a plaintext key", f)
+			}
+		}
+	}
+
+	t.Setenv("ETH_KEYSTORE_PASSPHRASE", "correct horse")
+	fromDir, err := (&KeystoreConfig{Dir: dir}).keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameKeys(fromDir, saved) {
+		t.Error("keys decrypted from the directory differ from the ones saved")
+	}
+	fromFiles, err := (&KeystoreConfig{Files: files}).keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameKeys(fromFiles, saved) {
+		t.Error("keys decrypted from the files differ from the ones saved")
+	}
+
+	// Reusing the keystore keeps the saved wallets and tops up the rest.
+	plan = &fundingPlan{wallets: 3, dir: dir, passphrase: "correct horse", reuse: true}
+	reused, err := plan.loadWallets(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reused) != 3 || !sameKeys(reused[:2], saved) {
+		t.Errorf("reused %d wallets, want the 2 saved plus 1 new", len(reused))
+	}
+
+	t.Setenv("ETH_KEYSTORE_PASSPHRASE", "wrong horse")
+	if _, err := (&KeystoreConfig{Dir: dir}).keys(); err == nil || !strings.Contains(err.Error(), "failed to decrypt") {
+		t.Errorf("wrong passphrase: %v, want a decryption failure", err)
+	}
+	if _, err := (&KeystoreConfig{Dir: t.TempDir()}).keys(); err == nil || !strings.Contains(err.Error(), "no keys in") {
+		t.Errorf("empty directory: %v, want no keys", err)
+	}
+	if _, err := (&KeystoreConfig{}).keys(); err == nil {
+		t.Error("no dir or files: accepted")
+	}
+}
+
+func TestReadPassphrase(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "passphrase")
+	if err := os.WriteFile(file, []byte("from file\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	empty := filepath.Join(dir, "empty")
+	if err := os.WriteFile(empty, []byte("\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KEYSTORE_TEST_PASSPHRASE", "from env")
+	t.Setenv("KEYSTORE_TEST_EMPTY", "")
+
+	tests := []struct {
+		name       string
+		defaultEnv string // value of ETH_KEYSTORE_PASSPHRASE
+		env, file  string
+		want       string
+		wantErr    string
+	}{
+		{name: "env wins over file", env: "KEYSTORE_TEST_PASSPHRASE", file: file, want: "from env"},
+		{name: "file when the env var is empty", env: "KEYSTORE_TEST_EMPTY", file: file, want: "from file"},
+		{name: "default env var", defaultEnv: "default env", file: file, want: "default env"},
+		{name: "file when the default env var is empty", file: file, want: "from file"},
+		{name: "no source", env: "KEYSTORE_TEST_EMPTY", wantErr: "set KEYSTORE_TEST_EMPTY to the keystore passphrase"},
+		{name: "no source, default env var", wantErr: "set ETH_KEYSTORE_PASSPHRASE"},
+		{name: "empty file", file: empty, wantErr: "is empty"},
+		{name: "missing file", file: file + ".missing", wantErr: "failed to read passphrase"},
+	}
+	for _, tt := range tests {
+		t.Setenv("ETH_KEYSTORE_PASSPHRASE", tt.defaultEnv)
+		got, err := readPassphrase(tt.env, tt.file)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: got %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
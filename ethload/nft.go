@@ -0,0 +1,210 @@
+package ethload
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Minimal ERC721 ABI: the 3-argument safeTransferFrom, ownership and enumeration
+const erc721ABI = `[{"inputs":[{"name":"from","type":"address"},{"name":"to","type":"address"},{"name":"tokenId","type":"uint256"}],"name":"safeTransferFrom","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"name":"tokenId","type":"uint256"}],"name":"ownerOf","outputs":[{"name":"","type":"address"}],"stateMutability":"view","type":"function"},{"inputs":[{"name":"owner","type":"address"}],"name":"balanceOf","outputs":[{"name":"","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[{"name":"owner","type":"address"},{"name":"index","type":"uint256"}],"name":"tokenOfOwnerByIndex","outputs":[{"name":"","type":"uint256"}],"stateMutability":"view","type":"function"}]`
+
+// Minimal ERC1155 ABI: safeTransferFrom and balanceOf
+const erc1155ABI = `[{"inputs":[{"name":"from","type":"address"},{"name":"to","type":"address"},{"name":"id","type":"uint256"},{"name":"value","type":"uint256"},{"name":"data","type":"bytes"}],"name":"safeTransferFrom","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"name":"account","type":"address"},{"name":"id","type":"uint256"}],"name":"balanceOf","outputs":[{"name":"","type":"uint256"}],"stateMutability":"view","type":"function"}]`
+
+var (
+	parsedERC721  = mustParseABI(erc721ABI)
+	parsedERC1155 = mustParseABI(erc1155ABI)
+)
+
+func mustParseABI(s string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(s))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// ERC721Config is the collection sent by txType erc721.
+type ERC721Config struct {
+	Contract string `json:"contract"`
+	// Token IDs each sender owns and may send, keyed by sender address. Senders
+	// left out are enumerated with tokenOfOwnerByIndex (ERC721Enumerable).
+	TokenIDs map[string][]string `json:"tokenIds"`
+}
+
+// ERC1155Config is the collection sent by txType erc1155.
+type ERC1155Config struct {
+	Contract string   `json:"contract"`
+	IDs      []string `json:"ids"`    // token IDs to pick from, at random
+	Amount   string   `json:"amount"` // units of the ID per transfer (default 1)
+}
+
+// notOwnedBySender marks ownership pre-check failures; those token IDs are not
+// returned to the pool.
+const notOwnedBySender = "not owned by the sender"
+
+// tokenPool hands out the ERC721 token IDs each sender still owns. An ID
+// taken by a transfer is gone for good once the transfer is broadcast.
+type tokenPool struct {
+	mu  sync.Mutex
+	ids map[common.Address][]*big.Int
+}
+
+// loadTokenPool builds the pool of every sender from tokenIds, enumerating
+// the ones not listed.
+func (n *node) loadTokenPool(ctx context.Context, cc *ERC721Config, contract common.Address, senders []common.Address) (*tokenPool, error) {
+	pool := &tokenPool{ids: make(map[common.Address][]*big.Int)}
+	listed := make(map[common.Address]bool)
+	for owner, ids := range cc.TokenIDs {
+		if !common.IsHexAddress(owner) {
+			return nil, fmt.Errorf("tokenIds: invalid sender address %q", owner)
+		}
+		addr := common.HexToAddress(owner)
+		listed[addr] = true
+		for _, s := range ids {
+			id, ok := new(big.Int).SetString(strings.TrimSpace(s), 0)
+			if !ok || id.Sign() < 0 {
+				return nil, fmt.Errorf("tokenIds: invalid token ID %q", s)
+			}
+			pool.ids[addr] = append(pool.ids[addr], id)
+		}
+	}
+	for _, sender := range senders {
+		if listed[sender] {
+			continue
+		}
+		listed[sender] = true
+		ids, err := n.ownedTokenIDs(ctx, contract, sender)
+		if err != nil {
+			return nil, fmt.Errorf("%s has no tokenIds and they could not be enumerated: %w", sender.Hex(), err)
+		}
+		pool.ids[sender] = ids
+		printf(n.out, "ERC721: %s owns %d token(s) of %s\n", sender.Hex(), len(ids), contract.Hex())
+	}
+	return pool, nil
+}
+
+// ownedTokenIDs lists the tokens owner holds in an ERC721Enumerable contract.
+func (n *node) ownedTokenIDs(ctx context.Context, contract, owner common.Address) ([]*big.Int, error) {
+	count, err := n.erc721Balance(ctx, contract, owner)
+	if err != nil {
+		return nil, err
+	}
+	bound := bind.NewBoundContract(contract, parsedERC721, n.client, n.client, n.client)
+	ids := make([]*big.Int, 0, count.Int64())
+	for i := int64(0); i < count.Int64(); i++ {
+		var res []interface{}
+		if err := bound.Call(&bind.CallOpts{Context: ctx}, &res, "tokenOfOwnerByIndex", owner, big.NewInt(i)); err != nil {
+			return nil, fmt.Errorf("failed to enumerate tokens: %w", err)
+		}
+		ids = append(ids, res[0].(*big.Int))
+	}
+	return ids, nil
+}
+
+// erc721Balance returns how many tokens of contract owner holds.
+func (n *node) erc721Balance(ctx context.Context, contract, owner common.Address) (*big.Int, error) {
+	bound := bind.NewBoundContract(contract, parsedERC721, n.client, n.client, n.client)
+	var res []interface{}
+	if err := bound.Call(&bind.CallOpts{Context: ctx}, &res, "balanceOf", owner); err != nil {
+		return nil, fmt.Errorf("failed to get NFT balance: %w", err)
+	}
+	return res[0].(*big.Int), nil
+}
+
+// erc1155Balance returns how many units of token id owner holds.
+func (n *node) erc1155Balance(ctx context.Context, contract, owner common.Address, id *big.Int) (*big.Int, error) {
+	bound := bind.NewBoundContract(contract, parsedERC1155, n.client, n.client, n.client)
+	var res []interface{}
+	if err := bound.Call(&bind.CallOpts{Context: ctx}, &res, "balanceOf", owner, id); err != nil {
+		return nil, fmt.Errorf("failed to get balance of token %s: %w", id, err)
+	}
+	return res[0].(*big.Int), nil
+}
+
+// take removes the next token ID of sender from the pool.
+func (p *tokenPool) take(sender common.Address) (*big.Int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ids := p.ids[sender]
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("erc721: no token IDs left for %s", sender.Hex())
+	}
+	p.ids[sender] = ids[1:]
+	return ids[0], nil
+}
+
+// release puts back a token ID whose transfer was never broadcast.
+func (p *tokenPool) release(sender common.Address, id *big.Int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ids[sender] = append(p.ids[sender], id)
+}
+
+// checkOwnership checks that addr can pay for gas and owns ERC721 token id.
+func (n *node) checkOwnership(ctx context.Context, addr common.Address, contract common.Address, id *big.Int) error {
+	if err := n.checkETHBalance(ctx, addr, new(big.Int)); err != nil {
+		return err
+	}
+	bound := bind.NewBoundContract(contract, parsedERC721, n.client, n.client, n.client)
+	var res []interface{}
+	if err := bound.Call(&bind.CallOpts{Context: ctx}, &res, "ownerOf", id); err != nil {
+		return fmt.Errorf("failed to get owner of token %s: %w", id, err)
+	}
+	if owner := res[0].(common.Address); owner != addr {
+		return fmt.Errorf("token %s is %s (owner %s)", id, notOwnedBySender, owner.Hex())
+	}
+	return nil
+}
+
+// checkERC1155Balance checks that addr can pay for gas and holds amount of ERC1155 token id.
+func (n *node) checkERC1155Balance(ctx context.Context, addr common.Address, contract common.Address, id, amount *big.Int) error {
+	if err := n.checkETHBalance(ctx, addr, new(big.Int)); err != nil {
+		return err
+	}
+	balance, err := n.erc1155Balance(ctx, contract, addr, id)
+	if err != nil {
+		return err
+	}
+	if balance.Cmp(amount) < 0 {
+		return fmt.Errorf("insufficient balance of token %s for transfer amount: %s", id, balance.String())
+	}
+	return nil
+}
+
+// erc1155Collection is a validated ERC1155Config.
+type erc1155Collection struct {
+	contract common.Address
+	ids      []*big.Int
+	amount   *big.Int
+}
+
+func loadERC1155(cc *ERC1155Config) (*erc1155Collection, error) {
+	if cc == nil || !common.IsHexAddress(cc.Contract) {
+		return nil, fmt.Errorf("erc1155.contract is not a valid address")
+	}
+	c := &erc1155Collection{contract: common.HexToAddress(cc.Contract), amount: big.NewInt(1)}
+	if len(cc.IDs) == 0 {
+		return nil, fmt.Errorf("erc1155.ids is empty")
+	}
+	for _, s := range cc.IDs {
+		id, ok := new(big.Int).SetString(strings.TrimSpace(s), 0)
+		if !ok || id.Sign() < 0 {
+			return nil, fmt.Errorf("erc1155.ids: invalid token ID %q", s)
+		}
+		c.ids = append(c.ids, id)
+	}
+	if strings.TrimSpace(cc.Amount) != "" {
+		if _, ok := c.amount.SetString(strings.TrimSpace(cc.Amount), 10); !ok || c.amount.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid erc1155.amount %q", cc.Amount)
+		}
+	}
+	return c, nil
+}
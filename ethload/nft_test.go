@@ -0,0 +1,327 @@
+package ethload
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// nftChain serves the ERC721 and ERC1155 views of one collection of each.
+type nftChain struct {
+	Client
+	eth      *big.Int
+	owners   map[int64]common.Address // ERC721 token ID -> owner
+	balances map[common.Address]map[int64]int64
+}
+
+func (c *nftChain) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	return c.eth, nil
+}
+
+func (c *nftChain) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	for _, parsed := range []abi.ABI{parsedERC721, parsedERC1155} {
+		method, err := parsed.MethodById(msg.Data)
+		if err != nil {
+			continue
+		}
+		args, err := method.Inputs.Unpack(msg.Data[4:])
+		if err != nil {
+			return nil, err
+		}
+		out, err := c.call(method.Sig, args)
+		if err != nil {
+			return nil, err
+		}
+		return method.Outputs.Pack(out)
+	}
+	return nil, errors.New("execution reverted")
+}
+
+func (c *nftChain) call(sig string, args []interface{}) (interface{}, error) {
+	switch sig {
+	case "ownerOf(uint256)":
+		owner, ok := c.owners[args[0].(*big.Int).Int64()]
+		if !ok {
+			return nil, errors.New("execution reverted: invalid token ID")
+		}
+		return owner, nil
+	case "balanceOf(address)":
+		return big.NewInt(int64(len(c.owned(args[0].(common.Address))))), nil
+	case "tokenOfOwnerByIndex(address,uint256)":
+		owned := c.owned(args[0].(common.Address))
+		i := args[1].(*big.Int).Int64()
+		if i >= int64(len(owned)) {
+			return nil, errors.New("execution reverted: index out of bounds")
+		}
+		return big.NewInt(owned[i]), nil
+	case "balanceOf(address,uint256)":
+		return big.NewInt(c.balances[args[0].(common.Address)][args[1].(*big.Int).Int64()]), nil
+	}
+	return nil, errors.New("execution reverted")
+}
+
+// owned lists the ERC721 token IDs owner holds, in order.
+func (c *nftChain) owned(owner common.Address) []int64 {
+	var ids []int64
+	for id, o := range c.owners {
+		if o == owner {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func ids(ns ...int64) []*big.Int {
+	out := make([]*big.Int, len(ns))
+	for i, n := range ns {
+		out[i] = big.NewInt(n)
+	}
+	return out
+}
+
+func idsEqual(a, b []*big.Int) bool {
+	return slices.EqualFunc(a, b, func(x, y *big.Int) bool { return x.Cmp(y) == 0 })
+}
+
+func TestLoadTokenPool(t *testing.T) {
+	ctx := context.Background()
+	contract := common.HexToAddress("0x5000000000000000000000000000000000000005")
+	listed := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	enumerated := common.HexToAddress("0x1000000000000000000000000000000000000002")
+	empty := common.HexToAddress("0x1000000000000000000000000000000000000003")
+	chain := &nftChain{owners: map[int64]common.Address{7: enumerated, 5: enumerated, 9: listed}}
+	n := &node{client: chain}
+
+	cc := &ERC721Config{Contract: contract.Hex(), TokenIDs: map[string][]string{listed.Hex(): {"1", "0x2"}}}
+	pool, err := n.loadTokenPool(ctx, cc, contract, []common.Address{listed, enumerated, empty})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Listed IDs are taken as given, without asking the contract.
+	for sender, want := range map[common.Address][]*big.Int{listed: ids(1, 2), enumerated: ids(5, 7), empty: nil} {
+		if got := pool.ids[sender]; !idsEqual(got, want) {
+			t.Errorf("pool of %s = %v, want %v", sender.Hex(), got, want)
+		}
+	}
+
+	// Every sender draws from its own pool until it runs out.
+	for _, want := range []int64{5, 7} {
+		id, err := pool.take(enumerated)
+		if err != nil || id.Int64() != want {
+			t.Fatalf("take = %v, %v; want %d", id, err, want)
+		}
+	}
+	if _, err := pool.take(enumerated); err == nil || !strings.Contains(err.Error(), "no token IDs left") {
+		t.Errorf("take from an empty pool: %v, want no token IDs left", err)
+	}
+	pool.release(enumerated, big.NewInt(7))
+	if id, err := pool.take(enumerated); err != nil || id.Int64() != 7 {
+		t.Errorf("take after release = %v, %v; want 7", id, err)
+	}
+	if id, err := pool.take(listed); err != nil || id.Int64() != 1 {
+		t.Errorf("take from the listed pool = %v, %v; want 1", id, err)
+	}
+
+	for _, bad := range []map[string][]string{
+		{"0x12": {"1"}},
+		{listed.Hex(): {"one"}},
+		{listed.Hex(): {"-1"}},
+	} {
+		if _, err := n.loadTokenPool(ctx, &ERC721Config{TokenIDs: bad}, contract, nil); err == nil {
+			t.Errorf("loadTokenPool(%v) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestCheckOwnership(t *testing.T) {
+	ctx := context.Background()
+	contract := common.HexToAddress("0x5000000000000000000000000000000000000005")
+	owner := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	other := common.HexToAddress("0x1000000000000000000000000000000000000002")
+	chain := &nftChain{eth: big.NewInt(1e18), owners: map[int64]common.Address{1: owner}}
+	n := &node{client: chain}
+
+	if err := n.checkOwnership(ctx, owner, contract, big.NewInt(1)); err != nil {
+		t.Errorf("owner: %v", err)
+	}
+	err := n.checkOwnership(ctx, other, contract, big.NewInt(1))
+	if err == nil || !strings.Contains(err.Error(), notOwnedBySender) || !strings.Contains(err.Error(), owner.Hex()) {
+		t.Errorf("other sender: %v, want %q naming the owner", err, notOwnedBySender)
+	}
+	if err := n.checkOwnership(ctx, owner, contract, big.NewInt(2)); err == nil || !strings.Contains(err.Error(), "failed to get owner of token 2") {
+		t.Errorf("unminted token: %v, want the ownerOf failure", err)
+	}
+	chain.eth = big.NewInt(1)
+	if err := n.checkOwnership(ctx, owner, contract, big.NewInt(1)); err == nil || !strings.Contains(err.Error(), "insufficient ETH balance") {
+		t.Errorf("no gas money: %v, want insufficient ETH", err)
+	}
+}
+
+func TestCheckERC1155Balance(t *testing.T) {
+	ctx := context.Background()
+	contract := common.HexToAddress("0x6000000000000000000000000000000000000006")
+	holder := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	chain := &nftChain{eth: big.NewInt(1e18), balances: map[common.Address]map[int64]int64{holder: {3: 5}}}
+	n := &node{client: chain}
+
+	if err := n.checkERC1155Balance(ctx, holder, contract, big.NewInt(3), big.NewInt(5)); err != nil {
+		t.Errorf("enough: %v", err)
+	}
+	if err := n.checkERC1155Balance(ctx, holder, contract, big.NewInt(3), big.NewInt(6)); err == nil || !strings.Contains(err.Error(), "insufficient balance of token 3") {
+		t.Errorf("short: %v, want insufficient balance", err)
+	}
+	if err := n.checkERC1155Balance(ctx, holder, contract, big.NewInt(4), big.NewInt(1)); err == nil {
+		t.Error("ID the holder has none of: accepted")
+	}
+	chain.eth = big.NewInt(1)
+	if err := n.checkERC1155Balance(ctx, holder, contract, big.NewInt(3), big.NewInt(1)); err == nil || !strings.Contains(err.Error(), "insufficient ETH balance") {
+		t.Errorf("no gas money: %v, want insufficient ETH", err)
+	}
+}
+
+func TestWorkloadERC721ReturnsUnsentIDs(t *testing.T) {
+	contract := common.HexToAddress("0x5000000000000000000000000000000000000005")
+	pair := testCycler(1).pairs[0]
+	pool := &tokenPool{ids: map[common.Address][]*big.Int{pair.Sender: ids(1, 2, 3)}}
+	w := &workload{types: []string{TxTypeERC721}, cumWeight: []float64{1}, amounts: map[string]*amountPicker{}, erc721: contract, pool: pool}
+
+	req, err := w.next(pair)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.to != contract || req.tokenID.Int64() != 1 || req.amount != nil {
+		t.Errorf("request %+v, want token 1 sent through the contract", req)
+	}
+	args, err := parsedERC721.Methods["safeTransferFrom"].Inputs.Unpack(req.data[4:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args[0] != pair.Sender || args[1] != pair.Recipient || args[2].(*big.Int).Int64() != 1 {
+		t.Errorf("safeTransferFrom%v, want (sender, recipient, 1)", args)
+	}
+
+	// A send that failed before broadcast hands its ID back; a broadcast one
+	// or one the sender turned out not to own is gone.
+	w.done(pair, req, TransferResult{Status: "failed", Error: "failed to get ETH balance"})
+	req2, _ := w.next(pair)
+	w.done(pair, req2, TransferResult{Status: "success", TxHash: "0x01"})
+	req3, _ := w.next(pair)
+	w.done(pair, req3, TransferResult{Status: "failed", Error: "token 3 is " + notOwnedBySender})
+	if got := pool.ids[pair.Sender]; !idsEqual(got, ids(1)) {
+		t.Errorf("pool after the sends = %v, want only the unsent 1 back", got)
+	}
+}
+
+func TestLoadERC1155(t *testing.T) {
+	contract := "0x6000000000000000000000000000000000000006"
+	c, err := loadERC1155(&ERC1155Config{Contract: contract, IDs: []string{"1", "0x10"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !idsEqual(c.ids, ids(1, 16)) || c.amount.Int64() != 1 {
+		t.Errorf("collection %+v, want IDs 1 and 16 with the default amount 1", c)
+	}
+	for _, bad := range []*ERC1155Config{
+		nil,
+		{Contract: "0x12", IDs: []string{"1"}},
+		{Contract: contract},
+		{Contract: contract, IDs: []string{"-1"}},
+		{Contract: contract, IDs: []string{"1"}, Amount: "0"},
+		{Contract: contract, IDs: []string{"1"}, Amount: "1.5"},
+	} {
+		if _, err := loadERC1155(bad); err == nil {
+			t.Errorf("loadERC1155(%+v) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestVerifyERC721Event(t *testing.T) {
+	contract := common.HexToAddress("0x5000000000000000000000000000000000000005")
+	from := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	to := common.HexToAddress("0x1000000000000000000000000000000000000002")
+	nftLog := func(from, to common.Address, id int64) *types.Log {
+		return &types.Log{Address: contract, Topics: []common.Hash{transferEventSig, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes()), common.BigToHash(big.NewInt(id))}}
+	}
+	tests := []struct {
+		name    string
+		receipt *types.Receipt
+		wantErr string
+	}{
+		{name: "matching", receipt: successReceipt(transferLog(contract, from, to, 1), nftLog(from, to, 4))},
+		{name: "no event", receipt: successReceipt(transferLog(contract, from, to, 4)), wantErr: "emitted no ERC721 Transfer event"},
+		{name: "other token", receipt: successReceipt(nftLog(from, to, 5)), wantErr: "(transfers: " + from.Hex() + "->" + to.Hex() + " #5)"},
+		{name: "wrong recipient", receipt: successReceipt(nftLog(from, from, 4)), wantErr: "(transfers: " + from.Hex() + "->" + from.Hex() + " #4)"},
+	}
+	for _, tt := range tests {
+		err := verifyERC721Event(tt.receipt, contract, from, to, big.NewInt(4))
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestVerifyERC1155Event(t *testing.T) {
+	contract := common.HexToAddress("0x6000000000000000000000000000000000000006")
+	operator := common.HexToAddress("0x7000000000000000000000000000000000000007")
+	from := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	to := common.HexToAddress("0x1000000000000000000000000000000000000002")
+	topics := func(sig common.Hash, from, to common.Address) []common.Hash {
+		return []common.Hash{sig, common.BytesToHash(operator.Bytes()), common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())}
+	}
+	single := func(from, to common.Address, id, value int64) *types.Log {
+		data := append(common.BigToHash(big.NewInt(id)).Bytes(), common.BigToHash(big.NewInt(value)).Bytes()...)
+		return &types.Log{Address: contract, Topics: topics(transferSingleSig, from, to), Data: data}
+	}
+	batch := func(from, to common.Address, idList, values []*big.Int) *types.Log {
+		data, err := transferBatchData.Pack(idList, values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &types.Log{Address: contract, Topics: topics(transferBatchSig, from, to), Data: data}
+	}
+	tests := []struct {
+		name    string
+		receipt *types.Receipt
+		wantErr string
+	}{
+		{name: "TransferSingle", receipt: successReceipt(single(from, to, 3, 5))},
+		{name: "TransferBatch", receipt: successReceipt(batch(from, to, ids(1, 3), ids(9, 5)))},
+		{name: "split across both", receipt: successReceipt(single(from, to, 3, 2), batch(from, to, ids(3), ids(3)))},
+		{name: "another contract", receipt: successReceipt(&types.Log{Address: operator, Topics: topics(transferSingleSig, from, to), Data: single(from, to, 3, 5).Data}),
+			wantErr: "transfer events credit 0"},
+		{name: "no event", receipt: successReceipt(), wantErr: "transfer events credit 0"},
+		{name: "short amount", receipt: successReceipt(single(from, to, 3, 4)), wantErr: "transfer events credit 4"},
+		{name: "batch of another ID", receipt: successReceipt(batch(from, to, ids(4), ids(5))),
+			wantErr: "credit 0 (other transfers: " + from.Hex() + "->" + to.Hex() + " #4 x5)"},
+		{name: "wrong recipient", receipt: successReceipt(single(from, operator, 3, 5)),
+			wantErr: "credit 0 (other transfers: " + from.Hex() + "->" + operator.Hex() + " #3 x5)"},
+		{name: "mismatched batch", receipt: successReceipt(batch(from, to, ids(3, 3), ids(5))), wantErr: "transfer events credit 0"},
+	}
+	for _, tt := range tests {
+		err := verifyERC1155Event(tt.receipt, contract, from, to, big.NewInt(3), big.NewInt(5))
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
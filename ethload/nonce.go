@@ -0,0 +1,289 @@
+package ethload
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"tron_load/secrets"
+)
+
+// defaultNonceCheckInterval is how often the gap watcher compares each
+// sender's local nonce sequence with the node's pending nonce.
+const defaultNonceCheckInterval = 15 * time.Second
+
+// gapFillGasLimit is the gas limit of the 0-value self transfer used to fill
+// a nonce gap.
+const gapFillGasLimit = 21000
+
+// NonceRepair records a gap-filling transaction sent on behalf of a sender.
+type NonceRepair struct {
+	Sender string `json:"sender"`
+	Nonce  uint64 `json:"nonce"`
+	TxHash string `json:"txHash,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// nonceManager hands out nonces per sender and keeps each sequence gap-free:
+// nonces of transactions that never reached the node are handed back and
+// reused, the counter is resynced from the node when it turns out to be
+// stale, and gaps the node is stuck on are filled with 0-value self transfers.
+type nonceManager struct {
+	node    *node
+	mu      sync.Mutex
+	senders map[common.Address]*senderNonces
+	repairs []NonceRepair
+}
+
+type senderNonces struct {
+	key      *ecdsa.PrivateKey // signs gap fillers
+	next     uint64            // next never-used nonce
+	released []uint64          // handed back, reused lowest first
+	inFlight map[uint64]bool   // acquired, send not settled yet
+	suspect  uint64            // pending nonce that looked like a gap at the last check
+	hasGap   bool              // whether suspect is set
+}
+
+func newNonceManager(n *node) *nonceManager {
+	return &nonceManager{node: n, senders: make(map[common.Address]*senderNonces)}
+}
+
+// acquire returns the nonce for the next transaction from addr: the lowest
+// released nonce if there is one, otherwise the next fresh one. The caller
+// must pass the outcome of sending it to settle.
+func (m *nonceManager) acquire(ctx context.Context, key *ecdsa.PrivateKey, addr common.Address) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.senders[addr]
+	if !ok {
+		start, err := m.node.client.PendingNonceAt(ctx, addr)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get nonce: %w", err)
+		}
+		s = &senderNonces{key: key, next: start, inFlight: make(map[uint64]bool)}
+		m.senders[addr] = s
+	}
+	var n uint64
+	if len(s.released) > 0 {
+		n, s.released = s.released[0], s.released[1:]
+	} else {
+		n = s.next
+		s.next++
+	}
+	s.inFlight[n] = true
+	return n, nil
+}
+
+// settle records the outcome of sending the transaction that used nonce.
+//   - accepted, or rejected because the nonce is already used: the nonce is
+//     consumed, and in the latter case the counter is resynced from the node
+//   - the send timed out: the nonce may or may not have arrived, so it is left
+//     for the gap watcher to decide
+//   - rejected for any other reason: the nonce never reached the node and is
+//     handed back for reuse
+func (m *nonceManager) settle(ctx context.Context, addr common.Address, nonce uint64, sendErr error) {
+	m.mu.Lock()
+	s := m.senders[addr]
+	delete(s.inFlight, nonce)
+	switch {
+	case sendErr == nil:
+	case isNonceUsedError(sendErr):
+		m.mu.Unlock()
+		if err := m.resync(ctx, addr); err != nil {
+			printf(m.node.out, "nonce resync for %s failed: %v\n", addr.Hex(), err)
+		}
+		return
+	case sendTimedOut(ctx, sendErr):
+	default:
+		s.released = append(s.released, nonce)
+		sort.Slice(s.released, func(i, j int) bool { return s.released[i] < s.released[j] })
+	}
+	m.mu.Unlock()
+}
+
+// sendTimedOut reports whether a send failed without an answer from the node,
+// so the transaction may or may not have reached it.
+func sendTimedOut(ctx context.Context, err error) bool {
+	return ctx.Err() != nil || errors.Is(err, context.DeadlineExceeded) || strings.Contains(strings.ToLower(err.Error()), "timeout")
+}
+
+// resync moves addr's counter up to the node's pending nonce and forgets
+// released nonces the node has already seen used.
+func (m *nonceManager) resync(ctx context.Context, addr common.Address) error {
+	pending, err := m.node.client.PendingNonceAt(ctx, addr)
+	if err != nil {
+		return fmt.Errorf("failed to get nonce: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.senders[addr]
+	if pending > s.next {
+		s.next = pending
+	}
+	kept := s.released[:0]
+	for _, n := range s.released {
+		if n >= pending {
+			kept = append(kept, n)
+		}
+	}
+	s.released = kept
+	return nil
+}
+
+// isNonceUsedError reports whether a send error means the nonce is already
+// taken on the node, by a mined or a pending transaction.
+func isNonceUsedError(err error) bool {
+	l := strings.ToLower(err.Error())
+	return strings.Contains(l, "nonce too low") || strings.Contains(l, "already known") || strings.Contains(l, "replacement transaction underpriced")
+}
+
+// findGap returns the nonce addr's later transactions are stuck behind, if
+// any: the node's pending nonce, when it is below the local counter and no
+// transaction with that nonce is currently being sent.
+func (m *nonceManager) findGap(ctx context.Context, addr common.Address) (uint64, bool, error) {
+	pending, err := m.node.client.PendingNonceAt(ctx, addr)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get nonce: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.senders[addr]
+	if pending >= s.next || s.inFlight[pending] {
+		return 0, false, nil
+	}
+	return pending, true, nil
+}
+
+// checkGaps looks for a gap on every known sender and fills it. Unless
+// immediate is set, a gap is only filled once it is seen at the same nonce on
+// two consecutive checks, giving the runner a chance to reuse it first.
+func (m *nonceManager) checkGaps(ctx context.Context, immediate bool) {
+	m.mu.Lock()
+	addrs := make([]common.Address, 0, len(m.senders))
+	for addr := range m.senders {
+		addrs = append(addrs, addr)
+	}
+	m.mu.Unlock()
+	for _, addr := range addrs {
+		// Fill repeatedly: once a gap is filled the node may be stuck on the next one.
+		for {
+			gap, ok, err := m.findGap(ctx, addr)
+			if err != nil {
+				printf(m.node.out, "nonce gap check for %s failed: %v\n", addr.Hex(), err)
+				break
+			}
+			m.mu.Lock()
+			s := m.senders[addr]
+			confirmed := ok && (immediate || (s.hasGap && s.suspect == gap))
+			s.suspect, s.hasGap = gap, ok && !confirmed
+			if confirmed {
+				// Take the nonce out of circulation while the filler is sent.
+				s.inFlight[gap] = true
+				kept := s.released[:0]
+				for _, n := range s.released {
+					if n != gap {
+						kept = append(kept, n)
+					}
+				}
+				s.released = kept
+			}
+			m.mu.Unlock()
+			if !confirmed || !m.fillGap(ctx, addr, gap) || !immediate {
+				break
+			}
+		}
+	}
+}
+
+// fillGap sends a 0-value self transfer from addr with the gap nonce.
+func (m *nonceManager) fillGap(ctx context.Context, addr common.Address, nonce uint64) bool {
+	repair := NonceRepair{Sender: addr.Hex(), Nonce: nonce}
+	m.mu.Lock()
+	key := m.senders[addr].key
+	m.mu.Unlock()
+	err := func() error {
+		fees, err := m.node.suggestFees(ctx)
+		if err != nil {
+			return err
+		}
+		tx := fees.newTx(nonce, &addr, big.NewInt(0), gapFillGasLimit, nil)
+		signed, err := types.SignTx(tx, types.LatestSignerForChainID(m.node.chainID), key)
+		if err != nil {
+			return fmt.Errorf("failed to sign gap filler: %w", err)
+		}
+		repair.TxHash = signed.Hash().Hex()
+		return m.node.client.SendTransaction(ctx, signed)
+	}()
+	if err != nil {
+		repair.Error = err.Error()
+		printf(m.node.out, "failed to fill nonce gap %d for %s: %v\n", nonce, addr.Hex(), err)
+	} else {
+		printf(m.node.out, "Filled nonce gap %d for %s with %s\n", nonce, addr.Hex(), repair.TxHash)
+	}
+	m.mu.Lock()
+	delete(m.senders[addr].inFlight, nonce)
+	m.repairs = append(m.repairs, repair)
+	m.mu.Unlock()
+	return err == nil
+}
+
+// watchGaps checks for nonce gaps every interval until the returned stop
+// function is called. stop runs a final check that fills any remaining gap
+// straight away, since no later transfer will reuse it.
+func (m *nonceManager) watchGaps(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				m.checkGaps(ctx, false)
+				cancel()
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		m.checkGaps(ctx, true)
+	}
+}
+
+// nonceRepairs returns the gap fillers sent so far.
+func (m *nonceManager) nonceRepairs() []NonceRepair {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]NonceRepair(nil), m.repairs...)
+}
+
+func printNonceRepairs(w io.Writer, repairs []NonceRepair) {
+	if len(repairs) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\n========== NONCE REPAIRS ==========")
+	for _, r := range repairs {
+		if r.Error != "" {
+			fmt.Fprintf(w, "%s nonce %d: failed: %s\n", r.Sender, r.Nonce, secrets.Redact(r.Error))
+			continue
+		}
+		fmt.Fprintf(w, "%s nonce %d: filled by %s\n", r.Sender, r.Nonce, r.TxHash)
+	}
+}
@@ -0,0 +1,130 @@
+package ethload
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// pendingNonceClient is a Client whose only working method is PendingNonceAt.
+type pendingNonceClient struct {
+	Client
+	pending uint64
+}
+
+func (c *pendingNonceClient) PendingNonceAt(context.Context, common.Address) (uint64, error) {
+	return c.pending, nil
+}
+
+// newTestNonces returns a nonce manager for addr whose node reports pending,
+// after acquiring nonces start..start+acquired-1.
+func newTestNonces(t *testing.T, addr common.Address, start, pending uint64, acquired int) (*nonceManager, *pendingNonceClient) {
+	t.Helper()
+	client := &pendingNonceClient{pending: start}
+	m := newNonceManager(&node{client: client})
+	for i := 0; i < acquired; i++ {
+		if _, err := m.acquire(context.Background(), nil, addr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client.pending = pending
+	return m, client
+}
+
+func TestNonceSettle(t *testing.T) {
+	addr := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	tests := []struct {
+		name         string
+		settled      []uint64
+		sendErr      error
+		pending      uint64
+		wantNext     uint64
+		wantReleased []uint64
+	}{
+		{name: "accepted", settled: []uint64{5, 6}, pending: 10,
+			wantNext: 8},
+		{name: "rejected nonces are handed back in order", settled: []uint64{7, 5}, sendErr: errors.New("insufficient funds for gas"), pending: 5,
+			wantNext: 8, wantReleased: []uint64{5, 7}},
+		{name: "timeout is left to the gap watcher", settled: []uint64{6}, sendErr: context.DeadlineExceeded, pending: 5,
+			wantNext: 8},
+		{name: "timeout message", settled: []uint64{6}, sendErr: errors.New("i/o timeout"), pending: 5,
+			wantNext: 8},
+		{name: "nonce too low resyncs the counter", settled: []uint64{5}, sendErr: errors.New("nonce too low"), pending: 12,
+			wantNext: 12},
+		{name: "already known keeps a counter ahead of the node", settled: []uint64{5}, sendErr: errors.New("already known"), pending: 6,
+			wantNext: 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := newTestNonces(t, addr, 5, tt.pending, 3)
+			for _, n := range tt.settled {
+				m.settle(context.Background(), addr, n, tt.sendErr)
+			}
+			s := m.senders[addr]
+			if s.next != tt.wantNext {
+				t.Errorf("next = %d, want %d", s.next, tt.wantNext)
+			}
+			if len(s.released) != 0 || len(tt.wantReleased) != 0 {
+				if !reflect.DeepEqual(s.released, tt.wantReleased) {
+					t.Errorf("released = %v, want %v", s.released, tt.wantReleased)
+				}
+			}
+			for _, n := range tt.settled {
+				if s.inFlight[n] {
+					t.Errorf("nonce %d still in flight", n)
+				}
+			}
+		})
+	}
+}
+
+func TestNonceSettleResyncDropsUsedReleases(t *testing.T) {
+	addr := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	m, client := newTestNonces(t, addr, 0, 0, 4)
+	m.settle(context.Background(), addr, 1, errors.New("underpriced"))
+	m.settle(context.Background(), addr, 3, errors.New("underpriced"))
+	client.pending = 2
+	m.settle(context.Background(), addr, 0, errors.New("nonce too low"))
+	if got := m.senders[addr].released; !reflect.DeepEqual(got, []uint64{3}) {
+		t.Errorf("released = %v, want [3]", got)
+	}
+	n, err := m.acquire(context.Background(), nil, addr)
+	if err != nil || n != 3 {
+		t.Errorf("acquire = %d, %v, want the released 3", n, err)
+	}
+}
+
+func TestNonceFindGap(t *testing.T) {
+	addr := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	tests := []struct {
+		name     string
+		pending  uint64
+		settled  []uint64 // accepted by the node
+		wantGap  uint64
+		wantFind bool
+	}{
+		{name: "node caught up", pending: 8, settled: []uint64{5, 6, 7}},
+		{name: "node ahead", pending: 9, settled: []uint64{5, 6, 7}},
+		{name: "stuck behind a nonce that never arrived", pending: 6, settled: []uint64{5, 6, 7},
+			wantGap: 6, wantFind: true},
+		{name: "stuck nonce still being sent", pending: 6, settled: []uint64{5, 7}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := newTestNonces(t, addr, 5, tt.pending, 3)
+			for _, n := range tt.settled {
+				m.settle(context.Background(), addr, n, nil)
+			}
+			gap, ok, err := m.findGap(context.Background(), addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.wantFind || gap != tt.wantGap {
+				t.Errorf("findGap = %d, %v, want %d, %v", gap, ok, tt.wantGap, tt.wantFind)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package ethload
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// transferTimeout bounds a single transfer, including retries and the receipt
+// wait, for runs that have no drain deadline.
+const transferTimeout = 2 * time.Minute
+
+// sendFunc executes one transfer for a sender/recipient pair.
+type sendFunc func(ctx context.Context, p transferPair) TransferResult
+
+// arrivalSchedule returns the time of the next arrival given the previous one
+// (zero for the first call), or false once the schedule is exhausted.
+type arrivalSchedule func(prev time.Time) (time.Time, bool)
+
+// pairCycler hands out pairs in round-robin order and is safe for concurrent use.
+type pairCycler struct {
+	pairs []transferPair
+	n     atomic.Uint64
+}
+
+func (c *pairCycler) next() transferPair {
+	return c.pairs[(c.n.Add(1)-1)%uint64(len(c.pairs))]
+}
+
+// constantRate schedules arrivals at tps per second starting at start, stopping
+// after total arrivals (when total > 0) or at end (when end is set).
+func constantRate(start time.Time, tps float64, total int, end time.Time) arrivalSchedule {
+	interval := time.Duration(float64(time.Second) / tps)
+	i := 0
+	return func(time.Time) (time.Time, bool) {
+		at := start.Add(time.Duration(i) * interval)
+		if (total > 0 && i >= total) || (!end.IsZero() && !at.Before(end)) {
+			return time.Time{}, false
+		}
+		i++
+		return at, true
+	}
+}
+
+// openLoopInFlight is the MaxInFlight default for a peak rate: room for a
+// minute of arrivals, enough to cover receipt waits on most chains.
+func openLoopInFlight(config TestConfig, peakTPS float64) int {
+	if config.MaxInFlight > 0 {
+		return config.MaxInFlight
+	}
+	n := int(math.Ceil(peakTPS * 60))
+	if n < config.MaxGoroutines {
+		n = config.MaxGoroutines
+	}
+	return n
+}
+
+// runOpenLoop dispatches one transfer per arrival in schedule, taking pairs
+// from cycler. Arrivals are timed against the schedule rather than against the
+// previous send, so a slow RPC never lowers the offered rate: each send records
+// how far behind schedule it was dispatched, and arrivals that find
+// maxInFlight transfers still outstanding are dropped instead of queued.
+// Transfers run under ctx (see transferContext).
+func (r *Runner) runOpenLoop(ctx context.Context, config TestConfig, cycler *pairCycler, schedule arrivalSchedule, maxInFlight int, send sendFunc) []TransferResult {
+	lateAfter := time.Duration(config.LateThresholdMs) * time.Millisecond
+	if lateAfter <= 0 {
+		lateAfter = 100 * time.Millisecond
+	}
+
+	var results []TransferResult
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	sem := make(chan struct{}, maxInFlight)
+	record := func(res TransferResult) {
+		mu.Lock()
+		results = append(results, res)
+		mu.Unlock()
+	}
+
+	var scheduled time.Time
+	for {
+		next, ok := schedule(scheduled)
+		if !ok {
+			break
+		}
+		scheduled = next
+		if d := time.Until(scheduled); d > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(d):
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		p := cycler.next()
+		lag := time.Since(scheduled)
+		select {
+		case sem <- struct{}{}:
+		default:
+			record(r.finish(TransferResult{
+				From:          p.Sender.Hex(),
+				To:            p.Recipient.Hex(),
+				Status:        "dropped",
+				Error:         fmt.Sprintf("in-flight limit of %d reached", maxInFlight),
+				ScheduledAt:   scheduled,
+				ScheduleLagMs: durationMs(lag),
+				Late:          lag > lateAfter,
+			}))
+			continue
+		}
+		wg.Add(1)
+		go func(p transferPair, scheduled time.Time, lag time.Duration) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			tctx, cancel := r.transferContext(ctx)
+			defer cancel()
+			res := send(tctx, p)
+			res.ScheduledAt = scheduled
+			res.ScheduleLagMs = durationMs(lag)
+			res.Late = lag > lateAfter
+			record(res)
+		}(p, scheduled, lag)
+	}
+	wg.Wait()
+	return results
+}
+
+// printSchedule summarises how closely an open-loop run kept to its schedule,
+// preceded by title when non-empty. It prints nothing for closed-loop results.
+func printSchedule(w io.Writer, title string, results []TransferResult) {
+	var first, last time.Time
+	scheduled, dropped, late := 0, 0, 0
+	var totalLag, maxLag float64
+	for _, r := range results {
+		if r.ScheduledAt.IsZero() {
+			continue
+		}
+		if scheduled == 0 || r.ScheduledAt.Before(first) {
+			first = r.ScheduledAt
+		}
+		if r.ScheduledAt.After(last) {
+			last = r.ScheduledAt
+		}
+		scheduled++
+		if r.Status == "dropped" {
+			dropped++
+		}
+		if r.Late {
+			late++
+		}
+		totalLag += r.ScheduleLagMs
+		maxLag = math.Max(maxLag, r.ScheduleLagMs)
+	}
+	if scheduled == 0 {
+		return
+	}
+	if title != "" {
+		fmt.Fprintln(w, title)
+	}
+	if span := last.Sub(first).Seconds(); span > 0 {
+		fmt.Fprintf(w, "  Offered rate: %.2f tx/s over %.1fs\n", float64(scheduled-1)/span, span)
+	}
+	fmt.Fprintf(w, "  Scheduled: %d | Dispatched: %d | Dropped: %d | Late: %d\n", scheduled, scheduled-dropped, dropped, late)
+	fmt.Fprintf(w, "  Schedule lag: avg %.1fms | max %.1fms\n", totalLag/float64(scheduled), maxLag)
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,213 @@
+package ethload
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestConstantRate(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		tps   float64
+		total int
+		end   time.Time
+		want  int
+	}{
+		{"total", 10, 5, time.Time{}, 5},
+		{"end excludes its own instant", 10, 0, start.Add(time.Second), 10},
+		{"total before end", 10, 3, start.Add(time.Second), 3},
+		{"end before total", 2, 100, start.Add(2 * time.Second), 4},
+	}
+	for _, tt := range tests {
+		schedule := constantRate(start, tt.tps, tt.total, tt.end)
+		var got []time.Time
+		var prev time.Time
+		for len(got) <= tt.want {
+			at, ok := schedule(prev)
+			if !ok {
+				break
+			}
+			got, prev = append(got, at), at
+		}
+		if len(got) != tt.want {
+			t.Errorf("%s: %d arrivals, want %d", tt.name, len(got), tt.want)
+			continue
+		}
+		interval := time.Duration(float64(time.Second) / tt.tps)
+		for i, at := range got {
+			if want := start.Add(time.Duration(i) * interval); !at.Equal(want) {
+				t.Errorf("%s: arrival %d at %v, want %v", tt.name, i, at, want)
+			}
+		}
+	}
+}
+
+// testCycler cycles over n pairs with distinct senders.
+func testCycler(n int) *pairCycler {
+	c := &pairCycler{}
+	for i := 0; i < n; i++ {
+		c.pairs = append(c.pairs, transferPair{
+			Sender:    common.BigToAddress(big.NewInt(int64(i + 1))),
+			Recipient: common.HexToAddress("0x1000000000000000000000000000000000000001"),
+		})
+	}
+	return c
+}
+
+func TestRunOpenLoopKeepsSchedule(t *testing.T) {
+	start := time.Now().Add(20 * time.Millisecond)
+	schedule := constantRate(start, 200, 10, time.Time{})
+	var mu sync.Mutex
+	var dispatched []time.Time
+	send := func(ctx context.Context, p transferPair) TransferResult {
+		mu.Lock()
+		dispatched = append(dispatched, time.Now())
+		mu.Unlock()
+		return TransferResult{From: p.Sender.Hex(), Status: "success"}
+	}
+	r := &Runner{}
+	results := r.runOpenLoop(context.Background(), TestConfig{}, testCycler(3), schedule, 10, send)
+
+	if len(results) != 10 || len(dispatched) != 10 {
+		t.Fatalf("%d results from %d sends, want 10", len(results), len(dispatched))
+	}
+	senders := map[string]int{}
+	for _, res := range results {
+		if res.Status != "success" || res.Late {
+			t.Errorf("result %+v, want an on-time success", res)
+		}
+		offset := res.ScheduledAt.Sub(start)
+		if offset < 0 || offset%(5*time.Millisecond) != 0 || offset > 45*time.Millisecond {
+			t.Errorf("scheduled %v after the start, want a multiple of 5ms up to 45ms", offset)
+		}
+		if res.ScheduleLagMs < 0 {
+			t.Errorf("negative schedule lag %vms", res.ScheduleLagMs)
+		}
+		senders[res.From]++
+	}
+	if len(senders) != 3 {
+		t.Errorf("sent from %d senders, want all 3 in turn", len(senders))
+	}
+	for _, at := range dispatched {
+		if at.Before(start) {
+			t.Errorf("dispatched %v before the first arrival", start.Sub(at))
+		}
+	}
+}
+
+func TestRunOpenLoopDropsAtInFlightLimit(t *testing.T) {
+	release := make(chan struct{})
+	send := func(ctx context.Context, p transferPair) TransferResult {
+		<-release
+		return TransferResult{Status: "success"}
+	}
+	schedule := constantRate(time.Now(), 500, 8, time.Time{})
+	done := make(chan []TransferResult)
+	r := &Runner{}
+	go func() { done <- r.runOpenLoop(context.Background(), TestConfig{}, testCycler(1), schedule, 2, send) }()
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	results := <-done
+
+	var success, dropped int
+	for _, res := range results {
+		switch res.Status {
+		case "success":
+			success++
+		case "dropped":
+			dropped++
+			if !strings.Contains(res.Error, "in-flight limit of 2 reached") || res.ScheduledAt.IsZero() {
+				t.Errorf("dropped result %+v, want the limit and its schedule", res)
+			}
+		}
+	}
+	if success != 2 || dropped != 6 {
+		t.Errorf("%d sent and %d dropped, want 2 and 6", success, dropped)
+	}
+}
+
+func TestRunOpenLoopMarksLateArrivals(t *testing.T) {
+	// Ten arrivals are already 100ms or more overdue when the run starts;
+	// the rest are in the future.
+	start := time.Now().Add(-time.Second)
+	schedule := constantRate(start, 10, 13, time.Time{})
+	send := func(ctx context.Context, p transferPair) TransferResult {
+		return TransferResult{Status: "success"}
+	}
+	r := &Runner{}
+	results := r.runOpenLoop(context.Background(), TestConfig{LateThresholdMs: 50}, testCycler(1), schedule, 20, send)
+
+	late := 0
+	for _, res := range results {
+		overdue := !res.ScheduledAt.After(start.Add(900 * time.Millisecond))
+		if res.Late != overdue {
+			t.Errorf("arrival %v after the start: late %v with lag %.1fms, want %v", res.ScheduledAt.Sub(start), res.Late, res.ScheduleLagMs, overdue)
+		}
+		if res.Late {
+			late++
+			if res.ScheduleLagMs < 100 {
+				t.Errorf("late arrival with %.1fms lag, want at least 100ms", res.ScheduleLagMs)
+			}
+		}
+	}
+	if len(results) != 13 || late != 10 {
+		t.Errorf("%d results, %d late; want 13 and 10", len(results), late)
+	}
+
+	var out bytes.Buffer
+	printSchedule(&out, "SCHEDULE", results)
+	for _, want := range []string{"SCHEDULE\n", "Scheduled: 13 | Dispatched: 13 | Dropped: 0 | Late: 10", "Offered rate: 10.00 tx/s over 1.2s"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("printSchedule output %q, want %q", out.String(), want)
+		}
+	}
+}
+
+func TestRunOpenLoopStopsWaitingOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	schedule := constantRate(time.Now(), 0.1, 3, time.Time{}) // 10s apart
+	send := func(ctx context.Context, p transferPair) TransferResult {
+		return TransferResult{Status: "success"}
+	}
+	time.AfterFunc(50*time.Millisecond, cancel)
+	began := time.Now()
+	results := (&Runner{}).runOpenLoop(ctx, TestConfig{}, testCycler(1), schedule, 5, send)
+	if waited := time.Since(began); waited > 2*time.Second {
+		t.Errorf("returned %v after the cancel, want it to stop sleeping", waited)
+	}
+	if len(results) != 1 {
+		t.Errorf("%d results, want only the arrival before the cancel", len(results))
+	}
+}
+
+func TestPrintScheduleCounts(t *testing.T) {
+	at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	results := []TransferResult{
+		{Status: "success", ScheduledAt: at, ScheduleLagMs: 2},
+		{Status: "dropped", ScheduledAt: at.Add(time.Second), ScheduleLagMs: 4},
+		{Status: "success", ScheduledAt: at.Add(2 * time.Second), ScheduleLagMs: 150, Late: true},
+		{Status: "success"}, // closed loop: not scheduled
+	}
+	var out bytes.Buffer
+	printSchedule(&out, "", results)
+	want := "  Offered rate: 1.00 tx/s over 2.0s\n" +
+		"  Scheduled: 3 | Dispatched: 2 | Dropped: 1 | Late: 1\n" +
+		"  Schedule lag: avg 52.0ms | max 150.0ms\n"
+	if out.String() != want {
+		t.Errorf("printSchedule =\n%s\nwant\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	printSchedule(&out, "SCHEDULE", []TransferResult{{Status: "success"}})
+	if out.Len() != 0 {
+		t.Errorf("printed %q for closed-loop results, want nothing", out.String())
+	}
+}
@@ -0,0 +1,141 @@
+package ethload
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Supported values for TestConfig.Scenario.
+const (
+	ScenarioOneToOne   = "one-to-one"
+	ScenarioOneToMany  = "one-to-many"
+	ScenarioManyToOne  = "many-to-one"
+	ScenarioManyToMany = "many-to-many"
+)
+
+// Supported values for TestConfig.Pairing.
+const (
+	PairingFixed      = "fixed"       // zip for one-to-one, full fan-out/fan-in otherwise
+	PairingRoundRobin = "round-robin" // sender i%len(senders) -> recipient i%len(recipients)
+	PairingRandom     = "random"      // shuffle both lists, then round-robin
+)
+
+// transferPair is one sender/recipient lane driven by runScenario.
+type transferPair struct {
+	SenderKey string
+	Sender    common.Address
+	Recipient common.Address
+}
+
+// buildPairs expands the configured senders and recipients into the lanes
+// required by config.Scenario, using config.Pairing to decide who sends to whom.
+func buildPairs(config TestConfig, out io.Writer) ([]transferPair, error) {
+	if len(config.SenderKeys) == 0 {
+		return nil, fmt.Errorf("no sender keys configured")
+	}
+	if len(config.Recipients) == 0 {
+		return nil, fmt.Errorf("no recipients configured")
+	}
+	senders := make([]transferPair, 0, len(config.SenderKeys))
+	for i, sk := range config.SenderKeys {
+		_, addr, err := loadPrivateKey(sk)
+		if err != nil {
+			return nil, fmt.Errorf("sender key %d: %w", i+1, err)
+		}
+		senders = append(senders, transferPair{SenderKey: sk, Sender: addr})
+	}
+	recipients := make([]common.Address, 0, len(config.Recipients))
+	for _, r := range config.Recipients {
+		if !common.IsHexAddress(r) {
+			return nil, fmt.Errorf("invalid recipient address %q", r)
+		}
+		recipients = append(recipients, common.HexToAddress(r))
+	}
+
+	scenario := strings.ToLower(strings.TrimSpace(config.Scenario))
+	if scenario == "" {
+		scenario = ScenarioManyToMany
+	}
+	switch scenario {
+	case ScenarioOneToOne, ScenarioManyToMany:
+	case ScenarioOneToMany:
+		if len(senders) != 1 {
+			return nil, fmt.Errorf("scenario %s needs exactly 1 sender key, got %d", scenario, len(senders))
+		}
+	case ScenarioManyToOne:
+		if len(recipients) != 1 {
+			return nil, fmt.Errorf("scenario %s needs exactly 1 recipient, got %d", scenario, len(recipients))
+		}
+	default:
+		return nil, fmt.Errorf("unknown scenario %q (want %s, %s, %s or %s)", config.Scenario, ScenarioOneToOne, ScenarioOneToMany, ScenarioManyToOne, ScenarioManyToMany)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(config.Pairing)) {
+	case "", PairingFixed:
+		if scenario != ScenarioOneToOne {
+			return crossPairs(senders, recipients), nil
+		}
+		if len(senders) != len(recipients) {
+			return nil, fmt.Errorf("scenario %s with %s pairing needs as many recipients as senders (got %d senders, %d recipients); use %s or %s pairing to cycle the shorter list",
+				scenario, PairingFixed, len(senders), len(recipients), PairingRoundRobin, PairingRandom)
+		}
+		return roundRobinPairs(senders, recipients), nil
+	case PairingRoundRobin:
+		return roundRobinPairs(senders, recipients), nil
+	case PairingRandom:
+		seed := config.PairingSeed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		printf(out, "Random pairing seed: %d\n", seed)
+		rng := rand.New(rand.NewSource(seed))
+		rng.Shuffle(len(senders), func(i, j int) { senders[i], senders[j] = senders[j], senders[i] })
+		rng.Shuffle(len(recipients), func(i, j int) { recipients[i], recipients[j] = recipients[j], recipients[i] })
+		return roundRobinPairs(senders, recipients), nil
+	default:
+		return nil, fmt.Errorf("unknown pairing %q (want %s, %s or %s)", config.Pairing, PairingFixed, PairingRoundRobin, PairingRandom)
+	}
+}
+
+// crossPairs returns every sender x recipient combination.
+func crossPairs(senders []transferPair, recipients []common.Address) []transferPair {
+	pairs := make([]transferPair, 0, len(senders)*len(recipients))
+	for _, s := range senders {
+		for _, r := range recipients {
+			s.Recipient = r
+			pairs = append(pairs, s)
+		}
+	}
+	return pairs
+}
+
+// roundRobinPairs walks both lists in step, wrapping the shorter one, so every
+// sender and every recipient is used at least once.
+func roundRobinPairs(senders []transferPair, recipients []common.Address) []transferPair {
+	n := len(senders)
+	if len(recipients) > n {
+		n = len(recipients)
+	}
+	pairs := make([]transferPair, 0, n)
+	for i := 0; i < n; i++ {
+		p := senders[i%len(senders)]
+		p.Recipient = recipients[i%len(recipients)]
+		pairs = append(pairs, p)
+	}
+	return pairs
+}
+
+func printPairs(w io.Writer, scenario string, pairs []transferPair) {
+	if scenario == "" {
+		scenario = ScenarioManyToMany
+	}
+	printf(w, "Scenario %s: %d sender/recipient pair(s)\n", scenario, len(pairs))
+	for i, p := range pairs {
+		printf(w, "  %d. %s -> %s\n", i+1, p.Sender.Hex(), p.Recipient.Hex())
+	}
+}
@@ -0,0 +1,147 @@
+package ethload
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestBuildPairs(t *testing.T) {
+	keys := []string{testKeyHex(), testKeyHex(), testKeyHex()}
+	senders := make([]common.Address, len(keys))
+	for i, k := range keys {
+		_, senders[i], _ = loadPrivateKey(k)
+	}
+	recipients := []string{
+		"0x1000000000000000000000000000000000000001",
+		"0x1000000000000000000000000000000000000002",
+		"0x1000000000000000000000000000000000000003",
+	}
+	// lane is a sender/recipient pair by index into senders and recipients.
+	type lane struct{ s, r int }
+
+	tests := []struct {
+		name       string
+		scenario   string
+		pairing    string
+		senders    int
+		recipients int
+		want       []lane
+		wantErr    string
+	}{
+		{name: "one-to-one zips", scenario: ScenarioOneToOne, senders: 3, recipients: 3,
+			want: []lane{{0, 0}, {1, 1}, {2, 2}}},
+		{name: "one-to-one fixed needs equal lists", scenario: ScenarioOneToOne, senders: 3, recipients: 2,
+			wantErr: "needs as many recipients as senders"},
+		{name: "one-to-one round-robin wraps recipients", scenario: ScenarioOneToOne, pairing: PairingRoundRobin, senders: 3, recipients: 2,
+			want: []lane{{0, 0}, {1, 1}, {2, 0}}},
+		{name: "one-to-one round-robin wraps senders", scenario: ScenarioOneToOne, pairing: "Round-Robin", senders: 1, recipients: 3,
+			want: []lane{{0, 0}, {0, 1}, {0, 2}}},
+		{name: "one-to-many fans out", scenario: ScenarioOneToMany, senders: 1, recipients: 3,
+			want: []lane{{0, 0}, {0, 1}, {0, 2}}},
+		{name: "one-to-many needs one sender", scenario: ScenarioOneToMany, senders: 2, recipients: 3,
+			wantErr: "needs exactly 1 sender key"},
+		{name: "many-to-one fans in", scenario: ScenarioManyToOne, senders: 3, recipients: 1,
+			want: []lane{{0, 0}, {1, 0}, {2, 0}}},
+		{name: "many-to-one needs one recipient", scenario: ScenarioManyToOne, senders: 3, recipients: 2,
+			wantErr: "needs exactly 1 recipient"},
+		{name: "many-to-many crosses", scenario: ScenarioManyToMany, senders: 2, recipients: 2,
+			want: []lane{{0, 0}, {0, 1}, {1, 0}, {1, 1}}},
+		{name: "default scenario is many-to-many", senders: 2, recipients: 2,
+			want: []lane{{0, 0}, {0, 1}, {1, 0}, {1, 1}}},
+		{name: "many-to-many round-robin", scenario: ScenarioManyToMany, pairing: PairingRoundRobin, senders: 2, recipients: 3,
+			want: []lane{{0, 0}, {1, 1}, {0, 2}}},
+		{name: "unknown scenario", scenario: "all-to-all", senders: 1, recipients: 1,
+			wantErr: "unknown scenario"},
+		{name: "unknown pairing", pairing: "zigzag", senders: 1, recipients: 1,
+			wantErr: "unknown pairing"},
+		{name: "no senders", senders: 0, recipients: 1,
+			wantErr: "no sender keys"},
+		{name: "no recipients", senders: 1, recipients: 0,
+			wantErr: "no recipients"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := TestConfig{
+				Scenario:   tt.scenario,
+				Pairing:    tt.pairing,
+				SenderKeys: keys[:tt.senders],
+				Recipients: recipients[:tt.recipients],
+			}
+			pairs, err := buildPairs(config, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pairs) != len(tt.want) {
+				t.Fatalf("got %d pairs, want %d", len(pairs), len(tt.want))
+			}
+			for i, w := range tt.want {
+				p := pairs[i]
+				if p.Sender != senders[w.s] || p.SenderKey != keys[w.s] || p.Recipient != common.HexToAddress(recipients[w.r]) {
+					t.Errorf("pair %d = %s -> %s, want sender %d -> recipient %d", i, p.Sender.Hex(), p.Recipient.Hex(), w.s, w.r)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildPairsInvalidInput(t *testing.T) {
+	if _, err := buildPairs(TestConfig{SenderKeys: []string{"not-a-key"}, Recipients: []string{"0x1000000000000000000000000000000000000001"}}, nil); err == nil {
+		t.Error("invalid sender key accepted")
+	}
+	if _, err := buildPairs(TestConfig{SenderKeys: []string{testKeyHex()}, Recipients: []string{"0x123"}}, nil); err == nil {
+		t.Error("invalid recipient accepted")
+	}
+}
+
+func TestBuildPairsRandomSeed(t *testing.T) {
+	config := TestConfig{
+		Pairing:     PairingRandom,
+		PairingSeed: 42,
+		SenderKeys:  []string{testKeyHex(), testKeyHex(), testKeyHex()},
+		Recipients: []string{
+			"0x1000000000000000000000000000000000000001",
+			"0x1000000000000000000000000000000000000002",
+		},
+	}
+	first, err := buildPairs(config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := buildPairs(config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 3 {
+		t.Fatalf("got %d pairs, want 3", len(first))
+	}
+	usedSenders, usedRecipients := map[common.Address]bool{}, map[common.Address]bool{}
+	for i := range first {
+		if first[i] != second[i] {
+			t.Errorf("pair %d differs between runs with the same seed", i)
+		}
+		usedSenders[first[i].Sender] = true
+		usedRecipients[first[i].Recipient] = true
+	}
+	if len(usedSenders) != 3 || len(usedRecipients) != 2 {
+		t.Errorf("used %d senders and %d recipients, want every one", len(usedSenders), len(usedRecipients))
+	}
+}
+
+// testKeyHex returns a fresh hex private key.
+func testKeyHex() string {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(crypto.FromECDSA(key))
+}
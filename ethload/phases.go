@@ -0,0 +1,210 @@
+package ethload
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// phaseTick is how often a closed-loop phase re-evaluates its worker count.
+const phaseTick = 100 * time.Millisecond
+
+// PhaseConfig is one stage of a multi-phase load profile (warmup, ramp, steady,
+// spike, cooldown, ...). A phase is open-loop when it sets targetTps and
+// closed-loop otherwise. Levels ramp linearly from the start* value to the final
+// value over the phase; leave start* at 0 to hold the final value throughout.
+type PhaseConfig struct {
+	Name             string  `json:"name"`
+	Duration         string  `json:"duration"`         // e.g. "30s", "5m"
+	Concurrency      int     `json:"concurrency"`      // closed-loop workers at the end of the phase (default maxGoroutines)
+	StartConcurrency int     `json:"startConcurrency"` // closed-loop workers at the start of the phase
+	TargetTPS        float64 `json:"targetTps"`        // open-loop rate at the end of the phase
+	StartTPS         float64 `json:"startTps"`         // open-loop rate at the start of the phase
+}
+
+// loadPhase is a validated PhaseConfig.
+type loadPhase struct {
+	name     string
+	duration time.Duration
+	openLoop bool
+	from, to float64 // workers or tx/s
+}
+
+// level returns the linearly interpolated load level elapsed into the phase.
+func (p loadPhase) level(elapsed time.Duration) float64 {
+	if elapsed >= p.duration {
+		return p.to
+	}
+	return p.from + (p.to-p.from)*float64(elapsed)/float64(p.duration)
+}
+
+func parsePhases(config TestConfig) ([]loadPhase, error) {
+	defaultConcurrency := config.MaxGoroutines
+	if defaultConcurrency <= 0 {
+		defaultConcurrency = 5
+	}
+	phases := make([]loadPhase, 0, len(config.Phases))
+	for i, pc := range config.Phases {
+		name := strings.TrimSpace(pc.Name)
+		if name == "" {
+			name = fmt.Sprintf("phase-%d", i+1)
+		}
+		d, err := time.ParseDuration(pc.Duration)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("phase %s: invalid duration %q", name, pc.Duration)
+		}
+		ph := loadPhase{name: name, duration: d}
+		switch {
+		case pc.TargetTPS > 0:
+			if pc.Concurrency > 0 || pc.StartConcurrency > 0 {
+				return nil, fmt.Errorf("phase %s: set either targetTps or concurrency, not both", name)
+			}
+			ph.openLoop = true
+			ph.from, ph.to = pc.TargetTPS, pc.TargetTPS
+			if pc.StartTPS > 0 {
+				ph.from = pc.StartTPS
+			}
+		case pc.StartTPS > 0:
+			return nil, fmt.Errorf("phase %s: startTps needs targetTps", name)
+		default:
+			ph.to = float64(defaultConcurrency)
+			if pc.Concurrency > 0 {
+				ph.to = float64(pc.Concurrency)
+			}
+			ph.from = ph.to
+			if pc.StartConcurrency > 0 {
+				ph.from = float64(pc.StartConcurrency)
+			}
+		}
+		phases = append(phases, ph)
+	}
+	return phases, nil
+}
+
+// runPhases runs config.Phases back to back, cycling through pairs across
+// phases, and tags every result with the phase that produced it. A phase ends
+// once its duration has passed and its in-flight transfers have finished or
+// used up the grace period.
+func (r *Runner) runPhases(ctx context.Context, config TestConfig, pairs []transferPair, grace time.Duration, send sendFunc) ([]TransferResult, error) {
+	phases, err := parsePhases(config)
+	if err != nil {
+		return nil, err
+	}
+	peakTPS := 0.0
+	for _, ph := range phases {
+		if ph.openLoop {
+			peakTPS = math.Max(peakTPS, math.Max(ph.from, ph.to))
+		}
+	}
+	maxInFlight := openLoopInFlight(config, peakTPS)
+	delay := time.Duration(config.Delay) * time.Millisecond
+
+	cycler := &pairCycler{pairs: pairs}
+	var results []TransferResult
+	for _, ph := range phases {
+		if ctx.Err() != nil {
+			break
+		}
+		var phaseResults []TransferResult
+		start := time.Now()
+		phaseCtx, cancel := context.WithDeadline(ctx, start.Add(ph.duration+grace))
+		if ph.openLoop {
+			printf(r.Output, "\nPhase %s: %s open-loop, %.2f -> %.2f tx/s\n", ph.name, ph.duration, ph.from, ph.to)
+			phaseResults = r.runOpenLoop(phaseCtx, config, cycler, rampRate(ph, start), maxInFlight, send)
+		} else {
+			printf(r.Output, "\nPhase %s: %s closed-loop, %.0f -> %.0f workers\n", ph.name, ph.duration, ph.from, ph.to)
+			phaseResults = runClosedPhase(phaseCtx, ph, cycler, delay, send)
+		}
+		cancel()
+		for i := range phaseResults {
+			phaseResults[i].Phase = ph.name
+		}
+		results = append(results, phaseResults...)
+	}
+	return results, nil
+}
+
+// rampRate schedules arrivals for an open-loop phase starting at start, spacing
+// each arrival by the rate in effect when the previous one was due.
+func rampRate(ph loadPhase, start time.Time) arrivalSchedule {
+	end := start.Add(ph.duration)
+	return func(prev time.Time) (time.Time, bool) {
+		next := start
+		if !prev.IsZero() {
+			rate := ph.level(prev.Sub(start))
+			next = prev.Add(time.Duration(float64(time.Second) / rate))
+		}
+		if !next.Before(end) {
+			return time.Time{}, false
+		}
+		return next, true
+	}
+}
+
+// runClosedPhase keeps a ramping number of workers sending until the phase
+// deadline, each pausing delay between its transfers. Workers above the current
+// level idle until the level rises again or the phase ends. Transfers run under
+// ctx.
+func runClosedPhase(ctx context.Context, ph loadPhase, cycler *pairCycler, delay time.Duration, send sendFunc) []TransferResult {
+	var results []TransferResult
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var want atomic.Int64
+
+	start := time.Now()
+	deadline := start.Add(ph.duration)
+	worker := func(id int64) {
+		defer wg.Done()
+		for time.Now().Before(deadline) && ctx.Err() == nil {
+			if id >= want.Load() {
+				time.Sleep(phaseTick)
+				continue
+			}
+			r := send(ctx, cycler.next())
+			mu.Lock()
+			results = append(results, r)
+			mu.Unlock()
+			if delay > 0 {
+				time.Sleep(delay)
+			}
+		}
+	}
+
+	var spawned int64
+	for now := start; now.Before(deadline) && ctx.Err() == nil; now = time.Now() {
+		level := int64(math.Round(ph.level(now.Sub(start))))
+		want.Store(level)
+		for ; spawned < level; spawned++ {
+			wg.Add(1)
+			go worker(spawned)
+		}
+		time.Sleep(phaseTick)
+	}
+	wg.Wait()
+	return results
+}
+
+// printPhases prints a summary block per phase, in the order phases ran.
+func printPhases(w io.Writer, results []TransferResult) {
+	order, byPhase := groupByPhase(results)
+	if len(order) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\n========== PHASES ==========")
+	for _, name := range order {
+		rs := byPhase[name]
+		s := summarize(rs)
+		fmt.Fprintf(w, "%s: Total: %d | Success: %d | Failed: %d\n", name, s.Total, s.Success, s.Failed)
+		if s.SubmitLatency.Count > 0 {
+			fmt.Fprintf(w, "  Submit: %s\n", formatLatency(s.SubmitLatency))
+			fmt.Fprintf(w, "  Inclusion: %s\n", formatLatency(s.InclusionLatency))
+			fmt.Fprintf(w, "  Throughput: %.2f submitted/s | %.2f confirmed/s\n", s.SubmitTPS, s.ConfirmTPS)
+		}
+		printSchedule(w, "", rs)
+	}
+}
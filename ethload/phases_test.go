@@ -0,0 +1,132 @@
+package ethload
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParsePhases(t *testing.T) {
+	config := TestConfig{MaxGoroutines: 4, Phases: []PhaseConfig{
+		{Name: "warmup", Duration: "10s", StartConcurrency: 1},
+		{Duration: "1m", StartTPS: 5, TargetTPS: 20},
+		{Name: " steady ", Duration: "30s", Concurrency: 8},
+	}}
+	phases, err := parsePhases(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []loadPhase{
+		{name: "warmup", duration: 10 * time.Second, from: 1, to: 4},
+		{name: "phase-2", duration: time.Minute, openLoop: true, from: 5, to: 20},
+		{name: "steady", duration: 30 * time.Second, from: 8, to: 8},
+	}
+	if len(phases) != len(want) {
+		t.Fatalf("%d phases, want %d", len(phases), len(want))
+	}
+	for i := range want {
+		if phases[i] != want[i] {
+			t.Errorf("phase %d = %+v, want %+v", i, phases[i], want[i])
+		}
+	}
+	if got := phases[1].level(30 * time.Second); got != 12.5 {
+		t.Errorf("level halfway through the ramp = %v, want 12.5", got)
+	}
+	if got := phases[1].level(2 * time.Minute); got != 20 {
+		t.Errorf("level after the phase = %v, want 20", got)
+	}
+
+	for _, bad := range []PhaseConfig{
+		{Name: "x", Duration: "soon"},
+		{Name: "x", Duration: "-1s"},
+		{Name: "x", Duration: "1s", TargetTPS: 5, Concurrency: 2},
+		{Name: "x", Duration: "1s", StartTPS: 5},
+	} {
+		if _, err := parsePhases(TestConfig{Phases: []PhaseConfig{bad}}); err == nil {
+			t.Errorf("parsePhases(%+v) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestRunPhases(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs phases in real time")
+	}
+	config := TestConfig{
+		Delay: 50,
+		Phases: []PhaseConfig{
+			{Name: "paced", Duration: "300ms", Concurrency: 1},
+			{Name: "burst", Duration: "200ms", TargetTPS: 50},
+		},
+	}
+	var mu sync.Mutex
+	var sent []time.Time
+	send := func(ctx context.Context, p transferPair) TransferResult {
+		now := time.Now()
+		mu.Lock()
+		sent = append(sent, now)
+		mu.Unlock()
+		return TransferResult{Status: "success", SubmittedAt: now}
+	}
+	r := &Runner{}
+	start := time.Now()
+	results, err := r.runPhases(context.Background(), config, testCycler(2).pairs, 100*time.Millisecond, send)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	order, byPhase := groupByPhase(results)
+	if strings.Join(order, ",") != "paced,burst" {
+		t.Fatalf("phases ran in order %v, want paced then burst", order)
+	}
+	// One worker pausing 50ms between sends gets at most 7 into 300ms;
+	// without the delay it would send thousands.
+	paced := byPhase["paced"]
+	if n := len(paced); n < 3 || n > 7 {
+		t.Errorf("paced phase sent %d transfers, want 3-7 at one per 50ms", n)
+	}
+	for i := 1; i < len(paced); i++ {
+		if gap := paced[i].SubmittedAt.Sub(paced[i-1].SubmittedAt); gap < 50*time.Millisecond {
+			t.Errorf("paced sends %v apart, want at least the 50ms delay", gap)
+		}
+	}
+	// The burst starts only once the paced phase is over and schedules
+	// arrivals at 50 tx/s over its own 200ms.
+	pacedEnd := paced[len(paced)-1].SubmittedAt
+	burst := byPhase["burst"]
+	if len(burst) != 10 {
+		t.Errorf("burst phase ran %d arrivals, want 10", len(burst))
+	}
+	for _, res := range burst {
+		if res.ScheduledAt.IsZero() {
+			t.Errorf("burst result %+v has no schedule, want open-loop", res)
+		}
+		if !res.SubmittedAt.After(pacedEnd) {
+			t.Errorf("burst send %v before the paced phase ended", pacedEnd.Sub(res.SubmittedAt))
+		}
+		if !res.ScheduledAt.After(start.Add(300 * time.Millisecond)) {
+			t.Errorf("burst arrival scheduled %v into the run, want after the 300ms paced phase", res.ScheduledAt.Sub(start))
+		}
+	}
+	if len(results) != len(sent) {
+		t.Errorf("%d results from %d sends", len(results), len(sent))
+	}
+
+	var out bytes.Buffer
+	printPhases(&out, results)
+	for _, want := range []string{
+		"========== PHASES ==========",
+		"burst: Total: 10 | Success: 10 | Failed: 0",
+		"Scheduled: 10 | Dispatched: 10 | Dropped: 0",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("printPhases output %q, want %q", out.String(), want)
+		}
+	}
+	if strings.Index(out.String(), "paced:") > strings.Index(out.String(), "burst:") {
+		t.Errorf("printPhases output %q, want paced before burst", out.String())
+	}
+}
@@ -0,0 +1,323 @@
+package ethload
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// defaultHeadPollInterval is how often the receipt tracker asks for the chain
+// head when the RPC endpoint cannot push new heads.
+const defaultHeadPollInterval = time.Second
+
+// reorgWindow is how many processed block hashes are kept to detect reorgs.
+const reorgWindow = 128
+
+// receiptTracker follows the chain head for the whole run and collects the
+// receipts of watched transactions from each new block, so waiting transfers
+// cost no RPC calls of their own.
+type receiptTracker struct {
+	client  Client
+	out     io.Writer // progress and warnings; nil discards them
+	mu      sync.Mutex
+	watched map[common.Hash]bool
+	found   map[common.Hash]*types.Receipt
+	seen    map[common.Hash]time.Time // when each found receipt was first seen
+	blocks  map[uint64]common.Hash    // processed block hashes, for reorg detection
+	head    uint64                    // last processed block
+	changed chan struct{}             // closed and replaced after every processed block
+	// blockReceipts is cleared if the endpoint lacks eth_getBlockReceipts;
+	// matching then falls back to the block's tx list.
+	blockReceipts bool
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// startReceiptTracker starts following new heads: over a subscription when the
+// endpoint supports one (websocket/IPC), by polling every pollInterval otherwise.
+func startReceiptTracker(client Client, pollInterval time.Duration, out io.Writer) (*receiptTracker, error) {
+	head, err := client.HeaderByNumber(context.Background(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chain head: %w", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	rt := newReceiptTracker(client, head, out)
+	rt.cancel = cancel
+	go rt.follow(ctx, pollInterval)
+	return rt, nil
+}
+
+// newReceiptTracker returns a tracker that has processed up to head, for
+// follow to advance.
+func newReceiptTracker(client Client, head *types.Header, out io.Writer) *receiptTracker {
+	return &receiptTracker{
+		client:        client,
+		out:           out,
+		watched:       make(map[common.Hash]bool),
+		found:         make(map[common.Hash]*types.Receipt),
+		seen:          make(map[common.Hash]time.Time),
+		blocks:        map[uint64]common.Hash{head.Number.Uint64(): head.Hash()},
+		head:          head.Number.Uint64(),
+		changed:       make(chan struct{}),
+		blockReceipts: true,
+		done:          make(chan struct{}),
+	}
+}
+
+func (rt *receiptTracker) stop() {
+	rt.cancel()
+	<-rt.done
+}
+
+func (rt *receiptTracker) follow(ctx context.Context, pollInterval time.Duration) {
+	defer close(rt.done)
+	if err := rt.subscribe(ctx); err != nil && ctx.Err() == nil {
+		printf(rt.out, "Polling for new heads every %s (%v)\n", pollInterval, err)
+		rt.poll(ctx, pollInterval)
+	}
+}
+
+// subscribe processes pushed heads until ctx ends or the subscription fails.
+func (rt *receiptTracker) subscribe(ctx context.Context) error {
+	heads := make(chan *types.Header, 16)
+	sub, err := rt.client.SubscribeNewHead(ctx, heads)
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+	printf(rt.out, "Following new heads over a subscription\n")
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-sub.Err():
+			return fmt.Errorf("head subscription failed: %w", err)
+		case h := <-heads:
+			if err := rt.advance(ctx, h); err != nil && ctx.Err() == nil {
+				printf(rt.out, "receipt tracker: %v\n", err)
+			}
+		}
+	}
+}
+
+func (rt *receiptTracker) poll(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h, err := rt.client.HeaderByNumber(ctx, nil)
+			if err == nil {
+				err = rt.advance(ctx, h)
+			}
+			if err != nil && ctx.Err() == nil {
+				printf(rt.out, "receipt tracker: %v\n", err)
+			}
+		}
+	}
+}
+
+// advance processes every block up to head, first rewinding past any blocks
+// a reorg replaced.
+func (rt *receiptTracker) advance(ctx context.Context, head *types.Header) error {
+	target := head.Number.Uint64()
+	rt.mu.Lock()
+	last := rt.head
+	seen := rt.blocks[target] == head.Hash()
+	rt.mu.Unlock()
+	if seen {
+		return nil
+	}
+
+	// Find the highest processed block that is still canonical.
+	fork := min(last, target-1)
+	for fork > 0 {
+		rt.mu.Lock()
+		known, ok := rt.blocks[fork]
+		rt.mu.Unlock()
+		if !ok {
+			break
+		}
+		var canonical common.Hash
+		if fork == target-1 {
+			canonical = head.ParentHash
+		} else {
+			h, err := rt.client.HeaderByNumber(ctx, new(big.Int).SetUint64(fork))
+			if err != nil {
+				return fmt.Errorf("failed to get block %d: %w", fork, err)
+			}
+			canonical = h.Hash()
+		}
+		if canonical == known {
+			break
+		}
+		fork--
+	}
+	if fork < last {
+		rt.rewind(fork)
+	}
+
+	for n := fork + 1; n <= target; n++ {
+		h := head
+		if n < target {
+			var err error
+			if h, err = rt.client.HeaderByNumber(ctx, new(big.Int).SetUint64(n)); err != nil {
+				return fmt.Errorf("failed to get block %d: %w", n, err)
+			}
+		}
+		if err := rt.processBlock(ctx, h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewind forgets every block above fork and the receipts found in them.
+func (rt *receiptTracker) rewind(fork uint64) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	for n := range rt.blocks {
+		if n > fork {
+			delete(rt.blocks, n)
+		}
+	}
+	for hash, r := range rt.found {
+		if r.BlockNumber.Uint64() > fork {
+			delete(rt.found, hash)
+			delete(rt.seen, hash)
+		}
+	}
+	rt.head = fork
+	printf(rt.out, "receipt tracker: reorg, rewound to block %d\n", fork)
+}
+
+// processBlock records the receipts of watched transactions in block h.
+func (rt *receiptTracker) processBlock(ctx context.Context, h *types.Header) error {
+	matched, err := rt.blockMatches(ctx, h)
+	if err != nil {
+		return err
+	}
+	n, now := h.Number.Uint64(), time.Now()
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	for _, r := range matched {
+		rt.found[r.TxHash] = r
+		if _, ok := rt.seen[r.TxHash]; !ok {
+			rt.seen[r.TxHash] = now
+		}
+	}
+	rt.blocks[n] = h.Hash()
+	delete(rt.blocks, n-reorgWindow)
+	rt.head = n
+	close(rt.changed)
+	rt.changed = make(chan struct{})
+	return nil
+}
+
+// blockMatches returns the receipts of watched transactions in block h.
+func (rt *receiptTracker) blockMatches(ctx context.Context, h *types.Header) ([]*types.Receipt, error) {
+	rt.mu.Lock()
+	useBlockReceipts, watching := rt.blockReceipts, len(rt.watched) > 0
+	rt.mu.Unlock()
+	if !watching {
+		return nil, nil
+	}
+	var matched []*types.Receipt
+	if useBlockReceipts {
+		all, err := rt.client.BlockReceipts(ctx, rpc.BlockNumberOrHashWithHash(h.Hash(), false))
+		if err == nil {
+			rt.mu.Lock()
+			for _, r := range all {
+				if rt.watched[r.TxHash] {
+					matched = append(matched, r)
+				}
+			}
+			rt.mu.Unlock()
+			return matched, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		printf(rt.out, "receipt tracker: eth_getBlockReceipts failed (%v); matching block transactions instead\n", err)
+		rt.mu.Lock()
+		rt.blockReceipts = false
+		rt.mu.Unlock()
+	}
+	block, err := rt.client.BlockByHash(ctx, h.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block %d: %w", h.Number, err)
+	}
+	for _, tx := range block.Transactions() {
+		rt.mu.Lock()
+		watched := rt.watched[tx.Hash()]
+		rt.mu.Unlock()
+		if !watched {
+			continue
+		}
+		r, err := rt.client.TransactionReceipt(ctx, tx.Hash())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get receipt %s: %w", tx.Hash().Hex(), err)
+		}
+		matched = append(matched, r)
+	}
+	return matched, nil
+}
+
+// watch starts collecting the receipt of hash. The receipt is looked up once
+// directly, in case the tx was mined before it was watched.
+func (rt *receiptTracker) watch(ctx context.Context, hash common.Hash) {
+	rt.mu.Lock()
+	rt.watched[hash] = true
+	rt.mu.Unlock()
+	r, err := rt.client.TransactionReceipt(ctx, hash)
+	if err != nil {
+		return
+	}
+	rt.mu.Lock()
+	if _, ok := rt.found[hash]; !ok && rt.watched[hash] {
+		rt.found[hash] = r
+		rt.seen[hash] = time.Now()
+	}
+	rt.mu.Unlock()
+}
+
+func (rt *receiptTracker) unwatch(hashes ...common.Hash) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	for _, h := range hashes {
+		delete(rt.watched, h)
+		delete(rt.found, h)
+		delete(rt.seen, h)
+	}
+}
+
+// seenAt returns when the receipt of hash was first seen, or the zero time if
+// it has not been.
+func (rt *receiptTracker) seenAt(hash common.Hash) time.Time {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return rt.seen[hash]
+}
+
+// status returns the receipt of the newest of hashes that has one, the last
+// processed block, and a channel closed once the next block is processed.
+func (rt *receiptTracker) status(hashes []common.Hash) (*types.Receipt, uint64, <-chan struct{}) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	for i := len(hashes) - 1; i >= 0; i-- {
+		if r, ok := rt.found[hashes[i]]; ok {
+			return r, rt.head, rt.changed
+		}
+	}
+	return nil, rt.head, rt.changed
+}
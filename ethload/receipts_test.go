@@ -0,0 +1,445 @@
+package ethload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// fakeChain is a Client serving a header sequence that tests can reorg. Every
+// block ever built stays retrievable by hash, as orphaned blocks do on a node.
+type fakeChain struct {
+	Client
+	mu         sync.Mutex
+	canonical  []*types.Header // by number
+	byHash     map[common.Hash]*types.Header
+	txs        map[common.Hash][]*types.Transaction // by block hash
+	calls      int                                  // BlockReceipts calls
+	noReceipts bool                                 // no eth_getBlockReceipts
+	subscribe  bool
+	heads      chan<- *types.Header
+	subErr     chan error
+	subscribed chan struct{}
+}
+
+func newFakeChain() *fakeChain {
+	c := &fakeChain{
+		byHash:     make(map[common.Hash]*types.Header),
+		txs:        make(map[common.Hash][]*types.Transaction),
+		subErr:     make(chan error, 1),
+		subscribed: make(chan struct{}),
+	}
+	c.build(0, 'a', 0, nil)
+	return c
+}
+
+// build replaces every block above fork with blocks up to head, tagged so
+// their hashes differ from the ones they replace, mining each tx of mined in
+// its block. It returns the new head.
+func (c *fakeChain) build(fork uint64, tag byte, head uint64, mined map[*types.Transaction]uint64) *types.Header {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.canonical) > 0 {
+		c.canonical = c.canonical[:fork+1]
+	}
+	for n := uint64(len(c.canonical)); n <= head; n++ {
+		h := &types.Header{Number: new(big.Int).SetUint64(n), Extra: []byte{tag}, Difficulty: common.Big0}
+		if n > 0 {
+			h.ParentHash = c.canonical[n-1].Hash()
+		}
+		var txs []*types.Transaction
+		for tx, at := range mined {
+			if at == n {
+				txs = append(txs, tx)
+			}
+		}
+		c.canonical = append(c.canonical, h)
+		c.byHash[h.Hash()] = h
+		c.txs[h.Hash()] = txs
+	}
+	return c.canonical[head]
+}
+
+func (c *fakeChain) header(n uint64) *types.Header {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.canonical[n]
+}
+
+func (c *fakeChain) receipt(tx *types.Transaction, h *types.Header) *types.Receipt {
+	return &types.Receipt{
+		Status:      types.ReceiptStatusSuccessful,
+		TxHash:      tx.Hash(),
+		BlockHash:   h.Hash(),
+		BlockNumber: new(big.Int).Set(h.Number),
+	}
+}
+
+func (c *fakeChain) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if number == nil {
+		return c.canonical[len(c.canonical)-1], nil
+	}
+	if !number.IsUint64() || number.Uint64() >= uint64(len(c.canonical)) {
+		return nil, ethereum.NotFound
+	}
+	return c.canonical[number.Uint64()], nil
+}
+
+func (c *fakeChain) BlockReceipts(ctx context.Context, block rpc.BlockNumberOrHash) ([]*types.Receipt, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls++
+	if c.noReceipts {
+		return nil, errors.New("the method eth_getBlockReceipts does not exist/is not available")
+	}
+	hash, _ := block.Hash()
+	h, ok := c.byHash[hash]
+	if !ok {
+		return nil, ethereum.NotFound
+	}
+	var receipts []*types.Receipt
+	for _, tx := range c.txs[hash] {
+		receipts = append(receipts, c.receipt(tx, h))
+	}
+	return receipts, nil
+}
+
+func (c *fakeChain) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h, ok := c.byHash[hash]
+	if !ok {
+		return nil, ethereum.NotFound
+	}
+	return types.NewBlockWithHeader(h).WithBody(types.Body{Transactions: c.txs[hash]}), nil
+}
+
+func (c *fakeChain) TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, h := range c.canonical {
+		for _, tx := range c.txs[h.Hash()] {
+			if tx.Hash() == hash {
+				return c.receipt(tx, h), nil
+			}
+		}
+	}
+	return nil, ethereum.NotFound
+}
+
+func (c *fakeChain) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	if !c.subscribe {
+		return nil, errors.New("notifications not supported")
+	}
+	c.mu.Lock()
+	c.heads = ch
+	c.mu.Unlock()
+	close(c.subscribed)
+	return fakeSubscription{c.subErr}, nil
+}
+
+// push sends head to the subscriber.
+func (c *fakeChain) push(head *types.Header) {
+	c.mu.Lock()
+	heads := c.heads
+	c.mu.Unlock()
+	heads <- head
+}
+
+func (c *fakeChain) blockReceiptsCalls() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls
+}
+
+type fakeSubscription struct{ err chan error }
+
+func (s fakeSubscription) Unsubscribe()      {}
+func (s fakeSubscription) Err() <-chan error { return s.err }
+
+func testTx(nonce uint64) *types.Transaction {
+	return types.NewTx(&types.LegacyTx{Nonce: nonce, Gas: 21000, GasPrice: common.Big1})
+}
+
+func TestReceiptTrackerAdvance(t *testing.T) {
+	a, b := testTx(1), testTx(2)
+	names := map[*types.Transaction]string{a: "a", b: "b"}
+
+	// step builds the blocks above fork up to head, then advances to head.
+	type step struct {
+		fork, head uint64
+		mined      map[*types.Transaction]uint64
+	}
+	tests := []struct {
+		name      string
+		steps     []step
+		want      map[string]uint64 // tx name -> block it was found in
+		wantHead  uint64
+		wantCalls int // BlockReceipts calls
+		wantReorg string
+	}{
+		{name: "processes every block up to a skipped-to head",
+			steps:    []step{{0, 5, map[*types.Transaction]uint64{a: 2, b: 5}}},
+			want:     map[string]uint64{"a": 2, "b": 5},
+			wantHead: 5, wantCalls: 5},
+		{name: "repeated head is processed once",
+			steps:    []step{{0, 3, map[*types.Transaction]uint64{a: 3}}, {3, 3, nil}},
+			want:     map[string]uint64{"a": 3},
+			wantHead: 3, wantCalls: 3},
+		{name: "nothing watched fetches no receipts",
+			steps:    []step{{0, 4, nil}},
+			wantHead: 4},
+		{name: "reorg moves a tx to a later block",
+			steps: []step{
+				{0, 5, map[*types.Transaction]uint64{a: 3}},
+				{2, 6, map[*types.Transaction]uint64{a: 4}},
+			},
+			want:     map[string]uint64{"a": 4},
+			wantHead: 6, wantCalls: 5 + 4, wantReorg: "rewound to block 2"},
+		{name: "reorg of the tip drops its tx",
+			steps: []step{
+				{0, 5, map[*types.Transaction]uint64{a: 5, b: 2}},
+				{4, 5, nil},
+			},
+			want:     map[string]uint64{"b": 2},
+			wantHead: 5, wantCalls: 5 + 1, wantReorg: "rewound to block 4"},
+		{name: "reorg to a shorter chain",
+			steps: []step{
+				{0, 5, map[*types.Transaction]uint64{a: 4}},
+				{2, 3, nil},
+			},
+			wantHead: 3, wantCalls: 5 + 1, wantReorg: "rewound to block 2"},
+		{name: "reorg below the new head's parent is found by walking back",
+			steps: []step{
+				{0, 5, map[*types.Transaction]uint64{a: 3}},
+				{1, 8, map[*types.Transaction]uint64{b: 7}},
+			},
+			want:     map[string]uint64{"b": 7},
+			wantHead: 8, wantCalls: 5 + 7, wantReorg: "rewound to block 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			chain := newFakeChain()
+			var out bytes.Buffer
+			rt := newReceiptTracker(chain, chain.header(0), &out)
+			for i, s := range tt.steps {
+				for tx := range s.mined {
+					rt.watch(ctx, tx.Hash())
+				}
+				head := chain.build(s.fork, byte('a'+i), s.head, s.mined)
+				if err := rt.advance(ctx, head); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			found := make(map[string]uint64)
+			for tx, name := range names {
+				r, _, _ := rt.status([]common.Hash{tx.Hash()})
+				if r == nil {
+					continue
+				}
+				found[name] = r.BlockNumber.Uint64()
+				if canonical := chain.header(r.BlockNumber.Uint64()).Hash(); r.BlockHash != canonical {
+					t.Errorf("tx %s found in orphaned block %s", name, r.BlockHash.Hex())
+				}
+			}
+			if len(found) != len(tt.want) {
+				t.Errorf("found %v, want %v", found, tt.want)
+			}
+			for name, n := range tt.want {
+				if found[name] != n {
+					t.Errorf("tx %s found in block %d, want %d", name, found[name], n)
+				}
+			}
+			if _, head, _ := rt.status(nil); head != tt.wantHead {
+				t.Errorf("head = %d, want %d", head, tt.wantHead)
+			}
+			if calls := chain.blockReceiptsCalls(); calls != tt.wantCalls {
+				t.Errorf("BlockReceipts called %d times, want %d", calls, tt.wantCalls)
+			}
+			reorged := strings.Contains(out.String(), "reorg")
+			if tt.wantReorg == "" && reorged {
+				t.Errorf("unexpected reorg: %q", out.String())
+			}
+			if tt.wantReorg != "" && !strings.Contains(out.String(), tt.wantReorg) {
+				t.Errorf("output %q, want %q", out.String(), tt.wantReorg)
+			}
+		})
+	}
+}
+
+func TestReceiptTrackerWindow(t *testing.T) {
+	ctx := context.Background()
+	chain := newFakeChain()
+	var out bytes.Buffer
+	rt := newReceiptTracker(chain, chain.header(0), &out)
+	tx := testTx(1)
+	rt.watch(ctx, tx.Hash())
+	if err := rt.advance(ctx, chain.build(0, 'a', 200, map[*types.Transaction]uint64{tx: 10})); err != nil {
+		t.Fatal(err)
+	}
+	if len(rt.blocks) != reorgWindow {
+		t.Errorf("kept %d block hashes, want %d", len(rt.blocks), reorgWindow)
+	}
+	if _, ok := rt.blocks[200-reorgWindow]; ok {
+		t.Errorf("block %d kept outside the window", 200-reorgWindow)
+	}
+	if _, ok := rt.blocks[200-reorgWindow+1]; !ok {
+		t.Errorf("block %d dropped inside the window", 200-reorgWindow+1)
+	}
+
+	// A reorg deeper than the window can only be rewound to its edge.
+	if err := rt.advance(ctx, chain.build(50, 'b', 201, map[*types.Transaction]uint64{tx: 10})); err != nil {
+		t.Fatal(err)
+	}
+	if want := "rewound to block 72"; !strings.Contains(out.String(), want) {
+		t.Errorf("output %q, want %q", out.String(), want)
+	}
+	if r, head, _ := rt.status([]common.Hash{tx.Hash()}); r == nil || head != 201 {
+		t.Errorf("status = %v, head %d; want the receipt at head 201", r, head)
+	}
+}
+
+func TestReceiptTrackerBlockReceiptsFallback(t *testing.T) {
+	ctx := context.Background()
+	chain := newFakeChain()
+	chain.noReceipts = true
+	var out bytes.Buffer
+	rt := newReceiptTracker(chain, chain.header(0), &out)
+	a, b := testTx(1), testTx(2)
+	rt.watch(ctx, a.Hash())
+	rt.watch(ctx, b.Hash())
+	if err := rt.advance(ctx, chain.build(0, 'a', 4, map[*types.Transaction]uint64{a: 2, b: 4})); err != nil {
+		t.Fatal(err)
+	}
+	if rt.blockReceipts {
+		t.Error("eth_getBlockReceipts still in use after it failed")
+	}
+	if calls := chain.blockReceiptsCalls(); calls != 1 {
+		t.Errorf("BlockReceipts called %d times, want only the first", calls)
+	}
+	if !strings.Contains(out.String(), "matching block transactions instead") {
+		t.Errorf("fallback not reported: %q", out.String())
+	}
+	for tx, n := range map[*types.Transaction]uint64{a: 2, b: 4} {
+		r, _, _ := rt.status([]common.Hash{tx.Hash()})
+		if r == nil || r.BlockNumber.Uint64() != n {
+			t.Errorf("tx %s: receipt %v, want one in block %d", tx.Hash().Hex(), r, n)
+		}
+	}
+}
+
+func TestReceiptTrackerFollow(t *testing.T) {
+	tests := []struct {
+		name      string
+		subscribe bool
+		failSub   bool
+		want      []string
+	}{
+		{name: "polls without subscriptions",
+			want: []string{"Polling for new heads every 10ms (notifications not supported)"}},
+		{name: "follows a subscription", subscribe: true,
+			want: []string{"Following new heads over a subscription"}},
+		{name: "polls after the subscription fails", subscribe: true, failSub: true,
+			want: []string{"Following new heads over a subscription", "Polling for new heads every 10ms (head subscription failed: dropped)"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			chain := newFakeChain()
+			chain.subscribe = tt.subscribe
+			var out bytes.Buffer
+			rt, err := startReceiptTracker(chain, 10*time.Millisecond, &out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tx := testTx(1)
+			rt.watch(ctx, tx.Hash())
+			if tt.subscribe {
+				<-chain.subscribed
+			}
+			if tt.failSub {
+				chain.subErr <- errors.New("dropped")
+			}
+			head := chain.build(0, 'a', 3, map[*types.Transaction]uint64{tx: 2})
+			if tt.subscribe && !tt.failSub {
+				chain.push(head)
+			}
+
+			timeout := time.After(5 * time.Second)
+			for {
+				r, _, changed := rt.status([]common.Hash{tx.Hash()})
+				if r != nil {
+					break
+				}
+				select {
+				case <-changed:
+				case <-timeout:
+					rt.stop()
+					t.Fatalf("receipt not found; output %q", out.String())
+				}
+			}
+			rt.stop()
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output %q, want %q", out.String(), want)
+				}
+			}
+			if !tt.failSub && tt.subscribe && strings.Contains(out.String(), "Polling") {
+				t.Errorf("polled despite the subscription: %q", out.String())
+			}
+		})
+	}
+}
+
+func TestReceiptTrackerSeenAt(t *testing.T) {
+	ctx := context.Background()
+	chain := newFakeChain()
+	rt := newReceiptTracker(chain, chain.header(0), nil)
+	tx := testTx(1)
+	rt.watch(ctx, tx.Hash())
+	if !rt.seenAt(tx.Hash()).IsZero() {
+		t.Fatal("pending tx has a seen time")
+	}
+
+	before := time.Now()
+	if err := rt.advance(ctx, chain.build(0, 'a', 2, map[*types.Transaction]uint64{tx: 2})); err != nil {
+		t.Fatal(err)
+	}
+	first := rt.seenAt(tx.Hash())
+	if first.Before(before) || first.After(time.Now()) {
+		t.Fatalf("seen at %v, want the time block 2 was processed", first)
+	}
+	if err := rt.advance(ctx, chain.build(2, 'a', 4, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if got := rt.seenAt(tx.Hash()); !got.Equal(first) {
+		t.Errorf("seen time moved to %v as the chain grew, want the first sighting %v", got, first)
+	}
+
+	// A reorg that re-mines the tx later restarts the clock.
+	time.Sleep(time.Millisecond)
+	if err := rt.advance(ctx, chain.build(1, 'b', 5, map[*types.Transaction]uint64{tx: 5})); err != nil {
+		t.Fatal(err)
+	}
+	if got := rt.seenAt(tx.Hash()); !got.After(first) {
+		t.Errorf("seen at %v after the reorg, want later than %v", got, first)
+	}
+	rt.unwatch(tx.Hash())
+	if !rt.seenAt(tx.Hash()).IsZero() {
+		t.Error("seen time kept after unwatch")
+	}
+}
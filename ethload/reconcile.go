@@ -0,0 +1,291 @@
+package ethload
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// balances is an ETH and token balance snapshot of a set of addresses.
+type balances map[common.Address]balance
+
+type balance struct {
+	eth, token *big.Int
+}
+
+// moved is the balance a transfer of txType moves: ETH for native transfers,
+// the token otherwise.
+func (b *balance) moved(txType string) *big.Int {
+	if txType == TxTypeNative {
+		return b.eth
+	}
+	return b.token
+}
+
+// BalanceDelta compares an observed balance change with the expected one, in
+// wei or token base units.
+type BalanceDelta struct {
+	Before   string `json:"before"`
+	After    string `json:"after"`
+	Delta    string `json:"delta"`
+	Expected string `json:"expected"`
+	Diff     string `json:"diff,omitempty"` // delta - expected, when they differ
+}
+
+// AddressReconciliation is the reconciliation of one sender or recipient.
+type AddressReconciliation struct {
+	Address string       `json:"address"`
+	ETH     BalanceDelta `json:"eth"`
+	Token   BalanceDelta `json:"token"`
+	OK      bool         `json:"ok"`
+}
+
+// Reconciliation is the pre/post-run balance check written to results.json.
+type Reconciliation struct {
+	OK        bool                    `json:"ok"`
+	Addresses []AddressReconciliation `json:"addresses"`
+}
+
+// reconcileAddresses returns every sender and recipient of the config.
+func reconcileAddresses(config TestConfig) ([]common.Address, error) {
+	seen := make(map[common.Address]bool)
+	var addrs []common.Address
+	add := func(a common.Address) {
+		if !seen[a] {
+			seen[a] = true
+			addrs = append(addrs, a)
+		}
+	}
+	for i, k := range config.SenderKeys {
+		_, addr, err := loadPrivateKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("sender key %d: %w", i+1, err)
+		}
+		add(addr)
+	}
+	for _, r := range config.Recipients {
+		add(common.HexToAddress(r))
+	}
+	return addrs, nil
+}
+
+// awaitPending waits for the transactions still pending at the end of the
+// run to be mined, so the post-run snapshot includes them: the nonce gap
+// fillers, and every transfer that was broadcast but did not succeed (it may
+// have timed out waiting for its receipt, or been interrupted). It gives up
+// after receiptTimeout.
+func (n *node) awaitPending(ctx context.Context, results []TransferResult, repairs []NonceRepair) {
+	var pending [][]string
+	for _, r := range results {
+		if r.TxHash != "" && r.Status != "success" {
+			pending = append(pending, resultHashes(r))
+		}
+	}
+	for _, rep := range repairs {
+		if rep.Error == "" {
+			pending = append(pending, []string{rep.TxHash})
+		}
+	}
+	if len(pending) == 0 {
+		return
+	}
+	printf(n.out, "Waiting for %d pending transaction(s) before the balance snapshot\n", len(pending))
+	ctx, cancel := context.WithTimeout(ctx, receiptTimeout)
+	defer cancel()
+	for _, hashes := range pending {
+		for {
+			if receipt, err := n.minedReceipt(ctx, hashes); receipt != nil || err != nil {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(receiptPoll):
+			}
+		}
+	}
+}
+
+// snapshotBalances records the ETH and token balances of addrs; token balances
+// are left at zero when token is the zero address.
+func (n *node) snapshotBalances(ctx context.Context, token common.Address, addrs []common.Address) (balances, error) {
+	snap := make(balances, len(addrs))
+	for _, a := range addrs {
+		eth, err := n.client.BalanceAt(ctx, a, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get ETH balance of %s: %w", a.Hex(), err)
+		}
+		tok := new(big.Int)
+		if token != (common.Address{}) {
+			if tok, err = n.tokenBalance(ctx, token, a); err != nil {
+				return nil, fmt.Errorf("%s: %w", a.Hex(), err)
+			}
+		}
+		snap[a] = balance{eth: eth, token: tok}
+	}
+	return snap, nil
+}
+
+// reconcile compares the balance changes between before and after with what
+// the results account for: tokens and native ETH moved by every transfer the
+// chain shows succeeded, and gas paid for every mined transaction (transfers,
+// replacements and gap fillers). Both are looked up from the chain's receipts
+// rather than trusted from the results, since a transfer reported as
+// unconfirmed may still have been mined.
+func (n *node) reconcile(ctx context.Context, before, after balances, results []TransferResult, repairs []NonceRepair) (Reconciliation, error) {
+	expected := make(map[common.Address]*balance, len(before))
+	for a := range before {
+		expected[a] = &balance{eth: new(big.Int), token: new(big.Int)}
+	}
+	spend := func(from common.Address, hashes []string) (*types.Receipt, error) {
+		receipt, err := n.minedReceipt(ctx, hashes)
+		if err != nil || receipt == nil {
+			return nil, err
+		}
+		if e, ok := expected[from]; ok {
+			fee, err := n.txFee(ctx, receipt)
+			if err != nil {
+				return nil, err
+			}
+			e.eth.Sub(e.eth, fee)
+		}
+		return receipt, nil
+	}
+
+	for _, r := range results {
+		if r.TxHash == "" {
+			continue
+		}
+		from, to := common.HexToAddress(r.From), common.HexToAddress(r.To)
+		receipt, err := spend(from, resultHashes(r))
+		if err != nil {
+			return Reconciliation{}, err
+		}
+		if receipt == nil || receipt.Status != types.ReceiptStatusSuccessful || isCancel(r, receipt.TxHash) {
+			continue
+		}
+		amount, ok := new(big.Int).SetString(r.Amount, 10)
+		if !ok {
+			return Reconciliation{}, fmt.Errorf("result %s has no amount", r.TxHash)
+		}
+		if e, ok := expected[from]; ok {
+			e.moved(r.TxType).Sub(e.moved(r.TxType), amount)
+		}
+		if e, ok := expected[to]; ok {
+			e.moved(r.TxType).Add(e.moved(r.TxType), amount)
+		}
+	}
+	for _, rep := range repairs {
+		if rep.Error == "" {
+			if _, err := spend(common.HexToAddress(rep.Sender), []string{rep.TxHash}); err != nil {
+				return Reconciliation{}, err
+			}
+		}
+	}
+
+	addrs := make([]common.Address, 0, len(before))
+	for a := range before {
+		addrs = append(addrs, a)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i].Hex() < addrs[j].Hex() })
+	rec := Reconciliation{OK: true}
+	for _, a := range addrs {
+		b, af, e := before[a], after[a], expected[a]
+		ar := AddressReconciliation{
+			Address: a.Hex(),
+			ETH:     compareDelta(b.eth, af.eth, e.eth),
+			Token:   compareDelta(b.token, af.token, e.token),
+		}
+		ar.OK = ar.ETH.Diff == "" && ar.Token.Diff == ""
+		rec.OK = rec.OK && ar.OK
+		rec.Addresses = append(rec.Addresses, ar)
+	}
+	return rec, nil
+}
+
+// resultHashes returns every hash r was broadcast under. They share a nonce,
+// so at most one of them is mined.
+func resultHashes(r TransferResult) []string {
+	hashes := []string{r.TxHash}
+	if r.OriginalTxHash != "" {
+		hashes = append(hashes, r.OriginalTxHash)
+	}
+	for _, rep := range r.Replacements {
+		hashes = append(hashes, rep.TxHash)
+	}
+	return hashes
+}
+
+// isCancel reports whether hash is a cancelling replacement of r, which moves
+// no funds.
+func isCancel(r TransferResult, hash common.Hash) bool {
+	for _, rep := range r.Replacements {
+		if rep.Cancel && common.HexToHash(rep.TxHash) == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// minedReceipt returns the receipt of whichever of hashes was mined, or nil
+// if none was.
+func (n *node) minedReceipt(ctx context.Context, hashes []string) (*types.Receipt, error) {
+	for _, h := range hashes {
+		receipt, err := n.client.TransactionReceipt(ctx, common.HexToHash(h))
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			continue
+		}
+		return receipt, nil
+	}
+	return nil, nil
+}
+
+// txFee returns the fee paid by the mined transaction of receipt.
+func (n *node) txFee(ctx context.Context, receipt *types.Receipt) (*big.Int, error) {
+	price := receipt.EffectiveGasPrice
+	if price == nil {
+		tx, _, err := n.client.TransactionByHash(ctx, receipt.TxHash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get tx %s: %w", receipt.TxHash.Hex(), err)
+		}
+		price = tx.GasPrice()
+	}
+	return new(big.Int).Mul(price, new(big.Int).SetUint64(receipt.GasUsed)), nil
+}
+
+func compareDelta(before, after, expected *big.Int) BalanceDelta {
+	delta := new(big.Int).Sub(after, before)
+	d := BalanceDelta{Before: before.String(), After: after.String(), Delta: delta.String(), Expected: expected.String()}
+	if diff := new(big.Int).Sub(delta, expected); diff.Sign() != 0 {
+		d.Diff = diff.String()
+	}
+	return d
+}
+
+func printReconciliation(w io.Writer, rec Reconciliation) {
+	fmt.Fprintln(w, "\n========== RECONCILIATION ==========")
+	bad := 0
+	for _, a := range rec.Addresses {
+		if a.OK {
+			continue
+		}
+		bad++
+		fmt.Fprintf(w, "%s:\n", a.Address)
+		if a.ETH.Diff != "" {
+			fmt.Fprintf(w, "  ETH:   delta %s wei, expected %s (off by %s)\n", a.ETH.Delta, a.ETH.Expected, a.ETH.Diff)
+		}
+		if a.Token.Diff != "" {
+			fmt.Fprintf(w, "  Token: delta %s, expected %s (off by %s)\n", a.Token.Delta, a.Token.Expected, a.Token.Diff)
+		}
+	}
+	fmt.Fprintf(w, "%d address(es) checked, %d with discrepancies\n", len(rec.Addresses), bad)
+}
@@ -0,0 +1,133 @@
+package ethload
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// slowSendClient hands transactions to the node but reports the send as timed
+// out, as a slow RPC would.
+type slowSendClient struct {
+	Client
+}
+
+func (c *slowSendClient) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	if err := c.Client.SendTransaction(ctx, tx); err != nil {
+		return err
+	}
+	return context.DeadlineExceeded
+}
+
+// TestReconcileUnconfirmedTransfers checks that transfers the runner gave up
+// on are reconciled by what the chain did with them: the amount of one mined
+// later counts, the amount of a reverted one does not, and both pay gas. A
+// send that timed out is looked up by its signed hash like the others.
+func TestReconcileUnconfirmedTransfers(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs a simulated chain")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	h, err := NewSimHarness(ctx, SimOptions{Senders: 1, Recipients: 1, BlockTime: 50 * time.Millisecond, Supply: big.NewInt(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	n, err := newNode(h.client, TestConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.connect(ctx, TestConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	key, from, _ := loadPrivateKey(h.senderKeys[0])
+	to := common.HexToAddress(h.recipients[0])
+	accounts := []common.Address{from, to}
+	before, err := n.snapshotBalances(ctx, h.token, accounts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nonce, err := h.client.PendingNonceAt(ctx, from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gasPrice, err := h.client.SuggestGasPrice(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gasPrice.Mul(gasPrice, big.NewInt(2))
+	// send broadcasts a token transfer without waiting for it, as a runner
+	// that timed out on its receipt would leave it.
+	send := func(amount *big.Int, status string) TransferResult {
+		data, err := erc20TransferData(to, amount)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx := types.NewTransaction(nonce, h.token, new(big.Int), 100_000, gasPrice, data)
+		signed, err := types.SignTx(tx, types.LatestSignerForChainID(n.chainID), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := h.client.SendTransaction(ctx, signed); err != nil {
+			t.Fatal(err)
+		}
+		nonce++
+		return TransferResult{From: from.Hex(), To: to.Hex(), Amount: amount.String(), TxType: TxTypeERC20, TxHash: signed.Hash().Hex(), Status: status}
+	}
+	tooMuch := new(big.Int).Exp(big.NewInt(10), big.NewInt(testTokenDecimals+1), nil)
+	results := []TransferResult{
+		send(big.NewInt(7), "unconfirmed"),
+		send(tooMuch, "unconfirmed"),
+	}
+
+	// A transfer whose send timed out after the node took it.
+	tn, err := newNode(&slowSendClient{Client: h.client}, TestConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tn.connect(ctx, TestConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	r := &Runner{node: tn, nonces: newNonceManager(tn)}
+	data, err := erc20TransferData(to, big.NewInt(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := txRequest{txType: TxTypeERC20, recipient: to, to: h.token, value: new(big.Int), data: data, amount: big.NewInt(3)}
+	timedOut := r.executeTransfer(ctx, h.senderKeys[0], req, false)
+	if timedOut.Status != "unconfirmed" || timedOut.TxHash == "" {
+		t.Fatalf("timed-out send = %+v, want unconfirmed with its tx hash", timedOut)
+	}
+	results = append(results, timedOut)
+
+	n.awaitPending(ctx, results, nil)
+	after, err := n.snapshotBalances(ctx, h.token, accounts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := n.reconcile(ctx, before, after, results, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rec.OK {
+		t.Fatalf("reconciliation failed: %+v", rec.Addresses)
+	}
+	for _, a := range rec.Addresses {
+		want := "10"
+		if a.Address == from.Hex() {
+			want = "-10"
+			if a.ETH.Expected == "0" {
+				t.Error("sender's expected ETH change leaves out gas")
+			}
+		}
+		if a.Token.Delta != want {
+			t.Errorf("%s token delta = %s, want %s", a.Address, a.Token.Delta, want)
+		}
+	}
+}
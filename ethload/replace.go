@@ -0,0 +1,224 @@
+package ethload
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Replacement modes for stuck transactions
+const (
+	ReplaceSpeedUp = "speed-up" // resend the same transfer with a higher fee
+	ReplaceCancel  = "cancel"   // replace the transfer with a 0-value self transfer
+)
+
+const (
+	defaultGasBumpPercent  = 15
+	defaultMaxReplacements = 3
+	// minGasBumpPercent is the smallest bump geth-style pools accept for a
+	// same-nonce replacement.
+	minGasBumpPercent = 10
+	// receiptTimeout is how long waitForReceipt waits past the last broadcast.
+	receiptTimeout = 2 * time.Minute
+	// receiptPoll is how often a waiting transfer re-checks its replacement
+	// and timeout deadlines between blocks; it makes no RPC calls.
+	receiptPoll = 2 * time.Second
+)
+
+// errReceiptTimeout is returned by waitForReceipt when nothing broadcast for
+// a transfer was mined within receiptTimeout. The tx may still land.
+var errReceiptTimeout = errors.New("timeout waiting for receipt")
+
+// Replacement is a same-nonce re-broadcast of a stuck transfer.
+type Replacement struct {
+	TxHash               string    `json:"txHash"`
+	GasPrice             string    `json:"gasPrice,omitempty"`             // wei, legacy txs
+	MaxFeePerGas         string    `json:"maxFeePerGas,omitempty"`         // wei, EIP-1559 txs
+	MaxPriorityFeePerGas string    `json:"maxPriorityFeePerGas,omitempty"` // wei, EIP-1559 txs
+	Cancel               bool      `json:"cancel,omitempty"`
+	SentAt               time.Time `json:"sentAt"`
+}
+
+// replacementPolicy is the parsed replaceAfter/replaceMode/... from TestConfig.
+type replacementPolicy struct {
+	after       time.Duration // 0 disables replacement
+	cancel      bool
+	bumpPercent int64
+	max         int
+	maxGasPrice *big.Int // caps gasPrice or maxFeePerGas; nil for no cap
+}
+
+func parseReplacementPolicy(config TestConfig) (replacementPolicy, error) {
+	p := replacementPolicy{bumpPercent: defaultGasBumpPercent, max: defaultMaxReplacements}
+	if s := strings.TrimSpace(config.ReplaceAfter); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return p, fmt.Errorf("invalid replaceAfter %q", config.ReplaceAfter)
+		}
+		// The receipt wait would give up before the first replacement.
+		if d >= receiptTimeout {
+			return p, fmt.Errorf("replaceAfter %s must be under the %s receipt timeout", d, receiptTimeout)
+		}
+		p.after = d
+	}
+	switch strings.TrimSpace(config.ReplaceMode) {
+	case "", ReplaceSpeedUp:
+	case ReplaceCancel:
+		p.cancel = true
+	default:
+		return p, fmt.Errorf("invalid replaceMode %q (want %s or %s)", config.ReplaceMode, ReplaceSpeedUp, ReplaceCancel)
+	}
+	if config.GasBumpPercent != 0 {
+		if config.GasBumpPercent < minGasBumpPercent {
+			return p, fmt.Errorf("gasBumpPercent must be at least %d", minGasBumpPercent)
+		}
+		p.bumpPercent = int64(config.GasBumpPercent)
+	}
+	if config.MaxReplacements < 0 {
+		return p, fmt.Errorf("invalid maxReplacements %d", config.MaxReplacements)
+	}
+	if config.MaxReplacements > 0 {
+		p.max = config.MaxReplacements
+	}
+	if config.MaxGasPriceGwei > 0 {
+		p.maxGasPrice = gweiToWei(config.MaxGasPriceGwei)
+	}
+	return p, nil
+}
+
+func gweiToWei(gwei float64) *big.Int {
+	wei, _ := new(big.Float).Mul(big.NewFloat(gwei), big.NewFloat(1e9)).Int(nil)
+	return wei
+}
+
+// trackedTx is a broadcast transfer together with every replacement sent for it.
+type trackedTx struct {
+	key          *ecdsa.PrivateKey
+	original     *types.Transaction
+	latest       *types.Transaction
+	sent         []common.Hash // every broadcast hash, oldest first
+	lastSent     time.Time
+	lastAttempt  time.Time
+	replacements []Replacement
+	minedAt      time.Time // when the receipt tracker first saw the mined broadcast
+}
+
+func newTrackedTx(key *ecdsa.PrivateKey, tx *types.Transaction, sentAt time.Time) *trackedTx {
+	return &trackedTx{key: key, original: tx, latest: tx, sent: []common.Hash{tx.Hash()}, lastSent: sentAt, lastAttempt: sentAt}
+}
+
+// dueForReplacement reports whether the policy allows another replacement now.
+func (t *trackedTx) dueForReplacement(p replacementPolicy, now time.Time) bool {
+	return p.after > 0 && len(t.replacements) < p.max && now.Sub(t.lastAttempt) >= p.after
+}
+
+// replacement returns the replacement broadcast as hash, if any.
+func (t *trackedTx) replacement(hash common.Hash) (Replacement, bool) {
+	for _, r := range t.replacements {
+		if r.TxHash == hash.Hex() {
+			return r, true
+		}
+	}
+	return Replacement{}, false
+}
+
+// replace re-broadcasts the transfer with the same nonce and bumped fees, or
+// a 0-value self transfer in cancel mode. It returns false once the fee cap
+// leaves no room for a further bump.
+func (t *trackedTx) replace(ctx context.Context, n *node, p replacementPolicy) (bool, error) {
+	t.lastAttempt = time.Now()
+	current := feesOf(t.latest)
+	fees := current.bump(p.bumpPercent)
+	if suggested, err := n.suggestFees(ctx); err == nil {
+		fees = fees.atLeast(suggested)
+	}
+	fees = fees.capped(p.maxGasPrice)
+	// Anything under the minimum bump would be rejected as underpriced.
+	if !fees.covers(current.bump(minGasBumpPercent)) {
+		return false, nil
+	}
+
+	to, value, gas, data := t.original.To(), t.original.Value(), t.original.Gas(), t.original.Data()
+	if p.cancel {
+		self := crypto.PubkeyToAddress(t.key.PublicKey)
+		to, value, gas, data = &self, big.NewInt(0), gapFillGasLimit, nil
+	}
+	tx := fees.newTx(t.original.Nonce(), to, value, gas, data)
+	signed, err := types.SignTx(tx, types.LatestSignerForChainID(n.chainID), t.key)
+	if err != nil {
+		return false, fmt.Errorf("failed to sign replacement: %w", err)
+	}
+	if err := n.client.SendTransaction(ctx, signed); err != nil {
+		return true, fmt.Errorf("replacement failed: %w", err)
+	}
+	now := time.Now()
+	t.latest, t.lastSent = signed, now
+	t.sent = append(t.sent, signed.Hash())
+	rep := Replacement{TxHash: signed.Hash().Hex(), Cancel: p.cancel, SentAt: now}
+	if fees.dynamic() {
+		rep.MaxPriorityFeePerGas, rep.MaxFeePerGas = fees.tipCap.String(), fees.feeCap.String()
+	} else {
+		rep.GasPrice = fees.gasPrice.String()
+	}
+	t.replacements = append(t.replacements, rep)
+	kind := "speed-up"
+	if p.cancel {
+		kind = "cancel"
+	}
+	printf(n.out, "Replaced stuck tx %s (nonce %d) with %s %s at %s\n", t.original.Hash().Hex(), t.original.Nonce(), kind, signed.Hash().Hex(), fees)
+	return true, nil
+}
+
+// waitForReceipt waits for the run's receipt tracker to see the transfer or
+// any of its replacements mined, replacing it per the replacement policy while
+// it stays pending, until the mined one is confirmations blocks deep. It gives
+// up receiptTimeout after the last broadcast if nothing has been mined by then.
+func (r *Runner) waitForReceipt(ctx context.Context, t *trackedTx) (*types.Receipt, error) {
+	r.receipts.watch(ctx, t.latest.Hash())
+	defer func() { r.receipts.unwatch(t.sent...) }()
+	canReplace := true
+	for {
+		receipt, head, changed := r.receipts.status(t.sent)
+		if receipt != nil {
+			t.minedAt = r.receipts.seenAt(receipt.TxHash)
+		}
+		switch {
+		case receipt != nil && receipt.Status == 0:
+			return receipt, fmt.Errorf("transaction reverted")
+		case receipt != nil:
+			if head+1 >= receipt.BlockNumber.Uint64()+r.confirmations {
+				return receipt, nil
+			}
+		default:
+			now := time.Now()
+			if now.Sub(t.lastSent) >= receiptTimeout {
+				return nil, errReceiptTimeout
+			}
+			if canReplace && t.dueForReplacement(r.replacePolicy, now) {
+				var err error
+				canReplace, err = t.replace(ctx, r.node, r.replacePolicy)
+				if err != nil {
+					// "nonce too low" means an earlier broadcast was just mined;
+					// the tracker picks up its receipt.
+					printf(r.Output, "replacing %s: %v\n", t.original.Hash().Hex(), err)
+				} else if canReplace {
+					r.receipts.watch(ctx, t.latest.Hash())
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("context cancelled while waiting for receipt")
+		case <-changed:
+		case <-time.After(receiptPoll):
+		}
+	}
+}
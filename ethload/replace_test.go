@@ -0,0 +1,270 @@
+package ethload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestParseReplacementPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  TestConfig
+		want    replacementPolicy
+		wantErr string
+	}{
+		{name: "defaults", config: TestConfig{},
+			want: replacementPolicy{bumpPercent: defaultGasBumpPercent, max: defaultMaxReplacements}},
+		{name: "cancel after 30s with a cap",
+			config: TestConfig{ReplaceAfter: "30s", ReplaceMode: ReplaceCancel, GasBumpPercent: 20, MaxReplacements: 5, MaxGasPriceGwei: 1.5},
+			want:   replacementPolicy{after: 30 * time.Second, cancel: true, bumpPercent: 20, max: 5, maxGasPrice: big.NewInt(1_500_000_000)}},
+		{name: "speed-up by name", config: TestConfig{ReplaceAfter: "1m", ReplaceMode: ReplaceSpeedUp},
+			want: replacementPolicy{after: time.Minute, bumpPercent: defaultGasBumpPercent, max: defaultMaxReplacements}},
+		{name: "unparsable replaceAfter", config: TestConfig{ReplaceAfter: "soon"}, wantErr: "invalid replaceAfter"},
+		{name: "negative replaceAfter", config: TestConfig{ReplaceAfter: "-5s"}, wantErr: "invalid replaceAfter"},
+		{name: "replaceAfter at the receipt timeout", config: TestConfig{ReplaceAfter: "2m"}, wantErr: "must be under the 2m0s receipt timeout"},
+		{name: "replaceAfter past the receipt timeout", config: TestConfig{ReplaceAfter: "5m"}, wantErr: "must be under"},
+		{name: "unknown mode", config: TestConfig{ReplaceMode: "drop"}, wantErr: "invalid replaceMode"},
+		{name: "bump under the pool minimum", config: TestConfig{GasBumpPercent: 5}, wantErr: "gasBumpPercent must be at least 10"},
+		{name: "negative maxReplacements", config: TestConfig{MaxReplacements: -1}, wantErr: "invalid maxReplacements"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseReplacementPolicy(tt.config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.after != tt.want.after || got.cancel != tt.want.cancel || got.bumpPercent != tt.want.bumpPercent || got.max != tt.want.max ||
+				(got.maxGasPrice == nil) != (tt.want.maxGasPrice == nil) || (got.maxGasPrice != nil && got.maxGasPrice.Cmp(tt.want.maxGasPrice) != 0) {
+				t.Errorf("policy = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// mempoolChain is a fakeChain that accepts every transaction sent to it
+// without mining it; tests mine them with build.
+type mempoolChain struct {
+	*fakeChain
+	gasPrice *big.Int
+	tipErr   error
+	sendErr  error
+	mu       sync.Mutex
+	sent     []*types.Transaction
+}
+
+func (c *mempoolChain) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return c.gasPrice, nil
+}
+
+func (c *mempoolChain) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return nil, c.tipErr
+}
+
+func (c *mempoolChain) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(20), nil), nil
+}
+
+func (c *mempoolChain) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return 0, nil
+}
+
+func (c *mempoolChain) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	if c.sendErr != nil {
+		return c.sendErr
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = append(c.sent, tx)
+	return nil
+}
+
+func (c *mempoolChain) broadcast() []*types.Transaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*types.Transaction(nil), c.sent...)
+}
+
+func TestTrackedTxReplace(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	self := crypto.PubkeyToAddress(key.PublicKey)
+	to := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	chainID := big.NewInt(1337)
+	legacy := types.NewTx(&types.LegacyTx{Nonce: 7, To: &to, Value: big.NewInt(5), Gas: 60000, GasPrice: big.NewInt(100), Data: []byte{1, 2}})
+	dynamic := types.NewTx(&types.DynamicFeeTx{ChainID: chainID, Nonce: 7, To: &to, Value: big.NewInt(5), Gas: 60000, GasTipCap: big.NewInt(10), GasFeeCap: big.NewInt(100), Data: []byte{1, 2}})
+
+	tests := []struct {
+		name      string
+		tx        *types.Transaction
+		policy    replacementPolicy
+		suggested int64 // node gas price
+		sendErr   error
+		wantOK    bool
+		wantErr   bool
+		wantFees  txFees // nil fees: nothing sent
+		wantRep   Replacement
+	}{
+		{name: "speed-up bumps the gas price", tx: legacy, policy: replacementPolicy{bumpPercent: 15},
+			suggested: 50, wantOK: true, wantFees: fee(115, -1, -1), wantRep: Replacement{GasPrice: "115"}},
+		{name: "speed-up follows a higher suggested price", tx: legacy, policy: replacementPolicy{bumpPercent: 15},
+			suggested: 200, wantOK: true, wantFees: fee(200, -1, -1), wantRep: Replacement{GasPrice: "200"}},
+		{name: "speed-up bumps both eip1559 fees", tx: dynamic, policy: replacementPolicy{bumpPercent: 20},
+			wantOK: true, wantFees: fee(-1, 12, 120), wantRep: Replacement{MaxPriorityFeePerGas: "12", MaxFeePerGas: "120"}},
+		{name: "fee cap clamps the bump", tx: legacy, policy: replacementPolicy{bumpPercent: 50, maxGasPrice: big.NewInt(112)},
+			suggested: 50, wantOK: true, wantFees: fee(112, -1, -1), wantRep: Replacement{GasPrice: "112"}},
+		{name: "fee cap leaves no room for the minimum bump", tx: legacy, policy: replacementPolicy{bumpPercent: 15, maxGasPrice: big.NewInt(105)},
+			suggested: 50, wantFees: fee(-1, -1, -1)},
+		{name: "cancel sends a 0-value self transfer", tx: legacy, policy: replacementPolicy{bumpPercent: 15, cancel: true},
+			suggested: 50, wantOK: true, wantFees: fee(115, -1, -1), wantRep: Replacement{GasPrice: "115", Cancel: true}},
+		{name: "send error keeps the replacement allowed", tx: legacy, policy: replacementPolicy{bumpPercent: 15},
+			suggested: 50, sendErr: errors.New("nonce too low"), wantOK: true, wantErr: true, wantFees: fee(-1, -1, -1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := &mempoolChain{fakeChain: newFakeChain(), gasPrice: big.NewInt(tt.suggested), tipErr: errors.New("no tip"), sendErr: tt.sendErr}
+			n := &node{client: chain, chainID: chainID, fees: feePolicy{dynamic: tt.tx.Type() == types.DynamicFeeTxType}}
+			signed, err := types.SignTx(tt.tx, types.LatestSignerForChainID(chainID), key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tracked := newTrackedTx(key, signed, time.Now())
+
+			ok, err := tracked.replace(context.Background(), n, tt.policy)
+			if ok != tt.wantOK || (err != nil) != tt.wantErr {
+				t.Fatalf("replace = %v, %v; want %v, error %v", ok, err, tt.wantOK, tt.wantErr)
+			}
+			sent := chain.broadcast()
+			if tt.wantFees.gasPrice == nil && tt.wantFees.feeCap == nil {
+				if len(sent) != 0 || len(tracked.replacements) != 0 || tracked.latest != signed {
+					t.Fatalf("sent %d txs and recorded %d replacements, want none", len(sent), len(tracked.replacements))
+				}
+				return
+			}
+			if len(sent) != 1 {
+				t.Fatalf("sent %d txs, want 1", len(sent))
+			}
+			rep := sent[0]
+			if got := feesOf(rep); !feesEqual(got, tt.wantFees) {
+				t.Errorf("replacement fees %v, want %v", got, tt.wantFees)
+			}
+			if rep.Nonce() != 7 {
+				t.Errorf("replacement nonce %d, want the original 7", rep.Nonce())
+			}
+			wantTo, wantValue, wantGas, wantData := to, int64(5), uint64(60000), []byte{1, 2}
+			if tt.policy.cancel {
+				wantTo, wantValue, wantGas, wantData = self, 0, gapFillGasLimit, nil
+			}
+			if *rep.To() != wantTo || rep.Value().Int64() != wantValue || rep.Gas() != wantGas || !bytes.Equal(rep.Data(), wantData) {
+				t.Errorf("replacement sends %d wei to %s with gas %d and data %x, want %d wei to %s with gas %d and data %x",
+					rep.Value(), rep.To().Hex(), rep.Gas(), rep.Data(), wantValue, wantTo.Hex(), wantGas, wantData)
+			}
+
+			if tracked.latest != rep || len(tracked.sent) != 2 || tracked.sent[1] != rep.Hash() {
+				t.Errorf("tracked tx not updated to the replacement")
+			}
+			got, found := tracked.replacement(rep.Hash())
+			tt.wantRep.TxHash, tt.wantRep.SentAt = rep.Hash().Hex(), got.SentAt
+			if !found || got != tt.wantRep {
+				t.Errorf("recorded %+v, want %+v", got, tt.wantRep)
+			}
+		})
+	}
+}
+
+func TestExecuteTransferRecordsMinedHash(t *testing.T) {
+	tests := []struct {
+		name       string
+		cancel     bool
+		mine       int // index of the broadcast to mine
+		wantStatus string
+	}{
+		{name: "replacement mined", mine: 1, wantStatus: "success"},
+		{name: "original mined after a replacement", mine: 0, wantStatus: "success"},
+		{name: "cancel mined", cancel: true, mine: 1, wantStatus: "cancelled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			chain := &mempoolChain{fakeChain: newFakeChain(), gasPrice: big.NewInt(100)}
+			var out bytes.Buffer
+			n := &node{client: chain, chainID: big.NewInt(1337), gas: gasPolicy{fixed: 21000}, confirmations: 1}
+			n.limits = newGasEstimator(n)
+			rt := newReceiptTracker(chain, chain.header(0), nil)
+			r := &Runner{
+				Output:        &out,
+				node:          n,
+				nonces:        newNonceManager(n),
+				receipts:      rt,
+				replacePolicy: replacementPolicy{after: 10 * time.Millisecond, cancel: tt.cancel, bumpPercent: 15, max: 1},
+			}
+			recipient := common.HexToAddress("0x1000000000000000000000000000000000000001")
+			req := txRequest{txType: TxTypeNative, recipient: recipient, to: recipient, value: big.NewInt(1), amount: big.NewInt(1)}
+
+			done := make(chan TransferResult, 1)
+			go func() { done <- r.executeTransfer(ctx, testKeyHex(), req, true) }()
+
+			// Seal empty blocks, which wake the receipt wait, until the
+			// replacement is broadcast; then mine the chosen broadcast.
+			var result TransferResult
+			var sent []*types.Transaction
+			for head := uint64(0); ; head++ {
+				var mined map[*types.Transaction]uint64
+				if len(sent) < 2 {
+					if sent = chain.broadcast(); len(sent) == 2 {
+						mined = map[*types.Transaction]uint64{sent[tt.mine]: head + 1}
+					}
+				}
+				if err := rt.advance(ctx, chain.build(head, 'a', head+1, mined)); err != nil {
+					t.Fatal(err)
+				}
+				select {
+				case result = <-done:
+				case <-time.After(20 * time.Millisecond):
+					continue
+				case <-ctx.Done():
+					t.Fatal("transfer never finished")
+				}
+				break
+			}
+
+			if len(sent) != 2 {
+				t.Fatalf("broadcast %d txs, want the original and one replacement", len(sent))
+			}
+			if result.Status != tt.wantStatus {
+				t.Fatalf("status %q (%s), want %q", result.Status, result.Error, tt.wantStatus)
+			}
+			if want := sent[tt.mine].Hash().Hex(); result.TxHash != want {
+				t.Errorf("TxHash %s, want the mined %s", result.TxHash, want)
+			}
+			wantOriginal := ""
+			if tt.mine != 0 {
+				wantOriginal = sent[0].Hash().Hex()
+			}
+			if result.OriginalTxHash != wantOriginal {
+				t.Errorf("OriginalTxHash %q, want %q", result.OriginalTxHash, wantOriginal)
+			}
+			if len(result.Replacements) != 1 || result.Replacements[0].TxHash != sent[1].Hash().Hex() || result.Replacements[0].Cancel != tt.cancel {
+				t.Errorf("Replacements = %+v, want the one broadcast", result.Replacements)
+			}
+		})
+	}
+}
@@ -0,0 +1,761 @@
+// Package ethload sends ERC20, native, contract-call and NFT transfers on
+// Ethereum-like networks (e.g., Sepolia). It supports multiple sender keys and
+// multiple recipient addresses, enabling scenarios such as one-to-one,
+// one-to-many, many-to-one, and many-to-many, with balance checks, nonce
+// management and optional receipt waiting. A Runner built from a TestConfig
+// runs one test and returns its Report.
+package ethload
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"tron_load/hdwallet"
+	"tron_load/metrics"
+	"tron_load/secrets"
+)
+
+// Minimal ERC20 ABI for transfer
+const erc20ABI = `[{"constant":false,"inputs":[{"name":"_to","type":"address"},{"name":"_value","type":"uint256"}],"name":"transfer","outputs":[{"name":"","type":"bool"}],"type":"function"}]`
+
+// Default runner label of the live Prometheus metrics
+const metricsRunner = "eth"
+
+type TransferResult struct {
+	From        string `json:"from"`
+	To          string `json:"to"`
+	TxType      string `json:"txType,omitempty"` // erc20, native, contract-call, erc721 or erc1155
+	TxHash      string `json:"txHash"`           // the tx that got mined when the transfer was replaced
+	Amount      string `json:"amount,omitempty"` // token base units, or wei for native transfers
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+	BlockNumber uint64 `json:"blockNumber,omitempty"`
+	Phase       string `json:"phase,omitempty"`
+	GasLimit    uint64 `json:"gasLimit,omitempty"`
+	// NFT transfers: the token ID sent (erc721, erc1155)
+	TokenID string `json:"tokenId,omitempty"`
+	// From the receipt: gas used and the price per gas actually paid, in wei
+	GasUsed           uint64 `json:"gasUsed,omitempty"`
+	EffectiveGasPrice string `json:"effectiveGasPrice,omitempty"`
+	// Stuck-tx replacement: the first broadcast hash and every same-nonce re-broadcast
+	OriginalTxHash string        `json:"originalTxHash,omitempty"`
+	Replacements   []Replacement `json:"replacements,omitempty"`
+	// Timing: submit is sign -> RPC ack, inclusion is RPC ack -> receipt first
+	// seen, confirmation is receipt first seen -> confirmations blocks deep
+	SubmittedAt           time.Time `json:"submittedAt,omitzero"`
+	SubmitLatencyMs       float64   `json:"submitLatencyMs,omitempty"`
+	InclusionLatencyMs    float64   `json:"inclusionLatencyMs,omitempty"`
+	ConfirmationLatencyMs float64   `json:"confirmationLatencyMs,omitempty"`
+	// Open-loop (targetTps) runs only
+	ScheduledAt   time.Time `json:"scheduledAt,omitzero"`
+	ScheduleLagMs float64   `json:"scheduleLagMs,omitempty"`
+	Late          bool      `json:"late,omitempty"`
+}
+
+type TestConfig struct {
+	Scenario       string   `json:"scenario"`      // one-to-one, one-to-many, many-to-one or many-to-many
+	Pairing        string   `json:"pairing"`       // fixed (default), round-robin or random
+	PairingSeed    int64    `json:"pairingSeed"`   // seed for random pairing; 0 picks one from the clock
+	Chain          string   `json:"chain"`         // sepolia, holesky, bsc-testnet or local: presets rpcUrl, chainId, feeMode, confirmations
+	RPCURL         string   `json:"rpcUrl"`        // defaults to the chain profile's (sepolia when neither is set)
+	ChainID        uint64   `json:"chainId"`       // when set, the node's chain ID must match
+	Confirmations  uint64   `json:"confirmations"` // blocks that must hold a transfer before it counts (default 1)
+	ERC20Contract  string   `json:"erc20Contract"`
+	SenderKeys     []string `json:"senderKeys"`
+	Recipients     []string `json:"recipients"`
+	MaxConcurrent  int      `json:"maxConcurrent"`
+	WaitForReceipt bool     `json:"waitForReceipt"`
+	ContractAddr   string   `json:"contractAddr"`
+	LoopCount      int      `json:"loopCount"`
+	Duration       string   `json:"duration"`    // e.g. "30m": keep every pair busy until then instead of loopCount times
+	GracePeriod    string   `json:"gracePeriod"` // receipt wait allowed for in-flight transfers after the deadline (default "2m")
+	Delay          int      `json:"delay"`       // ms between each inner loop
+	Amount         string   `json:"amount"`      // fixed amount in the token's smallest unit; overrides minAmount/maxAmount
+	MinAmount      float64  `json:"minAmount"`
+	MaxAmount      float64  `json:"maxAmount"`
+	Decimals       int      `json:"decimals"`
+	MaxGoroutines  int      `json:"maxGoroutines"`
+	// What each transfer sends: erc20 (default), native, contract-call, or mixed per mix
+	TxType string      `json:"txType"`
+	Call   *CallConfig `json:"call"` // contract-call: the method and its templated args
+	Mix    []TxWeight  `json:"mix"`  // mixed: weight of each type
+	// NFT collections for txType erc721 / erc1155
+	ERC721  *ERC721Config  `json:"erc721"`
+	ERC1155 *ERC1155Config `json:"erc1155"`
+	// Amount distribution; overrides amount, and takes its default bounds from minAmount/maxAmount
+	Amounts *AmountConfig `json:"amounts"`
+	// Whole-ETH amounts of native transfers; required when mix includes native, which would otherwise
+	// send token-sized amounts of ETH
+	NativeAmounts *AmountConfig `json:"nativeAmounts"`
+	MetricsAddr   string        `json:"metricsAddr"` // e.g. ":9100" to serve Prometheus /metrics during the run
+	Reconcile     bool          `json:"reconcile"`   // check sender/recipient balances before and after the run; exit 1 on mismatch
+	// Production planners: quick retry knobs
+	RetryCount     int `json:"retryCount"`
+	RetryBackoffMs int `json:"retryBackoffMs"`
+	// Gas limits: estimated per token and recipient kind unless gasLimit is set
+	GasLimit              uint64  `json:"gasLimit"`              // fixed gas limit for every transfer; 0 estimates
+	GasMultiplier         float64 `json:"gasMultiplier"`         // safety margin on estimates (default 1.2)
+	EstimateEveryTransfer bool    `json:"estimateEveryTransfer"` // skip the estimate cache
+	// Fees: legacy (gasPrice) or eip1559 (base fee plus tip)
+	FeeMode          string  `json:"feeMode"`          // legacy (default) or eip1559
+	TipMultiplier    float64 `json:"tipMultiplier"`    // applied to SuggestGasTipCap (default 1)
+	MaxFeeMultiplier float64 `json:"maxFeeMultiplier"` // maxFeePerGas = base fee * this + tip (default 2)
+	// Stuck-tx replacement (needs waitForReceipt): re-broadcast a pending tx with the same nonce
+	ReplaceAfter    string  `json:"replaceAfter"`    // e.g. "30s" since the last broadcast; empty disables replacement
+	ReplaceMode     string  `json:"replaceMode"`     // speed-up (default) or cancel
+	GasBumpPercent  int     `json:"gasBumpPercent"`  // gas price increase per replacement (default 15, minimum 10)
+	MaxReplacements int     `json:"maxReplacements"` // replacements per transfer (default 3)
+	MaxGasPriceGwei float64 `json:"maxGasPriceGwei"` // cap for replacement gasPrice/maxFeePerGas; 0 for no cap
+	// How often to look for nonce gaps that stall a sender (default "15s")
+	NonceCheckInterval string `json:"nonceCheckInterval"`
+	// How often the receipt tracker polls for new heads when rpcUrl cannot push them (default "1s")
+	HeadPollInterval string `json:"headPollInterval"`
+	// Open-loop load: a fixed arrival rate instead of "as fast as workers allow"
+	TargetTPS       float64 `json:"targetTps"`       // transfers per second; 0 keeps the closed-loop runner
+	MaxInFlight     int     `json:"maxInFlight"`     // unfinished transfers allowed before arrivals are dropped
+	LateThresholdMs int     `json:"lateThresholdMs"` // dispatch lag that marks a send as late (default 100)
+	// Multi-phase load profile; when set, loopCount, duration and the top-level targetTps are ignored
+	Phases []PhaseConfig `json:"phases"`
+	// Senders from encrypted keystore files and/or a BIP39 mnemonic (BIP44 m/44'/60'); replace senderKeys
+	Keystore *KeystoreConfig  `json:"keystore"`
+	HDWallet *hdwallet.Config `json:"hdWallet"`
+	// Generate and fund fresh sender keys from a master key before the run; replaces senderKeys
+	Funding *FundingConfig `json:"funding"`
+}
+
+// Runner runs the load test of one TestConfig. It holds the state of its run
+// (client, nonces, fees, receipts), so runners can be used side by side. Two
+// things are shared by the process: the Prometheus registry behind
+// metricsAddr, where runners are told apart by MetricsLabel, and the
+// redaction list of package secrets, to which every key a runner resolves is
+// added for good.
+type Runner struct {
+	// Client, when set, is used instead of dialing rpcUrl. Run leaves it
+	// open.
+	Client Client
+	// OnResult, when set, is called with every transfer as it finishes,
+	// from the goroutine that sent it, so it must be safe for concurrent
+	// use. Schedule lag and phase are only set on the results of the
+	// Report.
+	OnResult func(TransferResult)
+	// Output receives progress messages: the connection, pairs, gas limits,
+	// funding, replacements and receipt tracker warnings. Nil discards them.
+	Output io.Writer
+	// MetricsLabel is the runner label of the live metrics (default "eth").
+	MetricsLabel string
+
+	config   TestConfig
+	resolver *secrets.Resolver
+	timing   runTiming
+
+	*node
+	nonces        *nonceManager
+	receipts      *receiptTracker
+	replacePolicy replacementPolicy
+}
+
+// NewRunner returns a Runner for config. It fills in the chain profile,
+// resolves the secret references and keystore or mnemonic senders of config,
+// and checks its settings.
+func NewRunner(config TestConfig) (*Runner, error) {
+	if err := applyChainProfile(&config); err != nil {
+		return nil, fmt.Errorf("invalid chain: %w", err)
+	}
+	r := &Runner{resolver: newResolver()}
+	keys, err := senderKeys(r.resolver, config, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load senders: %w", err)
+	}
+	config.SenderKeys = keys
+	if config.Funding != nil {
+		funding := *config.Funding
+		if funding.MasterKey, err = r.resolver.Resolve(funding.MasterKey); err != nil {
+			return nil, fmt.Errorf("funding.masterKey: %w", err)
+		}
+		config.Funding = &funding
+	}
+	if _, err := newNode(nil, config); err != nil {
+		return nil, err
+	}
+	if r.replacePolicy, err = parseReplacementPolicy(config); err != nil {
+		return nil, err
+	}
+	if r.timing, err = parseRunTiming(config); err != nil {
+		return nil, err
+	}
+	if config.Reconcile {
+		if !config.WaitForReceipt {
+			return nil, fmt.Errorf("invalid scenario: reconcile needs waitForReceipt, or transfers may still be pending at the post-run snapshot")
+		}
+		if usesTxType(config, TxTypeContractCall) || usesTxType(config, TxTypeERC721) || usesTxType(config, TxTypeERC1155) {
+			return nil, fmt.Errorf("invalid scenario: reconcile only accounts for erc20 and native transfers")
+		}
+	}
+	r.config = config
+	return r, nil
+}
+
+// Run connects to the chain, funds the senders when the config asks for it,
+// sends every transfer and returns the report of the run. Cancelling ctx
+// stops sending; transfers in flight finish or give up under it. A failed
+// reconciliation is reported in Report.Reconciliation, not as an error.
+func (r *Runner) Run(ctx context.Context) (Report, error) {
+	config := r.config
+	client := r.Client
+	if client == nil {
+		c, err := dialConfig(r.resolver, config)
+		if err != nil {
+			return Report{}, err
+		}
+		defer c.Close()
+		client = c
+	}
+	n, err := openNode(ctx, client, config, r.Output)
+	if err != nil {
+		return Report{}, err
+	}
+	r.node = n
+	r.nonces = newNonceManager(n)
+
+	if config.Funding != nil {
+		fundCtx, cancel := context.WithTimeout(ctx, fundingTimeout)
+		err := n.fundSenders(fundCtx, &config)
+		cancel()
+		if err != nil {
+			return Report{}, fmt.Errorf("failed to fund senders: %w", err)
+		}
+	}
+
+	srv, err := metrics.Serve(config.MetricsAddr)
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to start metrics listener: %w", err)
+	}
+	if srv != nil {
+		defer srv.Close()
+	}
+
+	var accounts []common.Address
+	var before balances
+	var reconcileToken common.Address
+	if config.Reconcile {
+		if usesTxType(config, TxTypeERC20) {
+			reconcileToken = tokenAddress(config)
+		}
+		if accounts, err = reconcileAddresses(config); err != nil {
+			return Report{}, fmt.Errorf("invalid scenario: %w", err)
+		}
+		if before, err = n.snapshotBalances(ctx, reconcileToken, accounts); err != nil {
+			return Report{}, fmt.Errorf("failed to snapshot balances: %w", err)
+		}
+	}
+
+	results, err := r.runScenario(ctx, config)
+	if err != nil {
+		return Report{}, fmt.Errorf("invalid scenario: %w", err)
+	}
+	report := buildReport(results)
+	report.NonceRepairs = r.nonces.nonceRepairs()
+	if config.Reconcile {
+		// The run's ctx may be spent by now; the balances are still owed.
+		ctx := context.WithoutCancel(ctx)
+		n.awaitPending(ctx, results, report.NonceRepairs)
+		after, err := n.snapshotBalances(ctx, reconcileToken, accounts)
+		if err != nil {
+			return report, fmt.Errorf("failed to snapshot balances: %w", err)
+		}
+		rec, err := n.reconcile(ctx, before, after, results, report.NonceRepairs)
+		if err != nil {
+			return report, fmt.Errorf("failed to reconcile balances: %w", err)
+		}
+		report.Reconciliation = &rec
+	}
+	return report, nil
+}
+
+// newResolver returns a resolver for the env:, file: and keystore:
+// references of keys and rpcUrl; keystore: files are decrypted with
+// ETH_KEYSTORE_PASSPHRASE.
+func newResolver() *secrets.Resolver {
+	return secrets.NewResolver(func() (string, error) { return readPassphrase("", "") })
+}
+
+// loadPrivateKey parses a hex key. References must already be resolved.
+func loadPrivateKey(hexkey string) (*ecdsa.PrivateKey, common.Address, error) {
+	hexkey = strings.TrimPrefix(strings.TrimSpace(hexkey), "0x")
+	key, err := crypto.HexToECDSA(hexkey)
+	if err != nil {
+		return nil, common.Address{}, fmt.Errorf("invalid private key: %w", err)
+	}
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	return key, addr, nil
+}
+
+func (n *node) checkBalance(ctx context.Context, addr common.Address, tokenAddr common.Address, amount *big.Int) error {
+	if err := n.checkETHBalance(ctx, addr, new(big.Int)); err != nil {
+		return err
+	}
+	// token balance check
+	balance, err := n.tokenBalance(ctx, tokenAddr, addr)
+	if err != nil {
+		return err
+	}
+	if balance.Cmp(amount) < 0 {
+		return fmt.Errorf("insufficient token balance for transfer amount: %s", balance.String())
+	}
+	return nil
+}
+
+// checkETHBalance checks that addr can send value wei and still pay ~0.001 ETH of gas.
+func (n *node) checkETHBalance(ctx context.Context, addr common.Address, value *big.Int) error {
+	ethBalance, err := n.client.BalanceAt(ctx, addr, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get ETH balance: %w", err)
+	}
+	need := new(big.Int).Add(value, big.NewInt(1e15))
+	if ethBalance.Cmp(need) < 0 {
+		if value.Sign() > 0 {
+			return fmt.Errorf("insufficient ETH balance: %s wei (need %s wei plus ~0.001 ETH for gas)", ethBalance.String(), value.String())
+		}
+		return fmt.Errorf("insufficient ETH balance: %s wei (need ~0.001 ETH)", ethBalance.String())
+	}
+	return nil
+}
+
+func (n *node) tokenBalance(ctx context.Context, tokenAddr, owner common.Address) (*big.Int, error) {
+	balanceOfABI := `[{"constant":true,"inputs":[{"name":"_owner","type":"address"}],"name":"balanceOf","outputs":[{"name":"balance","type":"uint256"}],"type":"function"}]`
+	parsedABI, err := abi.JSON(strings.NewReader(balanceOfABI))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ABI: %w", err)
+	}
+	bound := bind.NewBoundContract(tokenAddr, parsedABI, n.client, n.client, n.client)
+	var res []interface{}
+	err = bound.Call(&bind.CallOpts{Context: ctx}, &res, "balanceOf", owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token balance: %w", err)
+	}
+	return res[0].(*big.Int), nil
+}
+
+// sendTransfer signs and sends tx. The signed tx is returned even when
+// sending fails, so callers can tell which hash the node rejected.
+func (n *node) sendTransfer(ctx context.Context, key *ecdsa.PrivateKey, tx *types.Transaction) (*types.Transaction, error) {
+	signed, err := types.SignTx(tx, types.LatestSignerForChainID(n.chainID), key)
+	if err != nil {
+		return nil, fmt.Errorf("transfer failed: %w", err)
+	}
+	if err := n.client.SendTransaction(ctx, signed); err != nil {
+		return signed, fmt.Errorf("transfer failed: %w", err)
+	}
+	return signed, nil
+}
+
+// checkFunds checks that from holds what req spends.
+func (n *node) checkFunds(ctx context.Context, from common.Address, req txRequest) error {
+	switch req.txType {
+	case TxTypeERC20:
+		return n.checkBalance(ctx, from, req.to, req.amount)
+	case TxTypeERC721:
+		return n.checkOwnership(ctx, from, req.to, req.tokenID)
+	case TxTypeERC1155:
+		return n.checkERC1155Balance(ctx, from, req.to, req.tokenID, req.amount)
+	}
+	return n.checkETHBalance(ctx, from, req.value)
+}
+
+// WriteResults writes results to path in the eth_result.json format, with
+// every registered secret redacted.
+func WriteResults(path string, results []TransferResult) error {
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal results for %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, secrets.RedactBytes(data), 0644); err != nil {
+		return fmt.Errorf("failed writing results to %s: %w", path, err)
+	}
+	return nil
+}
+
+// printf writes a progress message to w; a nil w discards it.
+func printf(w io.Writer, format string, a ...any) {
+	if w != nil {
+		fmt.Fprintf(w, format, a...)
+	}
+}
+
+// metricsLabel is the runner label of r's live metrics.
+func (r *Runner) metricsLabel() string {
+	if r.MetricsLabel != "" {
+		return r.MetricsLabel
+	}
+	return metricsRunner
+}
+
+// isTransientError heuristically determines whether an error is temporary and worth retrying.
+func isTransientError(err string) bool {
+	if err == "" {
+		return false
+	}
+	l := strings.ToLower(err)
+	return strings.Contains(l, "nonce") || strings.Contains(l, "timeout") || strings.Contains(l, "rpc") || strings.Contains(l, "temporary") || strings.Contains(l, "context deadline exceeded") || strings.Contains(l, "rate limit")
+}
+
+// robustExecuteTransfer performs a transfer with retry logic for transient errors.
+func (r *Runner) robustExecuteTransfer(ctx context.Context, privateKeyHex string, req txRequest, shouldWaitForReceipt bool, retries int, backoff time.Duration) TransferResult {
+	var last TransferResult
+	done := metrics.Started(r.metricsLabel())
+	defer done()
+	if retries <= 0 {
+		retries = 1
+	}
+	for i := 0; i < retries; i++ {
+		last = r.executeTransfer(ctx, privateKeyHex, req, shouldWaitForReceipt)
+		if last.TxHash != "" {
+			// The tx was broadcast, and may be mined even without a receipt
+			// yet; resending would double-spend.
+			return last
+		}
+		if isTransientError(last.Error) && i+1 < retries {
+			metrics.Retried(r.metricsLabel())
+			time.Sleep(backoff)
+			backoff = backoff * 2
+			continue
+		}
+		// Non-transient error, stop retrying
+		return last
+	}
+	return last
+}
+
+// finish counts a finished transfer in the live metrics and hands it to
+// OnResult.
+func (r *Runner) finish(res TransferResult) TransferResult {
+	recordOutcome(r.metricsLabel(), res)
+	if r.OnResult != nil {
+		r.OnResult(res)
+	}
+	return res
+}
+
+// recordOutcome counts a finished transfer in the live metrics of runner.
+func recordOutcome(runner string, r TransferResult) {
+	switch r.Status {
+	case "success":
+		metrics.Succeeded(runner)
+	case "unconfirmed", "dropped", "cancelled", "mismatch":
+		metrics.Failed(runner, r.Status)
+	default:
+		metrics.Failed(runner, metrics.ClassifyError(r.Error))
+	}
+}
+
+func (r *Runner) executeTransfer(ctx context.Context, privateKeyHex string, req txRequest, shouldWaitForReceipt bool) TransferResult {
+	result := TransferResult{To: req.recipient.Hex(), TxType: req.txType, Status: "pending"}
+	if req.amount != nil {
+		result.Amount = req.amount.String()
+	}
+	if req.tokenID != nil {
+		result.TokenID = req.tokenID.String()
+	}
+	priv, fromAddr, err := loadPrivateKey(privateKeyHex)
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err.Error()
+		return result
+	}
+	_ = priv
+	result.From = fromAddr.Hex()
+	if err := r.checkFunds(ctx, fromAddr, req); err != nil {
+		result.Status = "failed"
+		result.Error = err.Error()
+		return result
+	}
+	fees, err := r.suggestFees(ctx)
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err.Error()
+		return result
+	}
+	gasLimit, err := r.limits.limit(ctx, fromAddr, req)
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err.Error()
+		return result
+	}
+	nonce, err := r.nonces.acquire(ctx, priv, fromAddr)
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err.Error()
+		return result
+	}
+	result.GasLimit = gasLimit
+	signedAt := time.Now()
+	tx, err := r.sendTransfer(ctx, priv, fees.newTx(nonce, &req.to, req.value, gasLimit, req.data))
+	r.nonces.settle(ctx, fromAddr, nonce, err)
+	if err != nil && tx != nil && sendTimedOut(ctx, err) {
+		// The node may have taken the tx before the send timed out; keep
+		// its hash so the run can still find it mined.
+		result.TxHash = tx.Hash().Hex()
+		result.Status = "unconfirmed"
+		result.Error = err.Error()
+		return result
+	}
+	if err != nil && !(tx != nil && strings.Contains(strings.ToLower(err.Error()), "already known")) {
+		result.Status = "failed"
+		result.Error = err.Error()
+		return result
+	}
+	// "already known" means this exact tx is already in the node's pool.
+	result.TxHash = tx.Hash().Hex()
+	result.SubmittedAt = time.Now()
+	result.SubmitLatencyMs = durationMs(result.SubmittedAt.Sub(signedAt))
+	metrics.Sent(r.metricsLabel(), result.SubmittedAt.Sub(signedAt))
+	if shouldWaitForReceipt {
+		tracked := newTrackedTx(priv, tx, result.SubmittedAt)
+		receipt, err := r.waitForReceipt(ctx, tracked)
+		result.Replacements = tracked.replacements
+		if receipt != nil {
+			if receipt.TxHash != tx.Hash() {
+				result.OriginalTxHash = result.TxHash
+				result.TxHash = receipt.TxHash.Hex()
+			}
+			result.GasUsed = receipt.GasUsed
+			if receipt.EffectiveGasPrice != nil {
+				result.EffectiveGasPrice = receipt.EffectiveGasPrice.String()
+			}
+		}
+		if err != nil && (ctx.Err() != nil || errors.Is(err, errReceiptTimeout)) {
+			// Broadcast but not mined in time; it may still land.
+			result.Status = "unconfirmed"
+			result.Error = fmt.Sprintf("receipt error: %v", err)
+			return result
+		}
+		if err != nil {
+			result.Status = "failed"
+			result.Error = fmt.Sprintf("receipt error: %v", err)
+			return result
+		}
+		result.BlockNumber = receipt.BlockNumber.Uint64()
+		if rep, ok := tracked.replacement(receipt.TxHash); ok && rep.Cancel {
+			result.Status = "cancelled"
+			result.Error = "stuck transfer cancelled by a 0-value self transfer"
+			return result
+		}
+		inclusion := max(tracked.minedAt.Sub(result.SubmittedAt), 0)
+		result.InclusionLatencyMs = durationMs(inclusion)
+		result.ConfirmationLatencyMs = durationMs(time.Since(tracked.minedAt))
+		metrics.Included(r.metricsLabel(), inclusion)
+		// Deposits are credited from transfer events, so a mined token
+		// transfer only counts when its event moves exactly what was sent.
+		if err := verifyReceipt(receipt, fromAddr, req); err != nil {
+			result.Status = "mismatch"
+			result.Error = err.Error()
+			return result
+		}
+	}
+	result.Status = "success"
+	return result
+}
+
+// tokenAddress is the ERC20 contract under test; contractAddr overrides erc20Contract.
+func tokenAddress(config TestConfig) common.Address {
+	if strings.TrimSpace(config.ContractAddr) != "" {
+		return common.HexToAddress(config.ContractAddr)
+	}
+	return common.HexToAddress(config.ERC20Contract)
+}
+
+// runScenario sends the transfers of config and returns their results.
+func (r *Runner) runScenario(ctx context.Context, config TestConfig) ([]TransferResult, error) {
+	pairs, err := buildPairs(config, r.Output)
+	if err != nil {
+		return nil, err
+	}
+	printPairs(r.Output, config.Scenario, pairs)
+
+	var results []TransferResult
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	cap := config.MaxGoroutines
+	if cap <= 0 {
+		cap = 5
+	}
+	sem := make(chan struct{}, cap)
+
+	decimals := config.Decimals
+	if decimals <= 0 {
+		decimals = 18
+	}
+	loopCount := config.LoopCount
+	if loopCount <= 0 {
+		loopCount = 1
+	}
+	delay := time.Duration(config.Delay) * time.Millisecond
+
+	load, err := newWorkload(ctx, r.node, config, decimals)
+	if err != nil {
+		return nil, err
+	}
+	retryBackoff := time.Duration(config.RetryBackoffMs) * time.Millisecond
+	send := func(ctx context.Context, p transferPair) TransferResult {
+		req, err := load.next(p)
+		if err != nil {
+			return r.finish(TransferResult{From: p.Sender.Hex(), To: p.Recipient.Hex(), TxType: req.txType, Status: "failed", Error: err.Error()})
+		}
+		res := r.robustExecuteTransfer(ctx, p.SenderKey, req, config.WaitForReceipt, config.RetryCount, retryBackoff)
+		load.done(p, req, res)
+		return r.finish(res)
+	}
+	nonceCheck := defaultNonceCheckInterval
+	if strings.TrimSpace(config.NonceCheckInterval) != "" {
+		nonceCheck, err = time.ParseDuration(config.NonceCheckInterval)
+		if err != nil || nonceCheck <= 0 {
+			return nil, fmt.Errorf("invalid nonceCheckInterval %q", config.NonceCheckInterval)
+		}
+	}
+	if config.WaitForReceipt {
+		headPoll := defaultHeadPollInterval
+		if strings.TrimSpace(config.HeadPollInterval) != "" {
+			headPoll, err = time.ParseDuration(config.HeadPollInterval)
+			if err != nil || headPoll <= 0 {
+				return nil, fmt.Errorf("invalid headPollInterval %q", config.HeadPollInterval)
+			}
+		}
+		if r.receipts, err = startReceiptTracker(r.client, headPoll, r.Output); err != nil {
+			return nil, err
+		}
+		defer r.receipts.stop()
+	}
+	stopGapWatch := r.nonces.watchGaps(nonceCheck)
+	defer stopGapWatch()
+
+	timing := r.timing
+	if len(config.Phases) > 0 {
+		return r.runPhases(ctx, config, pairs, timing.grace, send)
+	}
+	if timing.duration > 0 {
+		start := time.Now()
+		deadline := start.Add(timing.duration)
+		ctx, cancel := context.WithDeadline(ctx, deadline.Add(timing.grace))
+		defer cancel()
+		if config.TargetTPS > 0 {
+			maxInFlight := openLoopInFlight(config, config.TargetTPS)
+			printf(r.Output, "Open-loop: %.2f tx/s for %s, grace %s (max in-flight %d)\n", config.TargetTPS, timing.duration, timing.grace, maxInFlight)
+			return r.runOpenLoop(ctx, config, &pairCycler{pairs: pairs}, constantRate(start, config.TargetTPS, 0, deadline), maxInFlight, send), nil
+		}
+		printf(r.Output, "Closed-loop: %d pair(s) for %s, grace %s (max %d in flight)\n", len(pairs), timing.duration, timing.grace, cap)
+		return runForDuration(ctx, pairs, deadline, cap, delay, send), nil
+	}
+	if config.TargetTPS > 0 {
+		total := len(pairs) * loopCount
+		maxInFlight := openLoopInFlight(config, config.TargetTPS)
+		printf(r.Output, "Open-loop: %d transfers at %.2f tx/s (max in-flight %d)\n", total, config.TargetTPS, maxInFlight)
+		return r.runOpenLoop(ctx, config, &pairCycler{pairs: pairs}, constantRate(time.Now(), config.TargetTPS, total, time.Time{}), maxInFlight, send), nil
+	}
+
+	for _, pair := range pairs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(p transferPair) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			for i := 0; i < loopCount && ctx.Err() == nil; i++ {
+				tctx, cancel := r.transferContext(ctx)
+				res := send(tctx, p)
+				cancel()
+				mu.Lock()
+				results = append(results, res)
+				mu.Unlock()
+				if delay > 0 {
+					time.Sleep(delay)
+				}
+			}
+		}(pair)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// PrintReport prints the results of report to w, then its summary, latency,
+// phases, nonce repairs, schedule and reconciliation.
+func PrintReport(w io.Writer, report Report) {
+	results := report.Results
+	success, failed, unconfirmed, mismatched := 0, 0, 0, 0
+	byType := make(map[string]int)
+	fmt.Fprintln(w, "\n========== RESULTS ==========")
+	for i, r := range results {
+		fmt.Fprintf(w, "\nTransfer %d:\n", i+1)
+		if r.TxType != "" && r.TxType != TxTypeERC20 {
+			fmt.Fprintf(w, "  Type: %s\n", r.TxType)
+		}
+		byType[r.TxType]++
+		fmt.Fprintf(w, "  From: %s\n", r.From)
+		fmt.Fprintf(w, "  To: %s\n", r.To)
+		fmt.Fprintf(w, "  TxHash: %s\n", r.TxHash)
+		fmt.Fprintf(w, "  Status: %s\n", r.Status)
+		if r.Phase != "" {
+			fmt.Fprintf(w, "  Phase: %s\n", r.Phase)
+		}
+		if r.Error != "" {
+			fmt.Fprintf(w, "  Error: %s\n", secrets.Redact(r.Error))
+		}
+		if len(r.Replacements) > 0 {
+			fmt.Fprintf(w, "  Replacements: %d\n", len(r.Replacements))
+		}
+		if r.OriginalTxHash != "" {
+			fmt.Fprintf(w, "  Original TxHash: %s\n", r.OriginalTxHash)
+		}
+		if r.BlockNumber > 0 {
+			fmt.Fprintf(w, "  Block: %d\n", r.BlockNumber)
+		}
+		switch r.Status {
+		case "success":
+			success++
+		case "unconfirmed":
+			unconfirmed++
+		case "mismatch":
+			mismatched++
+			failed++
+		default:
+			failed++
+		}
+	}
+	fmt.Fprintln(w, "\n========== SUMMARY ==========")
+	fmt.Fprintf(w, "Total: %d | Success: %d | Failed: %d\n", len(results), success, failed)
+	if len(byType) > 1 {
+		var counts []string
+		for _, t := range txTypes {
+			if byType[t] > 0 {
+				counts = append(counts, fmt.Sprintf("%d %s", byType[t], t))
+			}
+		}
+		fmt.Fprintf(w, "By type: %s\n", strings.Join(counts, " | "))
+	}
+	if unconfirmed > 0 {
+		fmt.Fprintf(w, "Unconfirmed (no receipt within the grace period): %d\n", unconfirmed)
+	}
+	if mismatched > 0 {
+		fmt.Fprintf(w, "Mismatched (mined, but the Transfer event does not match): %d\n", mismatched)
+	}
+	printLatency(w, report.Summary)
+	printPhases(w, results)
+	printNonceRepairs(w, report.NonceRepairs)
+	printSchedule(w, "\n========== SCHEDULE ==========", results)
+	if report.Reconciliation != nil {
+		printReconciliation(w, *report.Reconciliation)
+	}
+}
@@ -0,0 +1,63 @@
+package ethload
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"tron_load/hdwallet"
+	"tron_load/secrets"
+)
+
+// senderKeys returns the hex sender keys of config with every secret
+// resolved: the keys of its keystore and mnemonic when it has either, printing
+// the address of each to out, otherwise its senderKeys.
+func senderKeys(resolver *secrets.Resolver, config TestConfig, out io.Writer) ([]string, error) {
+	if !hasSenderSecrets(config) {
+		keys, err := resolver.ResolveAll(config.SenderKeys)
+		if err != nil {
+			return nil, fmt.Errorf("senderKeys: %w", err)
+		}
+		return keys, nil
+	}
+	var keys []string
+	if config.Keystore != nil {
+		ks, err := config.Keystore.keys()
+		if err != nil {
+			return nil, fmt.Errorf("keystore: %w", err)
+		}
+		printf(out, "Keystore: %d sender(s)\n", len(ks))
+		for _, k := range ks {
+			printf(out, "  %s\n", crypto.PubkeyToAddress(k.PublicKey).Hex())
+			keys = append(keys, registerKey(k))
+		}
+	}
+	if config.HDWallet != nil {
+		hd, err := config.HDWallet.Derive(hdwallet.EthereumPath)
+		if err != nil {
+			return nil, fmt.Errorf("hdWallet: %w", err)
+		}
+		printf(out, "HD wallet: %d sender(s)\n", len(hd))
+		for _, k := range hd {
+			printf(out, "  %s  %s\n", k.Path, crypto.PubkeyToAddress(k.PrivateKey.PublicKey).Hex())
+			keys = append(keys, registerKey(k.PrivateKey))
+		}
+	}
+	return keys, nil
+}
+
+// hasSenderSecrets reports whether config takes its senders from a keystore
+// or mnemonic, in which case its senderKeys are ignored.
+func hasSenderSecrets(config TestConfig) bool {
+	return config.Keystore != nil || config.HDWallet != nil
+}
+
+// registerKey returns key in hex, registered for redaction.
+func registerKey(key *ecdsa.PrivateKey) string {
+	hexkey := hex.EncodeToString(crypto.FromECDSA(key))
+	secrets.Register(hexkey)
+	return hexkey
+}
@@ -0,0 +1,156 @@
+package ethload
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+)
+
+// SimOptions configures a SimHarness.
+type SimOptions struct {
+	Senders    int           // sender keys to generate
+	Recipients int           // recipients to generate
+	BlockTime  time.Duration // time between blocks
+	Supply     *big.Int      // whole test tokens minted to each sender
+	Output     io.Writer     // progress messages, of the runners too; nil discards them
+}
+
+// SimHarness is an in-process simulated chain with funded senders and the
+// test token deployed, for running scenarios without a network.
+type SimHarness struct {
+	sim         *simulated.Backend
+	client      Client
+	senderKeys  []string
+	recipients  []string
+	token       common.Address
+	stopSealing func()
+	out         io.Writer
+}
+
+// NewSimHarness starts a simulated chain sealing a block every
+// opts.BlockTime with opts.Senders generated keys funded in genesis, and
+// deploys the test token minting opts.Supply to each. Close stops the chain.
+func NewSimHarness(ctx context.Context, opts SimOptions) (*SimHarness, error) {
+	if opts.Senders <= 0 || opts.Recipients <= 0 {
+		return nil, fmt.Errorf("senders and recipients must be positive")
+	}
+	if opts.BlockTime <= 0 {
+		return nil, fmt.Errorf("block-time must be positive")
+	}
+	if opts.Supply == nil || opts.Supply.Sign() <= 0 {
+		return nil, fmt.Errorf("supply must be positive")
+	}
+	h := &SimHarness{out: opts.Output}
+	deployerHex := newKeyHex()
+	for i := 0; i < opts.Senders; i++ {
+		h.senderKeys = append(h.senderKeys, newKeyHex())
+	}
+	for i := 0; i < opts.Recipients; i++ {
+		_, addr, _ := loadPrivateKey(newKeyHex())
+		h.recipients = append(h.recipients, addr.Hex())
+	}
+	alloc := types.GenesisAlloc{}
+	for _, k := range append([]string{deployerHex}, h.senderKeys...) {
+		_, addr, _ := loadPrivateKey(k)
+		alloc[addr] = types.Account{Balance: simulatedFunding}
+	}
+	h.sim = simulated.NewBackend(alloc)
+	c, ok := h.sim.Client().(Client)
+	if !ok {
+		h.sim.Close()
+		return nil, fmt.Errorf("simulated client lacks the runner's node API")
+	}
+	h.client = c
+	h.stopSealing = sealBlocks(h.sim, opts.BlockTime)
+
+	n, err := newNode(h.client, TestConfig{})
+	if err == nil {
+		n.out = h.out
+		err = n.connect(ctx, TestConfig{})
+	}
+	if err != nil {
+		h.Close()
+		return nil, err
+	}
+	deployer, _, _ := loadPrivateKey(deployerHex)
+	supply := new(big.Int).Mul(opts.Supply, new(big.Int).Exp(big.NewInt(10), big.NewInt(testTokenDecimals), nil))
+	token, err := n.deployTestToken(ctx, deployer, h.senderKeys, supply)
+	if err != nil {
+		h.Close()
+		return nil, err
+	}
+	h.token = token
+	return h, nil
+}
+
+// Close stops the chain.
+func (h *SimHarness) Close() {
+	h.stopSealing()
+	h.sim.Close()
+}
+
+// Run runs config against the chain as scenario, with the harness's token,
+// senders and recipients (only the first one on a "one" side). It checks that
+// every transfer succeeded and, for erc20 and native transfers, that the
+// balances reconcile.
+func (h *SimHarness) Run(ctx context.Context, config TestConfig, scenario string) error {
+	chainID, err := h.client.ChainID(ctx)
+	if err != nil {
+		return err
+	}
+	config.Scenario = scenario
+	config.Chain, config.RPCURL, config.ChainID = "", "simulated", chainID.Uint64()
+	config.Keystore, config.HDWallet, config.Funding = nil, nil, nil
+	config.ERC20Contract = h.token.Hex()
+	config.ContractAddr = ""
+	config.Decimals = testTokenDecimals
+	config.SenderKeys = h.senderKeys
+	config.Recipients = h.recipients
+	switch scenario {
+	case ScenarioOneToMany:
+		config.SenderKeys = h.senderKeys[:1]
+	case ScenarioManyToOne:
+		config.Recipients = h.recipients[:1]
+	}
+	config.WaitForReceipt = true
+	config.Reconcile = !usesTxType(config, TxTypeContractCall) && !usesTxType(config, TxTypeERC721) && !usesTxType(config, TxTypeERC1155)
+
+	r, err := NewRunner(config)
+	if err != nil {
+		return err
+	}
+	r.Client, r.Output = h.client, h.out
+	report, err := r.Run(ctx)
+	if err != nil {
+		return err
+	}
+	results := report.Results
+	failed := 0
+	for _, res := range results {
+		if res.Status != "success" {
+			failed++
+			printf(h.out, "%s: %s -> %s %s: %s\n", scenario, res.From, res.To, res.Status, res.Error)
+		}
+	}
+	if len(results) == 0 || failed > 0 {
+		return fmt.Errorf("%d of %d transfers did not succeed", failed, len(results))
+	}
+	if report.Reconciliation == nil {
+		printf(h.out, "%s: %d/%d succeeded\n", scenario, len(results), len(results))
+		return nil
+	}
+	if !report.Reconciliation.OK {
+		if h.out != nil {
+			printReconciliation(h.out, *report.Reconciliation)
+		}
+		return fmt.Errorf("balances do not reconcile")
+	}
+	printf(h.out, "%s: %d/%d succeeded, balances reconciled\n", scenario, len(results), len(results))
+	return nil
+}
@@ -0,0 +1,42 @@
+package ethload
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestSimHarnessScenarios(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs a simulated chain")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	h, err := NewSimHarness(ctx, SimOptions{
+		Senders:    2,
+		Recipients: 2,
+		BlockTime:  50 * time.Millisecond,
+		Supply:     big.NewInt(1_000_000),
+	})
+	if err != nil {
+		t.Fatalf("NewSimHarness: %v", err)
+	}
+	defer h.Close()
+
+	config := TestConfig{
+		LoopCount:      2,
+		MinAmount:      1,
+		MaxAmount:      5,
+		MaxGoroutines:  4,
+		RetryCount:     2,
+		RetryBackoffMs: 100,
+	}
+	for _, scenario := range []string{ScenarioOneToOne, ScenarioOneToMany, ScenarioManyToOne, ScenarioManyToMany} {
+		t.Run(scenario, func(t *testing.T) {
+			if err := h.Run(ctx, config, scenario); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
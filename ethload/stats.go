@@ -0,0 +1,260 @@
+package ethload
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// latencyBucketsMs are the upper bounds of the latency histogram buckets; a
+// final +Inf bucket catches everything above the last bound.
+var latencyBucketsMs = []float64{50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000, 60000, 120000}
+
+// throughputIntervals are the bucket widths tried, smallest first, when
+// slicing a run into at most maxThroughputPoints throughput buckets.
+var throughputIntervals = []time.Duration{
+	time.Second, 2 * time.Second, 5 * time.Second, 10 * time.Second, 15 * time.Second, 30 * time.Second,
+	time.Minute, 2 * time.Minute, 5 * time.Minute, 10 * time.Minute, 15 * time.Minute, 30 * time.Minute, time.Hour,
+}
+
+const maxThroughputPoints = 60
+
+// HistogramBucket counts samples at or below Le milliseconds (cumulative, as
+// in Prometheus); Le is "+Inf" for the overflow bucket.
+type HistogramBucket struct {
+	Le    string `json:"le"`
+	Count int    `json:"count"`
+}
+
+// LatencySummary describes one latency distribution in milliseconds.
+type LatencySummary struct {
+	Count     int               `json:"count"`
+	MeanMs    float64           `json:"meanMs"`
+	P50Ms     float64           `json:"p50Ms"`
+	P90Ms     float64           `json:"p90Ms"`
+	P95Ms     float64           `json:"p95Ms"`
+	P99Ms     float64           `json:"p99Ms"`
+	MaxMs     float64           `json:"maxMs"`
+	Histogram []HistogramBucket `json:"histogram,omitempty"`
+}
+
+// ThroughputPoint is one time bucket of a run, offset from the run start.
+type ThroughputPoint struct {
+	OffsetSec float64 `json:"offsetSec"`
+	Submitted int     `json:"submitted"` // broadcasts acknowledged by the RPC
+	Confirmed int     `json:"confirmed"` // successful receipts observed
+}
+
+// RunSummary aggregates a set of transfer results.
+type RunSummary struct {
+	Total               int               `json:"total"`
+	Success             int               `json:"success"`
+	Failed              int               `json:"failed"`
+	Unconfirmed         int               `json:"unconfirmed,omitempty"`
+	Dropped             int               `json:"dropped,omitempty"`
+	Mismatched          int               `json:"mismatched,omitempty"`
+	StartedAt           time.Time         `json:"startedAt,omitzero"`
+	ElapsedSec          float64           `json:"elapsedSec"`
+	SubmitTPS           float64           `json:"submitTps"`
+	ConfirmTPS          float64           `json:"confirmTps"`
+	SubmitLatency       LatencySummary    `json:"submitLatency"`       // sign -> RPC ack
+	InclusionLatency    LatencySummary    `json:"inclusionLatency"`    // RPC ack -> receipt first seen
+	ConfirmationLatency LatencySummary    `json:"confirmationLatency"` // receipt first seen -> confirmations deep
+	ScheduleLag         *LatencySummary   `json:"scheduleLag,omitempty"`
+	IntervalSec         float64           `json:"throughputIntervalSec,omitempty"`
+	Throughput          []ThroughputPoint `json:"throughput,omitempty"`
+}
+
+// PhaseSummary is the RunSummary of the results tagged with one phase.
+type PhaseSummary struct {
+	Name string `json:"name"`
+	RunSummary
+}
+
+// Report is the document written to results.json.
+type Report struct {
+	Summary        RunSummary       `json:"summary"`
+	Phases         []PhaseSummary   `json:"phases,omitempty"`
+	NonceRepairs   []NonceRepair    `json:"nonceRepairs,omitempty"`
+	Reconciliation *Reconciliation  `json:"reconciliation,omitempty"`
+	Results        []TransferResult `json:"results"`
+}
+
+func buildReport(results []TransferResult) Report {
+	report := Report{Summary: summarize(results), Results: results}
+	order, byPhase := groupByPhase(results)
+	for _, name := range order {
+		report.Phases = append(report.Phases, PhaseSummary{Name: name, RunSummary: summarize(byPhase[name])})
+	}
+	return report
+}
+
+func summarize(results []TransferResult) RunSummary {
+	s := RunSummary{Total: len(results)}
+	var submit, inclusion, confirmation, lag []float64
+	var end time.Time
+	for _, r := range results {
+		switch r.Status {
+		case "success":
+			s.Success++
+		case "unconfirmed":
+			s.Unconfirmed++
+		case "dropped":
+			s.Dropped++
+			s.Failed++
+		case "mismatch":
+			s.Mismatched++
+			s.Failed++
+		default:
+			s.Failed++
+		}
+		if !r.ScheduledAt.IsZero() {
+			lag = append(lag, r.ScheduleLagMs)
+		}
+		if r.SubmittedAt.IsZero() {
+			continue
+		}
+		submit = append(submit, r.SubmitLatencyMs)
+		started := r.SubmittedAt.Add(-msDuration(r.SubmitLatencyMs))
+		if s.StartedAt.IsZero() || started.Before(s.StartedAt) {
+			s.StartedAt = started
+		}
+		done := r.SubmittedAt
+		if r.Status == "success" && r.InclusionLatencyMs > 0 {
+			inclusion = append(inclusion, r.InclusionLatencyMs)
+			confirmation = append(confirmation, r.ConfirmationLatencyMs)
+			done = r.confirmedAt()
+		}
+		if done.After(end) {
+			end = done
+		}
+	}
+	s.SubmitLatency = latencySummary(submit)
+	s.InclusionLatency = latencySummary(inclusion)
+	s.ConfirmationLatency = latencySummary(confirmation)
+	if len(lag) > 0 {
+		l := latencySummary(lag)
+		s.ScheduleLag = &l
+	}
+	if s.StartedAt.IsZero() {
+		return s
+	}
+	elapsed := end.Sub(s.StartedAt)
+	s.ElapsedSec = elapsed.Seconds()
+	if s.ElapsedSec > 0 {
+		s.SubmitTPS = float64(len(submit)) / s.ElapsedSec
+		s.ConfirmTPS = float64(s.Success) / s.ElapsedSec
+	}
+
+	interval := throughputIntervals[len(throughputIntervals)-1]
+	for _, iv := range throughputIntervals {
+		if elapsed/iv < maxThroughputPoints {
+			interval = iv
+			break
+		}
+	}
+	s.IntervalSec = interval.Seconds()
+	s.Throughput = make([]ThroughputPoint, int(elapsed/interval)+1)
+	for i := range s.Throughput {
+		s.Throughput[i].OffsetSec = float64(i) * s.IntervalSec
+	}
+	for _, r := range results {
+		if r.SubmittedAt.IsZero() {
+			continue
+		}
+		s.Throughput[int(r.SubmittedAt.Sub(s.StartedAt)/interval)].Submitted++
+		if r.Status == "success" && r.InclusionLatencyMs > 0 {
+			s.Throughput[int(r.confirmedAt().Sub(s.StartedAt)/interval)].Confirmed++
+		}
+	}
+	return s
+}
+
+// confirmedAt is when a successful transfer was confirmations blocks deep.
+func (r TransferResult) confirmedAt() time.Time {
+	return r.SubmittedAt.Add(msDuration(r.InclusionLatencyMs + r.ConfirmationLatencyMs))
+}
+
+func latencySummary(samples []float64) LatencySummary {
+	s := LatencySummary{Count: len(samples)}
+	if len(samples) == 0 {
+		return s
+	}
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
+	total := 0.0
+	for _, v := range sorted {
+		total += v
+	}
+	s.MeanMs = total / float64(len(sorted))
+	s.P50Ms = percentile(sorted, 50)
+	s.P90Ms = percentile(sorted, 90)
+	s.P95Ms = percentile(sorted, 95)
+	s.P99Ms = percentile(sorted, 99)
+	s.MaxMs = sorted[len(sorted)-1]
+
+	i := 0
+	for _, le := range latencyBucketsMs {
+		for i < len(sorted) && sorted[i] <= le {
+			i++
+		}
+		s.Histogram = append(s.Histogram, HistogramBucket{Le: strconv.FormatFloat(le, 'f', -1, 64), Count: i})
+	}
+	s.Histogram = append(s.Histogram, HistogramBucket{Le: "+Inf", Count: len(sorted)})
+	return s
+}
+
+// percentile returns the nearest-rank percentile p of an ascending slice.
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func msDuration(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+func groupByPhase(results []TransferResult) ([]string, map[string][]TransferResult) {
+	var order []string
+	byPhase := make(map[string][]TransferResult)
+	for _, r := range results {
+		if r.Phase == "" {
+			continue
+		}
+		if _, seen := byPhase[r.Phase]; !seen {
+			order = append(order, r.Phase)
+		}
+		byPhase[r.Phase] = append(byPhase[r.Phase], r)
+	}
+	return order, byPhase
+}
+
+func formatLatency(l LatencySummary) string {
+	if l.Count == 0 {
+		return "n=0"
+	}
+	return fmt.Sprintf("n=%d | p50 %.1fms | p90 %.1fms | p95 %.1fms | p99 %.1fms | max %.1fms", l.Count, l.P50Ms, l.P90Ms, l.P95Ms, l.P99Ms, l.MaxMs)
+}
+
+// printLatency prints the latency percentiles and the throughput timeline.
+func printLatency(w io.Writer, s RunSummary) {
+	if s.SubmitLatency.Count == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\n========== LATENCY ==========")
+	fmt.Fprintf(w, "Submit (sign -> ack):       %s\n", formatLatency(s.SubmitLatency))
+	fmt.Fprintf(w, "Inclusion (ack -> receipt): %s\n", formatLatency(s.InclusionLatency))
+	if s.ConfirmationLatency.MaxMs > 0 {
+		fmt.Fprintf(w, "Confirmation (receipt -> confirmed): %s\n", formatLatency(s.ConfirmationLatency))
+	}
+	fmt.Fprintf(w, "Throughput: %.2f submitted/s | %.2f confirmed/s over %.1fs\n", s.SubmitTPS, s.ConfirmTPS, s.ElapsedSec)
+	for _, p := range s.Throughput {
+		fmt.Fprintf(w, "  +%-7s submitted %-5d confirmed %d\n", time.Duration(p.OffsetSec*float64(time.Second)), p.Submitted, p.Confirmed)
+	}
+}
@@ -0,0 +1,65 @@
+package ethload
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	ten := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	tests := []struct {
+		name   string
+		sorted []float64
+		p      float64
+		want   float64
+	}{
+		{"single sample p0", []float64{7}, 0, 7},
+		{"single sample p50", []float64{7}, 50, 7},
+		{"single sample p100", []float64{7}, 100, 7},
+		{"p0 is the minimum", ten, 0, 1},
+		{"p10 nearest rank", ten, 10, 1},
+		{"p11 rounds the rank up", ten, 11, 2},
+		{"p50", ten, 50, 5},
+		{"p90", ten, 90, 9},
+		{"p95", ten, 95, 10},
+		{"p99", ten, 99, 10},
+		{"p100 is the maximum", ten, 100, 10},
+		{"two samples p50", []float64{1, 100}, 50, 1},
+		{"two samples p51", []float64{1, 100}, 51, 100},
+	}
+	for _, tt := range tests {
+		if got := percentile(tt.sorted, tt.p); got != tt.want {
+			t.Errorf("%s: percentile(%v, %v) = %v, want %v", tt.name, tt.sorted, tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestSummarizeConfirmationLatency(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	results := []TransferResult{
+		{Status: "success", SubmittedAt: start.Add(100 * time.Millisecond), SubmitLatencyMs: 100, InclusionLatencyMs: 1000, ConfirmationLatencyMs: 2000},
+		{Status: "success", SubmittedAt: start.Add(200 * time.Millisecond), SubmitLatencyMs: 200, InclusionLatencyMs: 3000, ConfirmationLatencyMs: 4000},
+		{Status: "unconfirmed", SubmittedAt: start.Add(300 * time.Millisecond), SubmitLatencyMs: 300},
+	}
+	s := summarize(results)
+	if s.InclusionLatency.Count != 2 || s.InclusionLatency.MaxMs != 3000 {
+		t.Errorf("inclusion latency %+v, want 2 samples up to 3000ms", s.InclusionLatency)
+	}
+	if s.ConfirmationLatency.Count != 2 || s.ConfirmationLatency.P50Ms != 2000 || s.ConfirmationLatency.MaxMs != 4000 {
+		t.Errorf("confirmation latency %+v, want 2 samples of 2000 and 4000ms", s.ConfirmationLatency)
+	}
+	// The run ends when the last transfer is confirmed, not when its receipt
+	// is first seen: 200ms + 3s + 4s after the start.
+	if s.ElapsedSec != 7.2 {
+		t.Errorf("elapsed %vs, want 7.2s", s.ElapsedSec)
+	}
+	var confirmed []float64
+	for _, p := range s.Throughput {
+		for i := 0; i < p.Confirmed; i++ {
+			confirmed = append(confirmed, p.OffsetSec)
+		}
+	}
+	if len(confirmed) != 2 || confirmed[0] != 3 || confirmed[1] != 7 {
+		t.Errorf("confirmed at %v s, want 3 and 7", confirmed)
+	}
+}
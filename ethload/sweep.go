@@ -0,0 +1,287 @@
+package ethload
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"tron_load/secrets"
+)
+
+// sweepConcurrency is how many wallets Sweep empties at once by default.
+const sweepConcurrency = 5
+
+// SweepOptions configures Sweep.
+type SweepOptions struct {
+	Treasury       common.Address // receives the swept funds
+	KeysFile       string         // file of keys to sweep, hex or references, one per line
+	KeystoreDir    string         // keystore directory whose keys are swept
+	PassphraseEnv  string         // env var holding the keystore passphrase (default ETH_KEYSTORE_PASSPHRASE)
+	PassphraseFile string         // file holding the keystore passphrase, read when the env var is empty
+	Concurrency    int            // wallets swept at once (default 5)
+	Output         io.Writer      // progress messages; nil discards them
+}
+
+// Sweep sends the whole token balance and then the remaining ETH, less fees,
+// of every key of opts (or else every sender of config) to opts.Treasury. It
+// returns a result per transfer sent.
+func Sweep(ctx context.Context, config TestConfig, opts SweepOptions) ([]TransferResult, error) {
+	resolver := newResolver()
+	keys, err := sweepKeys(resolver, config, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load keys: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no keys to sweep: give a keys file, a keystore or a config with senderKeys")
+	}
+	if err := applyChainProfile(&config); err != nil {
+		return nil, fmt.Errorf("invalid chain: %w", err)
+	}
+	client, err := dialConfig(resolver, config)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	n, err := openNode(ctx, client, config, opts.Output)
+	if err != nil {
+		return nil, err
+	}
+
+	token := tokenAddress(config)
+	printf(opts.Output, "Sweeping %d wallet(s) to %s\n", len(keys), opts.Treasury.Hex())
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = sweepConcurrency
+	}
+	results := []TransferResult{}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	sem := make(chan struct{}, concurrency)
+	for _, key := range keys {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(key *ecdsa.PrivateKey) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			swept := n.sweepWallet(ctx, key, token, opts.Treasury)
+			mu.Lock()
+			results = append(results, swept...)
+			mu.Unlock()
+		}(key)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// sweepKeys collects the keys to sweep from opts.KeysFile, opts.KeystoreDir,
+// or else config's senders, dropping duplicates.
+func sweepKeys(resolver *secrets.Resolver, config TestConfig, opts SweepOptions) ([]*ecdsa.PrivateKey, error) {
+	var hexKeys []string
+	if opts.KeysFile != "" {
+		f, err := os.Open(opts.KeysFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line != "" && !strings.HasPrefix(line, "#") {
+				hexKeys = append(hexKeys, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		if hexKeys, err = resolver.ResolveAll(hexKeys); err != nil {
+			return nil, err
+		}
+	}
+	var keys []*ecdsa.PrivateKey
+	if opts.KeystoreDir != "" {
+		passphrase, err := readPassphrase(opts.PassphraseEnv, opts.PassphraseFile)
+		if err != nil {
+			return nil, err
+		}
+		if keys, err = decryptKeystore(opts.KeystoreDir, passphrase, 0); err != nil {
+			return nil, err
+		}
+	}
+	if opts.KeysFile == "" && opts.KeystoreDir == "" {
+		var err error
+		if hexKeys, err = senderKeys(resolver, config, opts.Output); err != nil {
+			return nil, err
+		}
+	}
+	for i, k := range hexKeys {
+		key, _, err := loadPrivateKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", i+1, err)
+		}
+		keys = append(keys, key)
+	}
+	seen := make(map[common.Address]bool)
+	unique := keys[:0]
+	for _, key := range keys {
+		addr := crypto.PubkeyToAddress(key.PublicKey)
+		if !seen[addr] {
+			seen[addr] = true
+			unique = append(unique, key)
+		}
+	}
+	return unique, nil
+}
+
+// sweepWallet sends the token balance of key, then its ETH less the fee of
+// that last transfer, to treasury. The ETH stays put when the token sweep
+// fails, so it can pay for another attempt.
+func (n *node) sweepWallet(ctx context.Context, key *ecdsa.PrivateKey, token, treasury common.Address) []TransferResult {
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	var results []TransferResult
+	failed := func(txType string, err error) []TransferResult {
+		return append(results, TransferResult{From: from.Hex(), To: treasury.Hex(), TxType: txType, Status: "failed", Error: err.Error()})
+	}
+
+	if token != (common.Address{}) {
+		balance, err := n.tokenBalance(ctx, token, from)
+		if err != nil {
+			return failed(TxTypeERC20, err)
+		}
+		if balance.Sign() > 0 {
+			data, err := erc20TransferData(treasury, balance)
+			if err != nil {
+				return failed(TxTypeERC20, err)
+			}
+			req := txRequest{txType: TxTypeERC20, recipient: treasury, to: token, value: new(big.Int), data: data, amount: balance}
+			r := n.sweepTx(ctx, key, req, nil)
+			results = append(results, r)
+			if r.Status != "success" {
+				return results
+			}
+		}
+	}
+
+	balance, err := n.client.BalanceAt(ctx, from, nil)
+	if err != nil {
+		return failed(TxTypeNative, fmt.Errorf("failed to get ETH balance: %w", err))
+	}
+	if balance.Sign() == 0 {
+		return results
+	}
+	req := txRequest{txType: TxTypeNative, recipient: treasury, to: treasury, value: new(big.Int)}
+	r := n.sweepTx(ctx, key, req, balance)
+	if r.Status != "skipped" {
+		results = append(results, r)
+	}
+	return results
+}
+
+// sweepTx sends req from key and waits for it to be mined. When ethBalance is
+// set, req sends that balance less the most the tx can pay in fees, and is
+// skipped when the balance does not cover them.
+func (n *node) sweepTx(ctx context.Context, key *ecdsa.PrivateKey, req txRequest, ethBalance *big.Int) TransferResult {
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	result := TransferResult{From: from.Hex(), To: req.recipient.Hex(), TxType: req.txType, Status: "failed"}
+	fail := func(err error) TransferResult {
+		result.Error = err.Error()
+		return result
+	}
+	fees, err := n.suggestFees(ctx)
+	if err != nil {
+		return fail(err)
+	}
+	var gas uint64
+	if ethBalance == nil {
+		gas, err = n.limits.estimate(ctx, from, req)
+	} else {
+		// No margin: the gas limit is paid for out of the swept balance, and
+		// a value transfer uses exactly its estimate.
+		gas, err = n.client.EstimateGas(ctx, ethereum.CallMsg{From: from, To: &req.to, Value: req.value})
+	}
+	if err != nil {
+		return fail(err)
+	}
+	if ethBalance != nil {
+		price := fees.gasPrice
+		if fees.dynamic() {
+			price = fees.feeCap
+		}
+		maxFee := new(big.Int).Mul(price, new(big.Int).SetUint64(gas))
+		req.value = new(big.Int).Sub(ethBalance, maxFee)
+		if req.value.Sign() <= 0 {
+			printf(n.out, "%s: %s wei does not cover the %s wei fee, leaving it\n", from.Hex(), ethBalance, maxFee)
+			result.Status = "skipped"
+			return result
+		}
+		req.amount = req.value
+	}
+	if req.amount != nil {
+		result.Amount = req.amount.String()
+	}
+	nonce, err := n.client.PendingNonceAt(ctx, from)
+	if err != nil {
+		return fail(fmt.Errorf("failed to get nonce: %w", err))
+	}
+	result.GasLimit = gas
+	signedAt := time.Now()
+	signed, err := n.sendTransfer(ctx, key, fees.newTx(nonce, &req.to, req.value, gas, req.data))
+	if signed != nil {
+		result.TxHash = signed.Hash().Hex()
+	}
+	if err != nil {
+		return fail(err)
+	}
+	result.SubmittedAt = time.Now()
+	result.SubmitLatencyMs = durationMs(result.SubmittedAt.Sub(signedAt))
+	receipt, err := n.waitMined(ctx, signed.Hash())
+	if err != nil {
+		result.Status = "unconfirmed"
+		return fail(err)
+	}
+	result.BlockNumber = receipt.BlockNumber.Uint64()
+	result.GasUsed = receipt.GasUsed
+	if receipt.EffectiveGasPrice != nil {
+		result.EffectiveGasPrice = receipt.EffectiveGasPrice.String()
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return fail(fmt.Errorf("sweep reverted"))
+	}
+	result.Status = "success"
+	return result
+}
+
+// PrintSweep prints the failed transfers of a sweep and what it moved to w.
+func PrintSweep(w io.Writer, results []TransferResult) {
+	fmt.Fprintln(w, "\n========== SWEEP ==========")
+	tokens, wei := new(big.Int), new(big.Int)
+	success := 0
+	for _, r := range results {
+		if r.Status != "success" {
+			fmt.Fprintf(w, "%s %s: %s (%s)\n", r.From, r.TxType, r.Status, secrets.Redact(r.Error))
+			continue
+		}
+		success++
+		amount, _ := new(big.Int).SetString(r.Amount, 10)
+		if r.TxType == TxTypeNative {
+			wei.Add(wei, amount)
+		} else {
+			tokens.Add(tokens, amount)
+		}
+	}
+	fmt.Fprintf(w, "Total: %d | Success: %d | Failed: %d\n", len(results), success, len(results)-success)
+	fmt.Fprintf(w, "Swept: %s token units and %s wei\n", tokens, wei)
+}
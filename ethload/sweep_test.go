@@ -0,0 +1,194 @@
+package ethload
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestSweepWallet(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs a simulated chain")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	h, err := NewSimHarness(ctx, SimOptions{Senders: 2, Recipients: 1, BlockTime: 50 * time.Millisecond, Supply: big.NewInt(1000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	n, err := newNode(h.client, TestConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.connect(ctx, TestConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	treasury := common.HexToAddress("0x7000000000000000000000000000000000000007")
+	key, from, _ := loadPrivateKey(h.senderKeys[0])
+	before, err := n.snapshotBalances(ctx, h.token, []common.Address{from, treasury})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := n.sweepWallet(ctx, key, h.token, treasury)
+	if len(results) != 2 || results[0].TxType != TxTypeERC20 || results[1].TxType != TxTypeNative {
+		t.Fatalf("results %+v, want a token sweep then an ETH sweep", results)
+	}
+	for _, r := range results {
+		if r.Status != "success" || r.From != from.Hex() || r.To != treasury.Hex() || r.TxHash == "" {
+			t.Errorf("result %+v, want a mined sweep to the treasury", r)
+		}
+	}
+	if results[0].Amount != before[from].token.String() {
+		t.Errorf("swept %s tokens, want the whole %s", results[0].Amount, before[from].token)
+	}
+
+	after, err := n.snapshotBalances(ctx, h.token, []common.Address{from, treasury})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after[from].token.Sign() != 0 || after[treasury].token.Cmp(before[from].token) != 0 {
+		t.Errorf("tokens after the sweep: wallet %s, treasury %s; want 0 and %s", after[from].token, after[treasury].token, before[from].token)
+	}
+	swept, _ := new(big.Int).SetString(results[1].Amount, 10)
+	if after[treasury].eth.Cmp(swept) != 0 {
+		t.Errorf("treasury holds %s wei, want the swept %s", after[treasury].eth, swept)
+	}
+	// Both fees come out of the wallet; what is left is the unused part of
+	// the fee reserved for the ETH sweep.
+	var fees big.Int
+	for _, r := range results {
+		receipt, err := h.client.TransactionReceipt(ctx, common.HexToHash(r.TxHash))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fee, err := n.txFee(ctx, receipt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fees.Add(&fees, fee)
+	}
+	spent := new(big.Int).Add(swept, &fees)
+	if got := new(big.Int).Sub(before[from].eth, after[from].eth); got.Cmp(spent) != 0 {
+		t.Errorf("wallet spent %s wei, want %s swept plus %s in fees", got, swept, &fees)
+	}
+	if reserve := new(big.Int).Mul(big.NewInt(21000), big.NewInt(1e12)); after[from].eth.Cmp(reserve) > 0 {
+		t.Errorf("wallet kept %s wei, want no more than the fee headroom", after[from].eth)
+	}
+
+	// A second sweep finds no tokens and too little ETH to pay a fee.
+	if again := n.sweepWallet(ctx, key, h.token, treasury); len(again) != 0 {
+		t.Errorf("second sweep sent %+v, want nothing", again)
+	}
+
+	// Without a token only the ETH is swept.
+	key2, from2, _ := loadPrivateKey(h.senderKeys[1])
+	results = n.sweepWallet(ctx, key2, common.Address{}, treasury)
+	if len(results) != 1 || results[0].TxType != TxTypeNative || results[0].Status != "success" {
+		t.Errorf("ETH-only sweep %+v, want one native sweep", results)
+	}
+	if tok, err := n.tokenBalance(ctx, h.token, from2); err != nil || tok.Sign() == 0 {
+		t.Errorf("token balance %v (%v) after an ETH-only sweep, want it untouched", tok, err)
+	}
+}
+
+func TestSweepTxRejected(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs a simulated chain")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	h, err := NewSimHarness(ctx, SimOptions{Senders: 1, Recipients: 1, BlockTime: 50 * time.Millisecond, Supply: big.NewInt(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	n, err := newNode(h.client, TestConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.connect(ctx, TestConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	key, from, _ := loadPrivateKey(h.senderKeys[0])
+	treasury := common.HexToAddress("0x7000000000000000000000000000000000000007")
+	// More tokens than the wallet holds: the estimate reverts and nothing is
+	// sent.
+	data, err := erc20TransferData(treasury, big.NewInt(1e18+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := n.sweepTx(ctx, key, txRequest{txType: TxTypeERC20, recipient: treasury, to: h.token, value: new(big.Int), data: data, amount: big.NewInt(1e18 + 1)}, nil)
+	if r.Status != "failed" || !strings.Contains(r.Error, "gas estimation failed") || r.TxHash != "" {
+		t.Errorf("result %+v, want a failed estimate and no transaction", r)
+	}
+	if nonce, err := h.client.PendingNonceAt(ctx, from); err != nil || nonce != 0 {
+		t.Errorf("wallet nonce %d (%v) after a rejected sweep, want 0", nonce, err)
+	}
+}
+
+func TestSweepKeys(t *testing.T) {
+	k1, k2 := testKeyHex(), newKeyHex()
+	file := filepath.Join(t.TempDir(), "keys")
+	content := "# sweep these\n" + k1 + "\n\n  " + k2 + "  \n" + k1 + "\n"
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := sweepKeys(newResolver(), TestConfig{SenderKeys: []string{newKeyHex()}}, SweepOptions{KeysFile: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{k1, k2}
+	if len(keys) != len(want) {
+		t.Fatalf("%d keys, want %d: the file's, without comments or duplicates", len(keys), len(want))
+	}
+	for i, k := range keys {
+		if got := keyHex(k); got != strings.TrimPrefix(want[i], "0x") {
+			t.Errorf("key %d = %s, want %s", i, got, want[i])
+		}
+	}
+
+	// With no file or keystore the config's senders are swept.
+	keys, err = sweepKeys(newResolver(), TestConfig{SenderKeys: []string{k2}}, SweepOptions{})
+	if err != nil || len(keys) != 1 || keyHex(keys[0]) != strings.TrimPrefix(k2, "0x") {
+		t.Errorf("config senders: %d keys (%v), want k2", len(keys), err)
+	}
+
+	if _, err := sweepKeys(newResolver(), TestConfig{}, SweepOptions{KeysFile: file + ".missing"}); err == nil {
+		t.Error("missing keys file: accepted")
+	}
+}
+
+func keyHex(k *ecdsa.PrivateKey) string {
+	return common.Bytes2Hex(crypto.FromECDSA(k))
+}
+
+func TestPrintSweep(t *testing.T) {
+	results := []TransferResult{
+		{From: "0xA", TxType: TxTypeERC20, Status: "success", Amount: "1000"},
+		{From: "0xA", TxType: TxTypeNative, Status: "success", Amount: "5000"},
+		{From: "0xB", TxType: TxTypeERC20, Status: "success", Amount: "24"},
+		{From: "0xC", TxType: TxTypeERC20, Status: "failed", Amount: "7", Error: "sweep reverted"},
+		{From: "0xD", TxType: TxTypeNative, Status: "unconfirmed", Error: "tx not mined"},
+	}
+	var out bytes.Buffer
+	PrintSweep(&out, results)
+	want := "\n========== SWEEP ==========\n" +
+		"0xC erc20: failed (sweep reverted)\n" +
+		"0xD native: unconfirmed (tx not mined)\n" +
+		"Total: 5 | Success: 3 | Failed: 2\n" +
+		"Swept: 1024 token units and 5000 wei\n"
+	if out.String() != want {
+		t.Errorf("PrintSweep =\n%s\nwant\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,209 @@
+package ethload
+
+import (
+	"encoding/binary"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// The bundled test token is the contract of testtoken/TestToken.sol. Until
+// its solc artifact is checked in, the deployed code is assembled here to the
+// same storage layout and behaviour: every function rejects ETH, arithmetic
+// reverts on overflow and underflow, and only the deployer may mint. The
+// tests run every function through the testtoken bindings on a simulated
+// chain.
+//
+// Storage: keccak(addr . 0) balances, keccak(spender . keccak(owner . 1))
+// allowances, 2 totalSupply, 3 owner.
+
+const (
+	testTokenName     = "Load Test Token"
+	testTokenSymbol   = "LTT"
+	testTokenDecimals = 18
+)
+
+// approvalEventSig is topic 0 of Approval(address indexed owner, address indexed spender, uint256 value).
+// The token's Transfer events use transferEventSig.
+var approvalEventSig = crypto.Keccak256Hash([]byte("Approval(address,address,uint256)"))
+
+var addressMask = common.FromHex("0xffffffffffffffffffffffffffffffffffffffff")
+
+// assembler builds EVM bytecode with forward-referenced jump labels.
+type assembler struct {
+	code   []byte
+	labels map[string]int
+	fixups map[int]string // offset of a PUSH2 operand -> label it jumps to
+}
+
+func newAssembler() *assembler {
+	return &assembler{labels: make(map[string]int), fixups: make(map[int]string)}
+}
+
+func (a *assembler) op(ops ...vm.OpCode) *assembler {
+	for _, o := range ops {
+		a.code = append(a.code, byte(o))
+	}
+	return a
+}
+
+func (a *assembler) push(v []byte) *assembler {
+	a.code = append(a.code, byte(vm.PUSH1)+byte(len(v)-1))
+	a.code = append(a.code, v...)
+	return a
+}
+
+// pushN pushes n with the shortest PUSH.
+func (a *assembler) pushN(n uint64) *assembler {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], n)
+	i := 0
+	for i < 7 && b[i] == 0 {
+		i++
+	}
+	return a.push(b[i:])
+}
+
+func (a *assembler) pushLabel(name string) *assembler {
+	a.code = append(a.code, byte(vm.PUSH2))
+	a.fixups[len(a.code)] = name
+	a.code = append(a.code, 0, 0)
+	return a
+}
+
+func (a *assembler) label(name string) *assembler {
+	a.mark(name)
+	return a.op(vm.JUMPDEST)
+}
+
+// mark names the current offset without making it a jump destination.
+func (a *assembler) mark(name string) *assembler {
+	a.labels[name] = len(a.code)
+	return a
+}
+
+func (a *assembler) jump(name string) *assembler   { return a.pushLabel(name).op(vm.JUMP) }
+func (a *assembler) jumpIf(name string) *assembler { return a.pushLabel(name).op(vm.JUMPI) }
+
+// bytes resolves the jump labels and returns the code.
+func (a *assembler) bytes() []byte {
+	for pos, name := range a.fixups {
+		dst, ok := a.labels[name]
+		if !ok {
+			panic("assembler: missing label " + name)
+		}
+		binary.BigEndian.PutUint16(a.code[pos:], uint16(dst))
+	}
+	return a.code
+}
+
+// arg pushes calldata word i; argAddr pushes it masked to an address.
+func (a *assembler) arg(i uint64) *assembler { return a.pushN(4 + 32*i).op(vm.CALLDATALOAD) }
+func (a *assembler) argAddr(i uint64) *assembler {
+	return a.arg(i).push(addressMask).op(vm.AND)
+}
+
+// balanceSlot: [addr] -> [slot]
+func (a *assembler) balanceSlot() *assembler {
+	return a.pushN(0).op(vm.MSTORE).pushN(0).pushN(0x20).op(vm.MSTORE).pushN(0x40).pushN(0).op(vm.KECCAK256)
+}
+
+// allowanceSlot: [spender, owner] -> [slot]
+func (a *assembler) allowanceSlot() *assembler {
+	a.pushN(0).op(vm.MSTORE).pushN(1).pushN(0x20).op(vm.MSTORE).pushN(0x40).pushN(0).op(vm.KECCAK256)
+	return a.pushN(0x20).op(vm.MSTORE).pushN(0).op(vm.MSTORE).pushN(0x40).pushN(0).op(vm.KECCAK256)
+}
+
+// addChecked: [x, y] -> [x+y], reverting on overflow like Solidity's
+// checked arithmetic.
+func (a *assembler) addChecked() *assembler {
+	return a.op(vm.DUP2, vm.ADD, vm.DUP1, vm.SWAP2, vm.GT).jumpIf("revert")
+}
+
+// ret32 returns the top of the stack as one word.
+func (a *assembler) ret32() *assembler {
+	return a.pushN(0).op(vm.MSTORE).pushN(0x20).pushN(0).op(vm.RETURN)
+}
+
+// retString returns s (at most 32 bytes) ABI-encoded as a string.
+func (a *assembler) retString(s string) *assembler {
+	var word [32]byte
+	copy(word[:], s)
+	a.pushN(0x20).pushN(0).op(vm.MSTORE)
+	a.pushN(uint64(len(s))).pushN(0x20).op(vm.MSTORE)
+	a.push(word[:]).pushN(0x40).op(vm.MSTORE)
+	return a.pushN(0x60).pushN(0).op(vm.RETURN)
+}
+
+func testTokenRuntime(name, symbol string, decimals uint8) []byte {
+	a := newAssembler()
+	// No function is payable.
+	a.op(vm.CALLVALUE).jumpIf("revert")
+	a.pushN(0).op(vm.CALLDATALOAD).pushN(0xe0).op(vm.SHR)
+	for _, f := range []struct{ sig, label string }{
+		{"name()", "name"}, {"symbol()", "symbol"}, {"decimals()", "decimals"},
+		{"totalSupply()", "totalSupply"}, {"owner()", "owner"},
+		{"balanceOf(address)", "balanceOf"}, {"allowance(address,address)", "allowance"},
+		{"transfer(address,uint256)", "transfer"}, {"approve(address,uint256)", "approve"},
+		{"transferFrom(address,address,uint256)", "transferFrom"}, {"mint(address,uint256)", "mint"},
+	} {
+		a.op(vm.DUP1).push(crypto.Keccak256([]byte(f.sig))[:4]).op(vm.EQ).jumpIf(f.label)
+	}
+	a.label("revert").pushN(0).pushN(0).op(vm.REVERT)
+
+	a.label("name").retString(name)
+	a.label("symbol").retString(symbol)
+	a.label("decimals").pushN(uint64(decimals)).ret32()
+	a.label("totalSupply").pushN(2).op(vm.SLOAD).ret32()
+	a.label("owner").pushN(3).op(vm.SLOAD).ret32()
+	a.label("balanceOf").argAddr(0).balanceSlot().op(vm.SLOAD).ret32()
+	a.label("allowance").argAddr(1).argAddr(0).allowanceSlot().op(vm.SLOAD).ret32()
+
+	a.label("transfer").op(vm.CALLER).argAddr(0).arg(1).jump("move")
+
+	a.label("approve").arg(1).argAddr(0).op(vm.CALLER)
+	a.op(vm.DUP2, vm.DUP2).allowanceSlot().op(vm.DUP4, vm.SWAP1, vm.SSTORE)
+	a.op(vm.DUP3).pushN(0).op(vm.MSTORE)
+	a.push(approvalEventSig.Bytes()).pushN(0x20).pushN(0).op(vm.LOG3)
+	a.pushN(1).ret32()
+
+	// Spends the caller's allowance, reverting if it is short.
+	a.label("transferFrom").argAddr(0).argAddr(1).arg(2)
+	a.op(vm.CALLER, vm.DUP4).allowanceSlot()
+	a.op(vm.DUP1, vm.SLOAD, vm.DUP1, vm.DUP4, vm.GT).jumpIf("revert")
+	a.op(vm.DUP3, vm.SWAP1, vm.SUB, vm.SWAP1, vm.SSTORE).jump("move")
+
+	a.label("mint").pushN(3).op(vm.SLOAD, vm.CALLER, vm.EQ, vm.ISZERO).jumpIf("revert")
+	a.argAddr(0).arg(1)
+	a.op(vm.DUP1).pushN(2).op(vm.SLOAD).addChecked().pushN(2).op(vm.SSTORE)
+	a.op(vm.DUP2).balanceSlot().op(vm.DUP1, vm.SLOAD, vm.DUP3).addChecked().op(vm.SWAP1, vm.SSTORE)
+	a.pushN(0).op(vm.MSTORE).pushN(0)
+	a.push(transferEventSig.Bytes()).pushN(0x20).pushN(0).op(vm.LOG3)
+	a.pushN(1).ret32()
+
+	// move: [from, to, amount] -> debit from, credit to, emit Transfer, return true
+	a.label("move")
+	a.op(vm.DUP3).balanceSlot().op(vm.DUP1, vm.SLOAD)
+	a.op(vm.DUP1, vm.DUP4, vm.GT).jumpIf("revert")
+	a.op(vm.DUP3, vm.SWAP1, vm.SUB, vm.SWAP1, vm.SSTORE)
+	a.op(vm.DUP2).balanceSlot().op(vm.DUP1, vm.SLOAD, vm.DUP3).addChecked().op(vm.SWAP1, vm.SSTORE)
+	a.pushN(0).op(vm.MSTORE, vm.SWAP1)
+	a.push(transferEventSig.Bytes()).pushN(0x20).pushN(0).op(vm.LOG3)
+	a.pushN(1).ret32()
+	return a.bytes()
+}
+
+// testTokenCode is the creation code of the test token: it rejects ETH,
+// records the deployer as owner and returns the runtime code that follows it.
+func testTokenCode() []byte {
+	runtime := testTokenRuntime(testTokenName, testTokenSymbol, testTokenDecimals)
+	a := newAssembler()
+	a.op(vm.CALLVALUE).jumpIf("revert")
+	a.op(vm.CALLER).pushN(3).op(vm.SSTORE)
+	// CODECOPY(0, runtime, len(runtime)); RETURN(0, len(runtime))
+	a.pushN(uint64(len(runtime))).op(vm.DUP1).pushLabel("runtime").pushN(0).op(vm.CODECOPY).pushN(0).op(vm.RETURN)
+	a.label("revert").pushN(0).pushN(0).op(vm.REVERT)
+	a.mark("runtime")
+	return append(a.bytes(), runtime...)
+}
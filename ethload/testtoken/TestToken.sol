@@ -0,0 +1,55 @@
+// SPDX-License-Identifier: MIT
+pragma solidity ^0.8.20;
+
+/// @title Load Test Token
+/// @notice A minimal mintable ERC20 for load tests: only the deployer mints.
+/// @dev The storage layout is part of the interface: ethload/testtoken.go
+/// assembles its deployed code against these slots.
+contract TestToken {
+    mapping(address => uint256) public balanceOf; // slot 0
+    mapping(address => mapping(address => uint256)) public allowance; // slot 1
+    uint256 public totalSupply; // slot 2
+    address public owner; // slot 3
+
+    string public constant name = "Load Test Token";
+    string public constant symbol = "LTT";
+    uint8 public constant decimals = 18;
+
+    event Transfer(address indexed from, address indexed to, uint256 value);
+    event Approval(address indexed owner, address indexed spender, uint256 value);
+
+    constructor() {
+        owner = msg.sender;
+    }
+
+    function transfer(address to, uint256 value) external returns (bool) {
+        _move(msg.sender, to, value);
+        return true;
+    }
+
+    function approve(address spender, uint256 value) external returns (bool) {
+        allowance[msg.sender][spender] = value;
+        emit Approval(msg.sender, spender, value);
+        return true;
+    }
+
+    function transferFrom(address from, address to, uint256 value) external returns (bool) {
+        allowance[from][msg.sender] -= value;
+        _move(from, to, value);
+        return true;
+    }
+
+    function mint(address to, uint256 amount) external returns (bool) {
+        require(msg.sender == owner);
+        totalSupply += amount;
+        balanceOf[to] += amount;
+        emit Transfer(address(0), to, amount);
+        return true;
+    }
+
+    function _move(address from, address to, uint256 value) private {
+        balanceOf[from] -= value;
+        balanceOf[to] += value;
+        emit Transfer(from, to, value);
+    }
+}
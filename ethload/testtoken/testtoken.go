@@ -0,0 +1,12 @@
+// Package testtoken holds the ERC20 the bootstrap and simulate commands
+// deploy: its Solidity source, ABI and abigen bindings.
+//
+// TestToken.sol is the reference for the deployed code. The compiled
+// artifact is not checked in yet, so ethload assembles bytecode to the same
+// storage layout and checks (see ethload/testtoken.go) and its tests run the
+// bindings against it. With solc on PATH, go generate writes TestToken.bin
+// and regenerates the bindings with a deploy function.
+package testtoken
+
+//go:generate solc --overwrite --abi --bin -o . TestToken.sol
+//go:generate abigen --abi TestToken.abi --bin TestToken.bin --pkg testtoken --type TestToken --out bindings.go
@@ -0,0 +1,384 @@
+package ethload
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+
+	"tron_load/ethload/testtoken"
+)
+
+// tokenChain is a simulated chain with the test token deployed by owner.
+// Blocks are committed after every transaction.
+type tokenChain struct {
+	t      *testing.T
+	sim    *simulated.Backend
+	abi    *abi.ABI
+	token  common.Address
+	tok    *testtoken.TestToken
+	keys   map[common.Address]*ecdsa.PrivateKey
+	owner  common.Address
+	others []common.Address
+}
+
+func newTokenChain(t *testing.T) *tokenChain {
+	t.Helper()
+	parsed, err := testtoken.TestTokenMetaData.GetAbi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &tokenChain{t: t, abi: parsed, keys: make(map[common.Address]*ecdsa.PrivateKey)}
+	alloc := types.GenesisAlloc{}
+	for i := 0; i < 4; i++ {
+		key, addr, _ := loadPrivateKey(newKeyHex())
+		c.keys[addr] = key
+		alloc[addr] = types.Account{Balance: simulatedFunding}
+		if i == 0 {
+			c.owner = addr
+		} else {
+			c.others = append(c.others, addr)
+		}
+	}
+	c.sim = simulated.NewBackend(alloc)
+	t.Cleanup(func() { c.sim.Close() })
+
+	receipt := c.send(c.owner, nil, testTokenCode())
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatal("deploy reverted")
+	}
+	c.token = receipt.ContractAddress
+	if c.tok, err = testtoken.NewTestToken(c.token, c.sim.Client()); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// send signs data from addr to to (a contract creation when nil), commits a
+// block and returns the receipt.
+func (c *tokenChain) send(from common.Address, to *common.Address, data []byte) *types.Receipt {
+	c.t.Helper()
+	return c.sendValue(from, to, new(big.Int), data)
+}
+
+// sendValue is send with value wei attached.
+func (c *tokenChain) sendValue(from common.Address, to *common.Address, value *big.Int, data []byte) *types.Receipt {
+	c.t.Helper()
+	ctx := context.Background()
+	client := c.sim.Client()
+	nonce, err := client.PendingNonceAt(ctx, from)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	gasPrice, err := client.SuggestGasPrice(ctx)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	tx := types.NewTx(&types.LegacyTx{Nonce: nonce, To: to, Value: value, Gas: 1_000_000, GasPrice: gasPrice.Mul(gasPrice, big.NewInt(2)), Data: data})
+	signed, err := types.SignTx(tx, types.LatestSignerForChainID(chainID), c.keys[from])
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if err := client.SendTransaction(ctx, signed); err != nil {
+		c.t.Fatal(err)
+	}
+	c.sim.Commit()
+	receipt, err := client.TransactionReceipt(ctx, signed.Hash())
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return receipt
+}
+
+// exec calls method on the token from addr in a transaction.
+func (c *tokenChain) exec(from common.Address, method string, args ...any) *types.Receipt {
+	c.t.Helper()
+	data, err := c.abi.Pack(method, args...)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return c.send(from, &c.token, data)
+}
+
+func (c *tokenChain) balance(addr common.Address) int64 {
+	c.t.Helper()
+	b, err := c.tok.BalanceOf(nil, addr)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return b.Int64()
+}
+
+func (c *tokenChain) allowance(owner, spender common.Address) int64 {
+	c.t.Helper()
+	a, err := c.tok.Allowance(nil, owner, spender)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return a.Int64()
+}
+
+func (c *tokenChain) totalSupply() *big.Int {
+	c.t.Helper()
+	s, err := c.tok.TotalSupply(nil)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return s
+}
+
+// wantEvent checks that receipt succeeded with exactly one log: the token's
+// event with the given indexed addresses and value.
+func (c *tokenChain) wantEvent(receipt *types.Receipt, event string, a, b common.Address, value int64) {
+	c.t.Helper()
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		c.t.Fatalf("%s tx reverted", event)
+	}
+	if len(receipt.Logs) != 1 {
+		c.t.Fatalf("%s tx emitted %d logs, want 1", event, len(receipt.Logs))
+	}
+	l := receipt.Logs[0]
+	want := []common.Hash{c.abi.Events[event].ID, common.BytesToHash(a.Bytes()), common.BytesToHash(b.Bytes())}
+	if l.Address != c.token || len(l.Topics) != 3 || l.Topics[0] != want[0] || l.Topics[1] != want[1] || l.Topics[2] != want[2] {
+		c.t.Errorf("%s log from %s with topics %v, want %v from the token", event, l.Address.Hex(), l.Topics, want)
+	}
+	if got := new(big.Int).SetBytes(l.Data); len(l.Data) != 32 || got.Int64() != value {
+		c.t.Errorf("%s log value = %s, want %d", event, got, value)
+	}
+}
+
+// wantReverted checks that receipt failed without emitting anything.
+func (c *tokenChain) wantReverted(receipt *types.Receipt, what string) {
+	c.t.Helper()
+	if receipt.Status != types.ReceiptStatusFailed || len(receipt.Logs) != 0 {
+		c.t.Errorf("%s: status %d with %d logs, want a revert", what, receipt.Status, len(receipt.Logs))
+	}
+}
+
+func TestTestTokenEventSigs(t *testing.T) {
+	parsed, err := testtoken.TestTokenMetaData.GetAbi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Events["Transfer"].ID != transferEventSig {
+		t.Error("transferEventSig is not the Transfer event's topic")
+	}
+	if parsed.Events["Approval"].ID != approvalEventSig {
+		t.Error("approvalEventSig is not the Approval event's topic")
+	}
+}
+
+func TestTestTokenMetadata(t *testing.T) {
+	c := newTokenChain(t)
+	name, err := c.tok.Name(nil)
+	if err != nil || name != testTokenName {
+		t.Errorf("name = %q, %v; want %s", name, err, testTokenName)
+	}
+	symbol, err := c.tok.Symbol(nil)
+	if err != nil || symbol != testTokenSymbol {
+		t.Errorf("symbol = %q, %v; want %s", symbol, err, testTokenSymbol)
+	}
+	decimals, err := c.tok.Decimals(nil)
+	if err != nil || decimals != testTokenDecimals {
+		t.Errorf("decimals = %d, %v; want %d", decimals, err, testTokenDecimals)
+	}
+	owner, err := c.tok.Owner(nil)
+	if err != nil || owner != c.owner {
+		t.Errorf("owner = %s, %v; want the deployer %s", owner.Hex(), err, c.owner.Hex())
+	}
+	if got := c.totalSupply(); got.Sign() != 0 {
+		t.Errorf("totalSupply = %s before any mint, want 0", got)
+	}
+	data := crypto.Keccak256([]byte("burn(uint256)"))[:4]
+	c.wantReverted(c.send(c.owner, &c.token, append(data, make([]byte, 32)...)), "unknown function")
+}
+
+// TestTestTokenMatchesSource checks the assembled token's constants against
+// the Solidity source it stands in for.
+func TestTestTokenMatchesSource(t *testing.T) {
+	src, err := os.ReadFile("testtoken/TestToken.sol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, decl := range []string{
+		fmt.Sprintf("string public constant name = %q;", testTokenName),
+		fmt.Sprintf("string public constant symbol = %q;", testTokenSymbol),
+		fmt.Sprintf("uint8 public constant decimals = %d;", testTokenDecimals),
+	} {
+		if !strings.Contains(string(src), decl) {
+			t.Errorf("TestToken.sol does not declare %s", decl)
+		}
+	}
+}
+
+func TestTestTokenRejectsETH(t *testing.T) {
+	c := newTokenChain(t)
+	alice := c.others[0]
+	c.exec(c.owner, "mint", alice, big.NewInt(100))
+
+	deploy := c.sendValue(c.owner, nil, big.NewInt(1), testTokenCode())
+	if deploy.Status != types.ReceiptStatusFailed {
+		t.Error("deploy with ETH attached succeeded")
+	}
+	for _, call := range []struct {
+		method string
+		args   []any
+	}{
+		{"transfer", []any{c.others[1], big.NewInt(1)}},
+		{"approve", []any{c.others[1], big.NewInt(1)}},
+		{"balanceOf", []any{alice}},
+	} {
+		data, err := c.abi.Pack(call.method, call.args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.wantReverted(c.sendValue(alice, &c.token, big.NewInt(1), data), call.method+" with ETH attached")
+	}
+	if got := c.balance(alice); got != 100 {
+		t.Errorf("alice's balance = %d, want 100", got)
+	}
+}
+
+func TestTestTokenMint(t *testing.T) {
+	c := newTokenChain(t)
+	alice, bob := c.others[0], c.others[1]
+
+	c.wantEvent(c.exec(c.owner, "mint", alice, big.NewInt(100)), "Transfer", common.Address{}, alice, 100)
+	c.wantEvent(c.exec(c.owner, "mint", bob, big.NewInt(50)), "Transfer", common.Address{}, bob, 50)
+	if got := c.balance(alice); got != 100 {
+		t.Errorf("alice's balance = %d, want 100", got)
+	}
+	if got := c.totalSupply().Int64(); got != 150 {
+		t.Errorf("totalSupply = %d, want 150", got)
+	}
+
+	c.wantReverted(c.exec(alice, "mint", alice, big.NewInt(1)), "mint by a non-owner")
+	if got := c.balance(alice); got != 100 {
+		t.Errorf("alice's balance = %d after her mint, want 100", got)
+	}
+	if got := c.totalSupply().Int64(); got != 150 {
+		t.Errorf("totalSupply = %d after a rejected mint, want 150", got)
+	}
+
+	// The supply cannot wrap past 2^256-1.
+	rest := new(big.Int).Sub(abi.MaxUint256, big.NewInt(150))
+	if receipt := c.exec(c.owner, "mint", bob, rest); receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatal("mint up to the maximum supply reverted")
+	}
+	c.wantReverted(c.exec(c.owner, "mint", alice, big.NewInt(1)), "mint past the maximum supply")
+	if got := c.totalSupply(); got.Cmp(abi.MaxUint256) != 0 {
+		t.Errorf("totalSupply = %s after an overflowing mint, want 2^256-1", got)
+	}
+	if got := c.balance(alice); got != 100 {
+		t.Errorf("alice's balance = %d after an overflowing mint, want 100", got)
+	}
+}
+
+func TestTestTokenTransfer(t *testing.T) {
+	c := newTokenChain(t)
+	alice, bob := c.others[0], c.others[1]
+	c.exec(c.owner, "mint", alice, big.NewInt(100))
+
+	tests := []struct {
+		name             string
+		from, to         common.Address
+		value            int64
+		wantOK           bool
+		wantFrom, wantTo int64 // balances afterwards
+	}{
+		{"part of the balance", alice, bob, 30, true, 70, 30},
+		{"more than the balance", alice, bob, 71, false, 70, 30},
+		{"the whole balance", alice, bob, 70, true, 0, 100},
+		{"from an empty balance", alice, bob, 1, false, 0, 100},
+		{"zero", alice, bob, 0, true, 0, 100},
+		{"to self", bob, bob, 40, true, 100, 100},
+	}
+	for _, tt := range tests {
+		receipt := c.exec(tt.from, "transfer", tt.to, big.NewInt(tt.value))
+		if tt.wantOK {
+			c.wantEvent(receipt, "Transfer", tt.from, tt.to, tt.value)
+		} else {
+			c.wantReverted(receipt, tt.name)
+		}
+		if got := c.balance(tt.from); got != tt.wantFrom {
+			t.Errorf("%s: sender balance = %d, want %d", tt.name, got, tt.wantFrom)
+		}
+		if got := c.balance(tt.to); got != tt.wantTo {
+			t.Errorf("%s: recipient balance = %d, want %d", tt.name, got, tt.wantTo)
+		}
+	}
+	if got := c.totalSupply().Int64(); got != 100 {
+		t.Errorf("totalSupply = %d after transfers, want 100", got)
+	}
+}
+
+func TestTestTokenApproveAndTransferFrom(t *testing.T) {
+	c := newTokenChain(t)
+	alice, bob, carol := c.others[0], c.others[1], c.others[2]
+	c.exec(c.owner, "mint", alice, big.NewInt(100))
+
+	c.wantEvent(c.exec(alice, "approve", bob, big.NewInt(500)), "Approval", alice, bob, 500)
+	c.wantEvent(c.exec(alice, "approve", bob, big.NewInt(60)), "Approval", alice, bob, 60)
+	if got := c.allowance(alice, bob); got != 60 {
+		t.Errorf("allowance = %d, want the latest approval 60", got)
+	}
+	if got := c.allowance(bob, alice); got != 0 {
+		t.Errorf("reverse allowance = %d, want 0", got)
+	}
+
+	tests := []struct {
+		name              string
+		spender, from, to common.Address
+		value             int64
+		wantOK            bool
+		wantAllowance     int64
+		wantFrom, wantTo  int64
+	}{
+		{"within the allowance", bob, alice, carol, 25, true, 35, 75, 25},
+		{"over the allowance", bob, alice, carol, 36, false, 35, 75, 25},
+		{"without an allowance", carol, alice, carol, 1, false, 35, 75, 25},
+		{"the rest of the allowance", bob, alice, carol, 35, true, 0, 40, 60},
+		{"from an exhausted allowance", bob, alice, carol, 1, false, 0, 40, 60},
+	}
+	for _, tt := range tests {
+		receipt := c.exec(tt.spender, "transferFrom", tt.from, tt.to, big.NewInt(tt.value))
+		if tt.wantOK {
+			c.wantEvent(receipt, "Transfer", tt.from, tt.to, tt.value)
+		} else {
+			c.wantReverted(receipt, tt.name)
+		}
+		if got := c.allowance(tt.from, bob); got != tt.wantAllowance {
+			t.Errorf("%s: allowance = %d, want %d", tt.name, got, tt.wantAllowance)
+		}
+		if got := c.balance(tt.from); got != tt.wantFrom {
+			t.Errorf("%s: owner balance = %d, want %d", tt.name, got, tt.wantFrom)
+		}
+		if got := c.balance(tt.to); got != tt.wantTo {
+			t.Errorf("%s: recipient balance = %d, want %d", tt.name, got, tt.wantTo)
+		}
+	}
+
+	// An allowance above the balance still cannot overdraw it, and the
+	// failed transfer leaves the allowance untouched.
+	c.exec(alice, "approve", bob, big.NewInt(1000))
+	c.wantReverted(c.exec(bob, "transferFrom", alice, carol, big.NewInt(41)), "over the balance")
+	if got := c.allowance(alice, bob); got != 1000 {
+		t.Errorf("allowance = %d after an overdraw, want 1000", got)
+	}
+	if got := c.balance(alice); got != 40 {
+		t.Errorf("owner balance = %d after an overdraw, want 40", got)
+	}
+}
@@ -0,0 +1,260 @@
+package ethload
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Transaction types
+const (
+	TxTypeERC20        = "erc20"         // ERC20 transfer(recipient, amount)
+	TxTypeNative       = "native"        // amount wei sent to the recipient
+	TxTypeContractCall = "contract-call" // the configured call
+	TxTypeERC721       = "erc721"        // safeTransferFrom of a token ID the sender owns
+	TxTypeERC1155      = "erc1155"       // safeTransferFrom of an ID and amount
+	TxTypeMixed        = "mixed"         // a weighted mix of the above
+)
+
+// txTypes are the types a transfer can send, in the order they are reported.
+var txTypes = []string{TxTypeERC20, TxTypeNative, TxTypeContractCall, TxTypeERC721, TxTypeERC1155}
+
+func isTxType(t string) bool {
+	for _, v := range txTypes {
+		if t == v {
+			return true
+		}
+	}
+	return false
+}
+
+// nativeDecimals scales native amounts: whole ETH to wei.
+const nativeDecimals = 18
+
+// TxWeight is the share of one transaction type in a mixed workload.
+type TxWeight struct {
+	Type   string  `json:"type"`
+	Weight float64 `json:"weight"`
+}
+
+// txRequest is the transaction one transfer sends.
+type txRequest struct {
+	txType    string
+	recipient common.Address // reported as To
+	to        common.Address // tx destination: the recipient, the token or the called contract
+	value     *big.Int       // wei
+	data      []byte
+	amount    *big.Int // reported as Amount: token base units, or wei for native; nil for calls without {{amount}} and erc721
+	tokenID   *big.Int // erc721 and erc1155
+}
+
+// workload builds the transaction of every transfer from txType, mix and call.
+// It is safe for concurrent use.
+type workload struct {
+	token     common.Address
+	types     []string
+	cumWeight []float64
+	amounts   map[string]*amountPicker
+	call      *contractCall
+	erc721    common.Address
+	pool      *tokenPool
+	erc1155   *erc1155Collection
+
+	mu       sync.Mutex
+	rng      *rand.Rand
+	sequence uint64
+}
+
+func newWorkload(ctx context.Context, n *node, config TestConfig, decimals int) (*workload, error) {
+	w := &workload{
+		token:   tokenAddress(config),
+		amounts: make(map[string]*amountPicker),
+		rng:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	txType := strings.ToLower(strings.TrimSpace(config.TxType))
+	switch txType {
+	case "":
+		txType = TxTypeERC20
+		fallthrough
+	case TxTypeERC20, TxTypeNative, TxTypeContractCall, TxTypeERC721, TxTypeERC1155:
+		w.types, w.cumWeight = []string{txType}, []float64{1}
+	case TxTypeMixed:
+		if len(config.Mix) == 0 {
+			return nil, fmt.Errorf("txType mixed needs mix weights")
+		}
+		total := 0.0
+		for i, m := range config.Mix {
+			t := strings.ToLower(strings.TrimSpace(m.Type))
+			if !isTxType(t) {
+				return nil, fmt.Errorf("mix[%d]: invalid type %q (want one of %s)", i, m.Type, strings.Join(txTypes, ", "))
+			}
+			if m.Weight <= 0 {
+				return nil, fmt.Errorf("mix[%d]: weight must be positive", i)
+			}
+			total += m.Weight
+			w.types = append(w.types, t)
+			w.cumWeight = append(w.cumWeight, total)
+		}
+	default:
+		return nil, fmt.Errorf("invalid txType %q (want one of %s or %s)", config.TxType, strings.Join(txTypes, ", "), TxTypeMixed)
+	}
+
+	for _, t := range w.types {
+		if _, ok := w.amounts[t]; ok || (t == TxTypeContractCall && w.call != nil) || (t == TxTypeERC721 && w.pool != nil) || (t == TxTypeERC1155 && w.erc1155 != nil) {
+			continue
+		}
+		var err error
+		switch t {
+		case TxTypeERC20:
+			if w.token == (common.Address{}) {
+				return nil, fmt.Errorf("erc20 transfers need erc20Contract")
+			}
+			w.amounts[t], err = newAmountPicker(config, decimals)
+		case TxTypeNative:
+			switch {
+			case config.NativeAmounts != nil:
+				w.amounts[t], err = newAmountPicker(TestConfig{Amounts: config.NativeAmounts}, nativeDecimals)
+			case len(w.types) > 1:
+				// The token amounts are sized for tokens, not ETH.
+				err = fmt.Errorf("mixed transfers need nativeAmounts")
+			default:
+				// Amounts are whole ETH here; a base-unit amount is wei.
+				w.amounts[t], err = newAmountPicker(config, nativeDecimals)
+			}
+		case TxTypeContractCall:
+			if w.call, err = loadContractCall(config.Call, w.token); err != nil {
+				return nil, err
+			}
+			if w.call.usesAmount() {
+				w.amounts[t], err = newAmountPicker(config, decimals)
+			}
+		case TxTypeERC721:
+			if config.ERC721 == nil || !common.IsHexAddress(config.ERC721.Contract) {
+				return nil, fmt.Errorf("erc721.contract is not a valid address")
+			}
+			w.erc721 = common.HexToAddress(config.ERC721.Contract)
+			var senders []common.Address
+			for i, k := range config.SenderKeys {
+				_, addr, err := loadPrivateKey(k)
+				if err != nil {
+					return nil, fmt.Errorf("sender key %d: %w", i+1, err)
+				}
+				senders = append(senders, addr)
+			}
+			w.pool, err = n.loadTokenPool(ctx, config.ERC721, w.erc721, senders)
+		case TxTypeERC1155:
+			w.erc1155, err = loadERC1155(config.ERC1155)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", t, err)
+		}
+	}
+	return w, nil
+}
+
+// next builds the transaction of the next transfer of pair p.
+func (w *workload) next(p transferPair) (txRequest, error) {
+	t := w.pickType()
+	recipient := p.Recipient
+	req := txRequest{txType: t, recipient: recipient, value: new(big.Int)}
+	if p := w.amounts[t]; p != nil {
+		req.amount = p.next()
+	}
+	switch t {
+	case TxTypeERC20:
+		data, err := erc20TransferData(recipient, req.amount)
+		if err != nil {
+			return req, err
+		}
+		req.to, req.data = w.token, data
+	case TxTypeNative:
+		req.to, req.value = recipient, req.amount
+	case TxTypeContractCall:
+		w.mu.Lock()
+		seq := w.sequence
+		w.sequence++
+		w.mu.Unlock()
+		data, err := w.call.pack(callArgs{recipient: recipient, amount: req.amount, sequence: seq, random: w.random})
+		if err != nil {
+			return req, err
+		}
+		req.to, req.value, req.data = w.call.contract, new(big.Int).Set(w.call.value), data
+	case TxTypeERC721:
+		id, err := w.pool.take(p.Sender)
+		if err != nil {
+			return req, err
+		}
+		data, err := parsedERC721.Pack("safeTransferFrom", p.Sender, recipient, id)
+		if err != nil {
+			w.pool.release(p.Sender, id)
+			return req, err
+		}
+		req.to, req.tokenID, req.data = w.erc721, id, data
+	case TxTypeERC1155:
+		w.mu.Lock()
+		id := w.erc1155.ids[w.rng.Intn(len(w.erc1155.ids))]
+		w.mu.Unlock()
+		data, err := parsedERC1155.Pack("safeTransferFrom", p.Sender, recipient, id, w.erc1155.amount, []byte{})
+		if err != nil {
+			return req, err
+		}
+		req.to, req.tokenID, req.amount, req.data = w.erc1155.contract, id, w.erc1155.amount, data
+	}
+	return req, nil
+}
+
+// done returns the token ID of an erc721 transfer that was never broadcast
+// to its sender's pool, unless the sender turned out not to own it.
+func (w *workload) done(p transferPair, req txRequest, r TransferResult) {
+	if req.txType == TxTypeERC721 && req.tokenID != nil && r.TxHash == "" && !strings.Contains(r.Error, notOwnedBySender) {
+		w.pool.release(p.Sender, req.tokenID)
+	}
+}
+
+func (w *workload) pickType() string {
+	if len(w.types) == 1 {
+		return w.types[0]
+	}
+	w.mu.Lock()
+	r := w.rng.Float64() * w.cumWeight[len(w.cumWeight)-1]
+	w.mu.Unlock()
+	for i, c := range w.cumWeight {
+		if r < c {
+			return w.types[i]
+		}
+	}
+	return w.types[len(w.types)-1]
+}
+
+// random draws a random value for a {{random}} argument of type t.
+func (w *workload) random(t abi.Type) string {
+	b := make([]byte, 32)
+	w.mu.Lock()
+	w.rng.Read(b)
+	w.mu.Unlock()
+	return randomArg(t, b)
+}
+
+// usesTxType reports whether config sends transactions of type t.
+func usesTxType(config TestConfig, t string) bool {
+	txType := strings.ToLower(strings.TrimSpace(config.TxType))
+	if txType == "" {
+		txType = TxTypeERC20
+	}
+	if txType != TxTypeMixed {
+		return txType == t
+	}
+	for _, m := range config.Mix {
+		if strings.ToLower(strings.TrimSpace(m.Type)) == t {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+package ethload
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// transferEventSig is topic 0 of Transfer(address indexed from, address indexed to, uint256 value).
+var transferEventSig = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+// transferEvent is a decoded ERC20 Transfer log.
+type transferEvent struct {
+	from, to common.Address
+	value    *big.Int
+}
+
+// transferEvents decodes the ERC20 Transfer logs token emitted in receipt.
+func transferEvents(receipt *types.Receipt, token common.Address) []transferEvent {
+	var events []transferEvent
+	for _, l := range receipt.Logs {
+		// ERC721 Transfer shares the signature but indexes the token ID instead
+		// of carrying a value in data.
+		if l.Address != token || len(l.Topics) != 3 || l.Topics[0] != transferEventSig || len(l.Data) != 32 {
+			continue
+		}
+		events = append(events, transferEvent{
+			from:  common.BytesToAddress(l.Topics[1].Bytes()),
+			to:    common.BytesToAddress(l.Topics[2].Bytes()),
+			value: new(big.Int).SetBytes(l.Data),
+		})
+	}
+	return events
+}
+
+// verifyTransferEvent checks that the Transfer logs token emitted in receipt
+// credit to with exactly amount from from. Tokens that emit no event, take a
+// fee on transfer, or move a different amount fail the check.
+func verifyTransferEvent(receipt *types.Receipt, token, from, to common.Address, amount *big.Int) error {
+	events := transferEvents(receipt, token)
+	if len(events) == 0 {
+		return fmt.Errorf("transfer event mismatch: %s emitted no Transfer event", token.Hex())
+	}
+	credited := new(big.Int)
+	var others []string
+	for _, e := range events {
+		if e.from == from && e.to == to {
+			credited.Add(credited, e.value)
+			continue
+		}
+		others = append(others, fmt.Sprintf("%s->%s %s", e.from.Hex(), e.to.Hex(), e.value))
+	}
+	if credited.Cmp(amount) == 0 {
+		return nil
+	}
+	msg := fmt.Sprintf("transfer event mismatch: expected %s->%s %s, events credit %s", from.Hex(), to.Hex(), amount, credited)
+	if len(others) > 0 {
+		msg += " (other transfers: " + strings.Join(others, ", ") + ")"
+	}
+	return errors.New(msg)
+}
+
+// transferSingleSig is topic 0 of the ERC1155
+// TransferSingle(address indexed operator, address indexed from, address indexed to, uint256 id, uint256 value).
+var transferSingleSig = crypto.Keccak256Hash([]byte("TransferSingle(address,address,address,uint256,uint256)"))
+
+// transferBatchSig is topic 0 of the ERC1155
+// TransferBatch(address indexed operator, address indexed from, address indexed to, uint256[] ids, uint256[] values).
+var transferBatchSig = crypto.Keccak256Hash([]byte("TransferBatch(address,address,address,uint256[],uint256[])"))
+
+// transferBatchData decodes the ids and values of a TransferBatch log.
+var transferBatchData = abi.Arguments{{Type: mustNewType("uint256[]")}, {Type: mustNewType("uint256[]")}}
+
+func mustNewType(t string) abi.Type {
+	typ, err := abi.NewType(t, "", nil)
+	if err != nil {
+		panic(err)
+	}
+	return typ
+}
+
+// erc1155Move is one token ID moved by a TransferSingle or TransferBatch log.
+type erc1155Move struct {
+	from, to  common.Address
+	id, value *big.Int
+}
+
+// erc1155Moves decodes the single and batch transfers of contract's
+// TransferSingle and TransferBatch logs in receipt.
+func erc1155Moves(receipt *types.Receipt, contract common.Address) []erc1155Move {
+	var moves []erc1155Move
+	for _, l := range receipt.Logs {
+		if l.Address != contract || len(l.Topics) != 4 {
+			continue
+		}
+		from, to := common.BytesToAddress(l.Topics[2].Bytes()), common.BytesToAddress(l.Topics[3].Bytes())
+		switch l.Topics[0] {
+		case transferSingleSig:
+			if len(l.Data) != 64 {
+				continue
+			}
+			moves = append(moves, erc1155Move{from, to, new(big.Int).SetBytes(l.Data[:32]), new(big.Int).SetBytes(l.Data[32:])})
+		case transferBatchSig:
+			decoded, err := transferBatchData.Unpack(l.Data)
+			if err != nil {
+				continue
+			}
+			ids, values := decoded[0].([]*big.Int), decoded[1].([]*big.Int)
+			if len(ids) != len(values) {
+				continue
+			}
+			for i := range ids {
+				moves = append(moves, erc1155Move{from, to, ids[i], values[i]})
+			}
+		}
+	}
+	return moves
+}
+
+// verifyERC721Event checks that contract emitted an ERC721 Transfer of token
+// id from from to to in receipt.
+func verifyERC721Event(receipt *types.Receipt, contract, from, to common.Address, id *big.Int) error {
+	var others []string
+	for _, l := range receipt.Logs {
+		if l.Address != contract || len(l.Topics) != 4 || l.Topics[0] != transferEventSig {
+			continue
+		}
+		evFrom, evTo := common.BytesToAddress(l.Topics[1].Bytes()), common.BytesToAddress(l.Topics[2].Bytes())
+		evID := new(big.Int).SetBytes(l.Topics[3].Bytes())
+		if evFrom == from && evTo == to && evID.Cmp(id) == 0 {
+			return nil
+		}
+		others = append(others, fmt.Sprintf("%s->%s #%s", evFrom.Hex(), evTo.Hex(), evID))
+	}
+	msg := fmt.Sprintf("transfer event mismatch: expected %s->%s #%s", from.Hex(), to.Hex(), id)
+	if len(others) == 0 {
+		return errors.New(msg + ", " + contract.Hex() + " emitted no ERC721 Transfer event")
+	}
+	return errors.New(msg + " (transfers: " + strings.Join(others, ", ") + ")")
+}
+
+// verifyERC1155Event checks that the TransferSingle and TransferBatch logs
+// contract emitted in receipt move exactly amount of token id from from to to.
+func verifyERC1155Event(receipt *types.Receipt, contract, from, to common.Address, id, amount *big.Int) error {
+	credited := new(big.Int)
+	var others []string
+	for _, m := range erc1155Moves(receipt, contract) {
+		if m.from == from && m.to == to && m.id.Cmp(id) == 0 {
+			credited.Add(credited, m.value)
+			continue
+		}
+		others = append(others, fmt.Sprintf("%s->%s #%s x%s", m.from.Hex(), m.to.Hex(), m.id, m.value))
+	}
+	if credited.Cmp(amount) == 0 {
+		return nil
+	}
+	msg := fmt.Sprintf("transfer event mismatch: expected %s->%s #%s x%s, transfer events credit %s", from.Hex(), to.Hex(), id, amount, credited)
+	if len(others) > 0 {
+		msg += " (other transfers: " + strings.Join(others, ", ") + ")"
+	}
+	return errors.New(msg)
+}
+
+// verifyReceipt checks the events of a mined transfer against req. Native
+// transfers and contract calls have no event to check.
+func verifyReceipt(receipt *types.Receipt, from common.Address, req txRequest) error {
+	switch req.txType {
+	case TxTypeERC20:
+		return verifyTransferEvent(receipt, req.to, from, req.recipient, req.amount)
+	case TxTypeERC721:
+		return verifyERC721Event(receipt, req.to, from, req.recipient, req.tokenID)
+	case TxTypeERC1155:
+		return verifyERC1155Event(receipt, req.to, from, req.recipient, req.tokenID, req.amount)
+	}
+	return nil
+}
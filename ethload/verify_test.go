@@ -0,0 +1,98 @@
+package ethload
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// transferLog is an ERC20 Transfer log of value from from to to emitted by
+// token.
+func transferLog(token, from, to common.Address, value int64) *types.Log {
+	return &types.Log{
+		Address: token,
+		Topics:  []common.Hash{transferEventSig, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())},
+		Data:    common.BigToHash(big.NewInt(value)).Bytes(),
+	}
+}
+
+// successReceipt is a successful receipt carrying logs.
+func successReceipt(logs ...*types.Log) *types.Receipt {
+	return &types.Receipt{Status: types.ReceiptStatusSuccessful, Logs: logs}
+}
+
+func TestVerifyTransferEvent(t *testing.T) {
+	token := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	other := common.HexToAddress("0x3000000000000000000000000000000000000003")
+	from := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	to := common.HexToAddress("0x1000000000000000000000000000000000000002")
+	feeSink := common.HexToAddress("0x1000000000000000000000000000000000000003")
+	erc721 := transferLog(token, from, to, 0)
+	erc721.Topics = append(erc721.Topics, common.BigToHash(big.NewInt(100)))
+	erc721.Data = nil
+
+	tests := []struct {
+		name    string
+		receipt *types.Receipt
+		wantErr string // empty when the events must match
+	}{
+		{name: "exact", receipt: successReceipt(transferLog(token, from, to, 100))},
+		{name: "split across events", receipt: successReceipt(transferLog(token, from, to, 60), transferLog(token, from, to, 40))},
+		{name: "other logs ignored", receipt: successReceipt(
+			transferLog(other, from, to, 5),
+			&types.Log{Address: token, Topics: []common.Hash{approvalEventSig, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())}, Data: common.BigToHash(big.NewInt(1)).Bytes()},
+			transferLog(token, from, to, 100),
+		)},
+		{name: "no Transfer log", receipt: successReceipt(),
+			wantErr: "emitted no Transfer event"},
+		{name: "only another token's Transfer", receipt: successReceipt(transferLog(other, from, to, 100)),
+			wantErr: "emitted no Transfer event"},
+		{name: "ERC721-shaped Transfer", receipt: successReceipt(erc721),
+			wantErr: "emitted no Transfer event"},
+		{name: "wrong from", receipt: successReceipt(transferLog(token, other, to, 100)),
+			wantErr: "events credit 0 (other transfers: " + other.Hex() + "->" + to.Hex() + " 100)"},
+		{name: "wrong to", receipt: successReceipt(transferLog(token, from, other, 100)),
+			wantErr: "events credit 0 (other transfers: " + from.Hex() + "->" + other.Hex() + " 100)"},
+		{name: "fee on transfer", receipt: successReceipt(transferLog(token, from, to, 98), transferLog(token, from, feeSink, 2)),
+			wantErr: "events credit 98 (other transfers: " + from.Hex() + "->" + feeSink.Hex() + " 2)"},
+		{name: "more than sent", receipt: successReceipt(transferLog(token, from, to, 101)),
+			wantErr: "expected " + from.Hex() + "->" + to.Hex() + " 100, events credit 101"},
+	}
+	for _, tt := range tests {
+		err := verifyTransferEvent(tt.receipt, token, from, to, big.NewInt(100))
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) || !strings.HasPrefix(err.Error(), "transfer event mismatch: ") {
+			t.Errorf("%s: error %v, want a mismatch containing %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestVerifyReceipt(t *testing.T) {
+	token := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	from := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	to := common.HexToAddress("0x1000000000000000000000000000000000000002")
+	receipt := successReceipt(transferLog(token, from, to, 7))
+
+	erc20 := txRequest{txType: TxTypeERC20, recipient: to, to: token, amount: big.NewInt(7)}
+	if err := verifyReceipt(receipt, from, erc20); err != nil {
+		t.Errorf("matching ERC20 transfer: %v", err)
+	}
+	erc20.amount = big.NewInt(8)
+	if err := verifyReceipt(receipt, from, erc20); err == nil {
+		t.Error("ERC20 transfer of the wrong amount verified")
+	}
+	// Native transfers and calls have no event to check.
+	for _, txType := range []string{TxTypeNative, TxTypeContractCall} {
+		if err := verifyReceipt(successReceipt(), from, txRequest{txType: txType, recipient: to, to: to}); err != nil {
+			t.Errorf("%s: %v", txType, err)
+		}
+	}
+}
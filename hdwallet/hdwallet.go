@@ -0,0 +1,124 @@
+// Package hdwallet derives load-test sender keys from a BIP39 mnemonic along
+// BIP44 paths, so a single secret can back hundreds of wallets for the eth and
+// tron runners.
+package hdwallet
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/fbsobreira/gotron-sdk/pkg/keys/hd"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// Default path templates; IndexPlaceholder is replaced by each index.
+const (
+	EthereumPath     = "m/44'/60'/0'/0/{index}"
+	TronPath         = "m/44'/195'/0'/0/{index}"
+	IndexPlaceholder = "{index}"
+)
+
+// Config selects the mnemonic and the keys derived from it.
+type Config struct {
+	MnemonicEnv   string `json:"mnemonicEnv"`   // env var holding the mnemonic
+	MnemonicFile  string `json:"mnemonicFile"`  // file holding the mnemonic, when mnemonicEnv is unset or empty
+	PassphraseEnv string `json:"passphraseEnv"` // env var holding the optional BIP39 passphrase
+	Path          string `json:"path"`          // path template with {index}; defaults to the runner's coin
+	From          int    `json:"from"`          // first index
+	Count         int    `json:"count"`         // number of keys
+}
+
+// Key is one derived key and the path it was derived along.
+type Key struct {
+	Path       string
+	PrivateKey *ecdsa.PrivateKey
+}
+
+// Derive loads the mnemonic of c and derives its keys, using defaultPath when
+// c has no path.
+func (c Config) Derive(defaultPath string) ([]Key, error) {
+	if c.Count <= 0 {
+		return nil, fmt.Errorf("count must be positive")
+	}
+	if c.From < 0 {
+		return nil, fmt.Errorf("from must not be negative")
+	}
+	mnemonic, err := LoadMnemonic(c.MnemonicEnv, c.MnemonicFile)
+	if err != nil {
+		return nil, err
+	}
+	passphrase := ""
+	if c.PassphraseEnv != "" {
+		passphrase = os.Getenv(c.PassphraseEnv)
+	}
+	path := c.Path
+	if path == "" {
+		path = defaultPath
+	}
+	return Derive(mnemonic, passphrase, path, c.From, c.Count)
+}
+
+// LoadMnemonic reads a mnemonic from the env var named env or, when that is
+// unset or empty, from file, and checks its BIP39 checksum.
+func LoadMnemonic(env, file string) (string, error) {
+	var mnemonic string
+	if env != "" {
+		mnemonic = os.Getenv(env)
+	}
+	if mnemonic == "" && file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read mnemonic: %w", err)
+		}
+		mnemonic = string(data)
+	}
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	if mnemonic == "" {
+		var sources []string
+		if env != "" {
+			sources = append(sources, "env "+env)
+		}
+		if file != "" {
+			sources = append(sources, "file "+file)
+		}
+		if len(sources) == 0 {
+			return "", fmt.Errorf("no mnemonic source: set an env var or a file")
+		}
+		return "", fmt.Errorf("no mnemonic in %s", strings.Join(sources, " or "))
+	}
+	if !bip39.IsMnemonicValid(mnemonic) {
+		return "", fmt.Errorf("mnemonic is not a valid BIP39 mnemonic")
+	}
+	return mnemonic, nil
+}
+
+// Derive derives count keys from mnemonic along template, with
+// IndexPlaceholder replaced by from, from+1, ...
+func Derive(mnemonic, passphrase, template string, from, count int) ([]Key, error) {
+	if !strings.HasPrefix(template, "m/") || !strings.Contains(template, IndexPlaceholder) {
+		return nil, fmt.Errorf("invalid path %q: want m/... with %s", template, IndexPlaceholder)
+	}
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mnemonic: %w", err)
+	}
+	master, chainCode := hd.ComputeMastersFromSeed(seed, []byte("Bitcoin seed"))
+	keys := make([]Key, 0, count)
+	for i := from; i < from+count; i++ {
+		path := strings.ReplaceAll(template, IndexPlaceholder, strconv.Itoa(i))
+		raw, err := hd.DerivePrivateKeyForPath(crypto.S256(), master, chainCode, strings.TrimPrefix(path, "m/"))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		key, err := crypto.ToECDSA(raw[:])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		keys = append(keys, Key{Path: path, PrivateKey: key})
+	}
+	return keys, nil
+}
@@ -0,0 +1,179 @@
+package hdwallet
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/fbsobreira/gotron-sdk/pkg/address"
+	"github.com/tyler-smith/go-bip39"
+)
+
+const (
+	// The BIP39 test vector mnemonic of 128 bits of zero entropy.
+	abandonMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	// The Hardhat and Anvil default mnemonic.
+	junkMnemonic = "test test test test test test test test test test test junk"
+)
+
+func TestBIP39Seed(t *testing.T) {
+	// BIP39 reference vector: zero entropy with passphrase "TREZOR".
+	seed, err := bip39.NewSeedWithErrorChecking(abandonMnemonic, "TREZOR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04"
+	if got := hex.EncodeToString(seed); got != want {
+		t.Errorf("seed = %s, want %s", got, want)
+	}
+}
+
+func TestDerive(t *testing.T) {
+	tests := []struct {
+		name       string
+		mnemonic   string
+		passphrase string
+		template   string
+		from       int
+		keys       []string // private keys, hex
+		addresses  []string // Ethereum hex or TRON base58
+	}{
+		{
+			name: "ethereum", mnemonic: junkMnemonic, template: EthereumPath,
+			keys: []string{
+				"ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80",
+				"59c6995e998f97a5a0044966f0945389dc9e86dae88c7a8412f4603b6b78690d",
+				"5de4111afa1a4b94908f83103eb1f1706367c2e68ca870fc3fb9a804cdab365a",
+			},
+			addresses: []string{
+				"0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266",
+				"0x70997970C51812dc3A010C7d01b50e0d17dc79C8",
+				"0x3C44CdDdB6a900fa2b585dd299e03d12FA4293BC",
+			},
+		},
+		{
+			name: "ethereum from an offset", mnemonic: junkMnemonic, template: EthereumPath, from: 2,
+			keys:      []string{"5de4111afa1a4b94908f83103eb1f1706367c2e68ca870fc3fb9a804cdab365a"},
+			addresses: []string{"0x3C44CdDdB6a900fa2b585dd299e03d12FA4293BC"},
+		},
+		{
+			name: "ethereum zero entropy", mnemonic: abandonMnemonic, template: EthereumPath,
+			keys:      []string{"1ab42cc412b618bdea3a599e3c9bae199ebf030895b039e9db1e30dafb12b727"},
+			addresses: []string{"0x9858EfFD232B4033E47d90003D41EC34EcaEda94"},
+		},
+		{
+			name: "tron", mnemonic: abandonMnemonic, template: TronPath,
+			keys: []string{
+				"b5a4cea271ff424d7c31dc12a3e43e401df7a40d7412a15750f3f0b6b5449a28",
+				"edb728e259afca2ddcc428459e7681b8414668649aedbc8d25c0872da219b2e6",
+				"0e5684898be2d272d54eb2be3fd41a12f720db6358cee02c2d23043eed4bf7a2",
+			},
+			addresses: []string{
+				"TUEZSdKsoDHQMeZwihtdoBiN46zxhGWYdH",
+				"TSeJkUh4Qv67VNFwY8LaAxERygNdy6NQZK",
+				"TYJPRrdB5APNeRs4R7fYZSwW3TcrTKw2gx",
+			},
+		},
+		{
+			name: "tron with passphrase", mnemonic: abandonMnemonic, passphrase: "TREZOR", template: TronPath,
+			keys: []string{"554d613c6ae7cfe1f7cc0814f48e8eab176ca316fd7d1153fcd7a45b73fee11e"},
+		},
+	}
+	for _, tt := range tests {
+		keys, err := Derive(tt.mnemonic, tt.passphrase, tt.template, tt.from, len(tt.keys))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(keys) != len(tt.keys) {
+			t.Errorf("%s: %d keys, want %d", tt.name, len(keys), len(tt.keys))
+			continue
+		}
+		for i, k := range keys {
+			wantPath := strings.ReplaceAll(tt.template, IndexPlaceholder, strconv.Itoa(tt.from+i))
+			if k.Path != wantPath {
+				t.Errorf("%s: key %d path %s, want %s", tt.name, i, k.Path, wantPath)
+			}
+			if got := hex.EncodeToString(crypto.FromECDSA(k.PrivateKey)); got != tt.keys[i] {
+				t.Errorf("%s: key %d = %s, want %s", tt.name, i, got, tt.keys[i])
+			}
+			if i >= len(tt.addresses) {
+				continue
+			}
+			got := crypto.PubkeyToAddress(k.PrivateKey.PublicKey).Hex()
+			if tt.template == TronPath {
+				got = address.PubkeyToAddress(k.PrivateKey.PublicKey).String()
+			}
+			if got != tt.addresses[i] {
+				t.Errorf("%s: address %d = %s, want %s", tt.name, i, got, tt.addresses[i])
+			}
+		}
+	}
+}
+
+func TestDeriveRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		mnemonic string
+		template string
+	}{
+		{"no index", junkMnemonic, "m/44'/60'/0'/0/0"},
+		{"not from the master", junkMnemonic, "44'/60'/0'/0/{index}"},
+		{"bad checksum", strings.Replace(junkMnemonic, "junk", "test", 1), EthereumPath},
+	}
+	for _, tt := range tests {
+		if _, err := Derive(tt.mnemonic, "", tt.template, 0, 1); err == nil {
+			t.Errorf("%s: accepted", tt.name)
+		}
+	}
+}
+
+func TestConfigDerive(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "mnemonic")
+	// Extra whitespace and line breaks are normalized.
+	if err := os.WriteFile(file, []byte("  "+strings.ReplaceAll(abandonMnemonic, " abandon", "\nabandon")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HDWALLET_TEST_MNEMONIC", junkMnemonic)
+	t.Setenv("HDWALLET_TEST_EMPTY", "")
+	t.Setenv("HDWALLET_TEST_PASSPHRASE", "TREZOR")
+
+	tests := []struct {
+		name    string
+		config  Config
+		want    string // first key; empty when Derive must fail
+		wantErr string
+	}{
+		{name: "env", config: Config{MnemonicEnv: "HDWALLET_TEST_MNEMONIC", MnemonicFile: file, Count: 1},
+			want: "ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80"},
+		{name: "file when the env var is empty", config: Config{MnemonicEnv: "HDWALLET_TEST_EMPTY", MnemonicFile: file, Count: 1},
+			want: "1ab42cc412b618bdea3a599e3c9bae199ebf030895b039e9db1e30dafb12b727"},
+		{name: "passphrase and path", config: Config{MnemonicFile: file, PassphraseEnv: "HDWALLET_TEST_PASSPHRASE", Path: TronPath, Count: 1},
+			want: "554d613c6ae7cfe1f7cc0814f48e8eab176ca316fd7d1153fcd7a45b73fee11e"},
+		{name: "no source", config: Config{Count: 1}, wantErr: "no mnemonic source"},
+		{name: "empty sources", config: Config{MnemonicEnv: "HDWALLET_TEST_EMPTY", Count: 1}, wantErr: "no mnemonic in env HDWALLET_TEST_EMPTY"},
+		{name: "missing file", config: Config{MnemonicFile: file + ".missing", Count: 1}, wantErr: "failed to read mnemonic"},
+		{name: "no count", config: Config{MnemonicEnv: "HDWALLET_TEST_MNEMONIC"}, wantErr: "count must be positive"},
+		{name: "negative from", config: Config{MnemonicEnv: "HDWALLET_TEST_MNEMONIC", From: -1, Count: 1}, wantErr: "from must not be negative"},
+	}
+	for _, tt := range tests {
+		keys, err := tt.config.Derive(EthereumPath)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := hex.EncodeToString(crypto.FromECDSA(keys[0].PrivateKey)); got != tt.want {
+			t.Errorf("%s: key %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Package metrics exposes live Prometheus metrics for the eth and tron load
+// runners, so long runs can be watched in Grafana instead of waiting for the
+// end-of-run summary.
+package metrics
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// latencyBuckets mirror the histogram bounds used in the eth results summary.
+var latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+var (
+	registry = prometheus.NewRegistry()
+
+	sent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "loadtest_transfers_sent_total",
+		Help: "Transfers broadcast and acknowledged by the node.",
+	}, []string{"runner"})
+	succeeded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "loadtest_transfers_succeeded_total",
+		Help: "Transfers that finished successfully.",
+	}, []string{"runner"})
+	failed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "loadtest_transfers_failed_total",
+		Help: "Transfers that finished unsuccessfully, by error class.",
+	}, []string{"runner", "class"})
+	retried = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "loadtest_transfers_retried_total",
+		Help: "Transfer attempts retried after a transient error.",
+	}, []string{"runner"})
+	inFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "loadtest_transfers_in_flight",
+		Help: "Transfers started but not yet finished.",
+	}, []string{"runner"})
+	submitLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "loadtest_submit_latency_seconds",
+		Help:    "Time from signing a transfer to the node acknowledging it.",
+		Buckets: latencyBuckets,
+	}, []string{"runner"})
+	inclusionLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "loadtest_inclusion_latency_seconds",
+		Help:    "Time from the node acknowledging a transfer to its receipt.",
+		Buckets: latencyBuckets,
+	}, []string{"runner"})
+)
+
+func init() {
+	registry.MustRegister(sent, succeeded, failed, retried, inFlight, submitLatency, inclusionLatency)
+}
+
+// Serve starts a /metrics listener on addr in the background. An empty addr
+// disables metrics and returns a nil server.
+func Serve(addr string) (*http.Server, error) {
+	if strings.TrimSpace(addr) == "" {
+		return nil, nil
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("metrics listener stopped: %v", err)
+		}
+	}()
+	log.Printf("Serving Prometheus metrics on http://%s/metrics", ln.Addr())
+	return srv, nil
+}
+
+// Sent records a transfer the node acknowledged submit after it was signed.
+func Sent(runner string, submit time.Duration) {
+	sent.WithLabelValues(runner).Inc()
+	submitLatency.WithLabelValues(runner).Observe(submit.Seconds())
+}
+
+// Included records a receipt that arrived inclusion after the node ack.
+func Included(runner string, inclusion time.Duration) {
+	inclusionLatency.WithLabelValues(runner).Observe(inclusion.Seconds())
+}
+
+// Succeeded counts a transfer that finished successfully.
+func Succeeded(runner string) {
+	succeeded.WithLabelValues(runner).Inc()
+}
+
+// Failed counts a transfer that finished unsuccessfully, classed by its error.
+func Failed(runner, class string) {
+	failed.WithLabelValues(runner, class).Inc()
+}
+
+// Retried counts a transfer attempt that is about to be retried.
+func Retried(runner string) {
+	retried.WithLabelValues(runner).Inc()
+}
+
+// Started marks a transfer as in flight; call the returned func when it finishes.
+func Started(runner string) (done func()) {
+	g := inFlight.WithLabelValues(runner)
+	g.Inc()
+	return g.Dec
+}
+
+// ClassifyError maps an error message to a small, stable set of classes
+// suitable for a metric label.
+func ClassifyError(msg string) string {
+	l := strings.ToLower(msg)
+	switch {
+	case l == "":
+		return "unknown"
+	case strings.Contains(l, "nonce"), strings.Contains(l, "already known"):
+		return "nonce"
+	case strings.Contains(l, "insufficient"):
+		return "insufficient_funds"
+	case strings.Contains(l, "underpriced"), strings.Contains(l, "fee too low"), strings.Contains(l, "less than block base fee"):
+		return "underpriced"
+	case strings.Contains(l, "revert"):
+		return "reverted"
+	case strings.Contains(l, "rate limit"), strings.Contains(l, "too many requests"), strings.Contains(l, "429"):
+		return "rate_limited"
+	case strings.Contains(l, "timeout"), strings.Contains(l, "deadline exceeded"), strings.Contains(l, "context canceled"), strings.Contains(l, "context cancelled"):
+		return "timeout"
+	case strings.Contains(l, "connection"), strings.Contains(l, "rpc"), strings.Contains(l, "eof"), strings.Contains(l, "unavailable"):
+		return "rpc"
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,213 @@
+// Package secrets resolves the private keys and API keys the eth and tron
+// runners are configured with, and redacts them from logs, errors and results
+// files.
+//
+// A configured value may be a reference instead of the secret itself:
+//
+//	env:NAME       the value of env var NAME
+//	file:PATH      the contents of PATH, without surrounding whitespace
+//	keystore:PATH  the hex private key of a Web3 Secret Storage (UTC--...) file
+//
+// Anything else is used as is.
+package secrets
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Reference schemes
+const (
+	SchemeEnv      = "env:"
+	SchemeFile     = "file:"
+	SchemeKeystore = "keystore:"
+)
+
+// Redacted replaces every secret in redacted text.
+const Redacted = "[REDACTED]"
+
+// Resolver resolves references, caching what each one resolved to so a
+// keystore file is decrypted once. It is safe for concurrent use.
+type Resolver struct {
+	passphrase func() (string, error)
+
+	mu    sync.Mutex
+	cache map[string]string
+}
+
+// NewResolver returns a Resolver that gets the passphrase of keystore:
+// references from passphrase.
+func NewResolver(passphrase func() (string, error)) *Resolver {
+	return &Resolver{passphrase: passphrase, cache: make(map[string]string)}
+}
+
+// IsReference reports whether v is a reference rather than a literal value.
+func IsReference(v string) bool {
+	v = strings.TrimSpace(v)
+	return strings.HasPrefix(v, SchemeEnv) || strings.HasPrefix(v, SchemeFile) || strings.HasPrefix(v, SchemeKeystore)
+}
+
+// Resolve returns the secret v refers to, or v itself when it is not a
+// reference. The result is registered for redaction.
+func (r *Resolver) Resolve(v string) (string, error) {
+	v = strings.TrimSpace(v)
+	if !IsReference(v) {
+		Register(v)
+		return v, nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if secret, ok := r.cache[v]; ok {
+		return secret, nil
+	}
+	var secret string
+	switch {
+	case strings.HasPrefix(v, SchemeEnv):
+		name := strings.TrimPrefix(v, SchemeEnv)
+		if secret = os.Getenv(name); secret == "" {
+			return "", fmt.Errorf("env var %s is not set", name)
+		}
+	case strings.HasPrefix(v, SchemeFile):
+		path := strings.TrimPrefix(v, SchemeFile)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret: %w", err)
+		}
+		if secret = strings.TrimSpace(string(data)); secret == "" {
+			return "", fmt.Errorf("secret file %s is empty", path)
+		}
+	case strings.HasPrefix(v, SchemeKeystore):
+		path := strings.TrimPrefix(v, SchemeKeystore)
+		if r.passphrase == nil {
+			return "", fmt.Errorf("no passphrase for %s", path)
+		}
+		passphrase, err := r.passphrase()
+		if err != nil {
+			return "", err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read keystore: %w", err)
+		}
+		key, err := keystore.DecryptKey(data, passphrase)
+		if err != nil {
+			return "", fmt.Errorf("failed to decrypt %s: %w", path, err)
+		}
+		secret = hex.EncodeToString(crypto.FromECDSA(key.PrivateKey))
+	}
+	Register(secret)
+	r.cache[v] = secret
+	return secret, nil
+}
+
+// ResolveAll resolves every value of vs.
+func (r *Resolver) ResolveAll(vs []string) ([]string, error) {
+	out := make([]string, len(vs))
+	for i, v := range vs {
+		s, err := r.Resolve(v)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = s
+	}
+	return out, nil
+}
+
+// FromEnv resolves the value of env var name, which may itself be a
+// reference. It returns "" when name is unset.
+func (r *Resolver) FromEnv(name string) (string, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return "", nil
+	}
+	s, err := r.Resolve(v)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	return s, nil
+}
+
+var (
+	mu    sync.RWMutex
+	known = make(map[string]bool)
+)
+
+// minSecretLen keeps short values (ports, chain IDs) from being registered.
+const minSecretLen = 8
+
+// Register marks secret for redaction. Hex secrets are matched with or
+// without a 0x prefix and in either case.
+func Register(secret string) {
+	secret = strings.TrimSpace(secret)
+	bare := strings.TrimPrefix(strings.TrimPrefix(secret, "0x"), "0X")
+	if len(bare) < minSecretLen {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	known[secret] = true
+	if isHex(bare) {
+		known[strings.ToLower(bare)] = true
+		known[strings.ToUpper(bare)] = true
+	}
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+var patterns = []*regexp.Regexp{
+	// Values labelled as secrets: privateKey=..., "apiKey": "...", mnemonic: ...
+	regexp.MustCompile(`(?i)((?:private[_ -]?key|priv[_ -]?key|api[_ -]?key|secret|mnemonic|passphrase|password)s?["']?\s*[:=]\s*["']?)[^\s"',}\]]+`),
+	// RPC URLs carrying an API key in the path
+	regexp.MustCompile(`(?i)((?:infura\.io|alchemy\.com|alchemyapi\.io|quiknode\.pro|ankr\.com|blastapi\.io|chainstack\.com|getblock\.io)/(?:v\d+/|[a-z0-9-]+/v\d+/)?)[A-Za-z0-9_-]{16,}`),
+	// UUID API keys, e.g. TronGrid's
+	regexp.MustCompile(`(?i)\b()[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`),
+}
+
+// Redact replaces every registered secret, and anything that looks like a
+// labelled secret or an API key, in s.
+func Redact(s string) string {
+	mu.RLock()
+	for secret := range known {
+		if strings.Contains(s, secret) {
+			s = strings.ReplaceAll(s, secret, Redacted)
+		}
+	}
+	mu.RUnlock()
+	for _, p := range patterns {
+		s = p.ReplaceAllString(s, "${1}"+Redacted)
+	}
+	return s
+}
+
+// RedactBytes is Redact for bytes, e.g. a marshalled results file.
+func RedactBytes(b []byte) []byte {
+	return []byte(Redact(string(b)))
+}
+
+// Writer returns a writer that redacts what it writes to w. Each write is
+// redacted on its own, which suits the log package's one write per line.
+func Writer(w io.Writer) io.Writer {
+	return redactingWriter{w}
+}
+
+type redactingWriter struct {
+	w io.Writer
+}
+
+func (r redactingWriter) Write(p []byte) (int, error) {
+	if _, err := r.w.Write(RedactBytes(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
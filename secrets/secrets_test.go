@@ -0,0 +1,245 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestRegister(t *testing.T) {
+	tests := []struct {
+		name     string
+		secret   string
+		text     string
+		redacted bool
+	}{
+		{"plain", "sup3r-s3cret-value", "token sup3r-s3cret-value here", true},
+		{"surrounding space is trimmed", "  trimmed-secret  ", "x trimmed-secret y", true},
+		{"minimum length", "abcdefgh", "got abcdefgh", true},
+		{"below minimum length", "abcdefg", "got abcdefg", false},
+		{"0x prefix not counted", "0x1234567", "got 0x1234567", false},
+		{"hex without prefix", "0xaabbccddeeff0011", "got aabbccddeeff0011", true},
+		{"hex upper case", "0xaabbccddeeff0022", "got AABBCCDDEEFF0022", true},
+		{"hex with prefix", "ccddeeff00112233", "got 0xccddeeff00112233", true},
+		{"non-hex is case sensitive", "Mixed-Case-Secret", "got mixed-case-secret", false},
+	}
+	for _, tt := range tests {
+		Register(tt.secret)
+		got := Redact(tt.text)
+		if redacted := strings.Contains(got, Redacted); redacted != tt.redacted {
+			t.Errorf("%s: Redact(%q) = %q, redacted %v, want %v", tt.name, tt.text, got, redacted, tt.redacted)
+		}
+	}
+}
+
+func TestRedactPatterns(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"private key label", "privateKey=deadbeefcafe", "privateKey=" + Redacted},
+		{"api key in JSON", `{"apiKey": "abc123xyz"}`, `{"apiKey": "` + Redacted + `"}`},
+		{"mnemonic label", "mnemonic: word", "mnemonic: " + Redacted},
+		{"passphrase label", "PASSPHRASE=hunter2", "PASSPHRASE=" + Redacted},
+		{"infura URL", "dial https://sepolia.infura.io/v3/0123456789abcdef0123: refused",
+			"dial https://sepolia.infura.io/v3/" + Redacted + ": refused"},
+		{"alchemy URL", "https://eth-sepolia.g.alchemy.com/v2/AbCdEfGhIjKlMnOpQrSt",
+			"https://eth-sepolia.g.alchemy.com/v2/" + Redacted},
+		{"quicknode URL", "https://x.quiknode.pro/0123456789abcdef0123456789/",
+			"https://x.quiknode.pro/" + Redacted + "/"},
+		{"UUID", "TRON-PRO-API-KEY 123e4567-e89b-12d3-a456-426614174000 set",
+			"TRON-PRO-API-KEY " + Redacted + " set"},
+		{"short provider path kept", "https://sepolia.infura.io/v3/short", "https://sepolia.infura.io/v3/short"},
+		{"nothing secret", "sent 5 transfers to 0xabc", "sent 5 transfers to 0xabc"},
+	}
+	for _, tt := range tests {
+		if got := Redact(tt.in); got != tt.want {
+			t.Errorf("%s: Redact(%q) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	Register("writer-test-secret")
+	var buf bytes.Buffer
+	w := Writer(&buf)
+	line := []byte("connecting with writer-test-secret\n")
+	n, err := w.Write(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(line) {
+		t.Errorf("Write returned %d, want the input length %d", n, len(line))
+	}
+	if got, want := buf.String(), "connecting with "+Redacted+"\n"; got != want {
+		t.Errorf("wrote %q, want %q", got, want)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("closed") }
+
+func TestWriterError(t *testing.T) {
+	if _, err := Writer(failingWriter{}).Write([]byte("x")); err == nil {
+		t.Error("write error was swallowed")
+	}
+}
+
+func TestIsReference(t *testing.T) {
+	for v, want := range map[string]bool{
+		"env:X":            true,
+		" file:/tmp/k":     true,
+		"keystore:UTC--x":  true,
+		"0xdeadbeef":       false,
+		"https://rpc.test": false,
+		"ENV:X":            false,
+	} {
+		if got := IsReference(v); got != want {
+			t.Errorf("IsReference(%q) = %v, want %v", v, got, want)
+		}
+	}
+}
+
+func TestResolveLiteral(t *testing.T) {
+	r := NewResolver(nil)
+	got, err := r.Resolve(" literal-secret-value ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "literal-secret-value" {
+		t.Errorf("Resolve = %q, want the trimmed literal", got)
+	}
+	if Redact(got) != Redacted {
+		t.Error("literal value not registered for redaction")
+	}
+}
+
+func TestResolveEnv(t *testing.T) {
+	t.Setenv("SECRETS_TEST_KEY", "env-secret-value")
+	r := NewResolver(nil)
+	got, err := r.Resolve("env:SECRETS_TEST_KEY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "env-secret-value" {
+		t.Errorf("Resolve = %q, want env-secret-value", got)
+	}
+	if Redact("x env-secret-value") != "x "+Redacted {
+		t.Error("env secret not registered for redaction")
+	}
+	if _, err := r.Resolve("env:SECRETS_TEST_UNSET"); err == nil {
+		t.Error("unset env var resolved")
+	}
+
+	// FromEnv resolves a reference held in an env var, and "" when unset.
+	t.Setenv("SECRETS_TEST_REF", "env:SECRETS_TEST_KEY")
+	if got, err := r.FromEnv("SECRETS_TEST_REF"); err != nil || got != "env-secret-value" {
+		t.Errorf("FromEnv = %q, %v, want env-secret-value", got, err)
+	}
+	if got, err := r.FromEnv("SECRETS_TEST_UNSET"); err != nil || got != "" {
+		t.Errorf("FromEnv of an unset var = %q, %v, want empty", got, err)
+	}
+}
+
+func TestResolveFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "key")
+	if err := os.WriteFile(path, []byte("\n  file-secret-value \n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	empty := filepath.Join(dir, "empty")
+	if err := os.WriteFile(empty, []byte(" \n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	r := NewResolver(nil)
+	got, err := r.Resolve("file:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "file-secret-value" {
+		t.Errorf("Resolve = %q, want the trimmed file contents", got)
+	}
+
+	// Resolved once: a changed file is not read again.
+	if err := os.WriteFile(path, []byte("changed-secret-value"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := r.Resolve("file:" + path); got != "file-secret-value" {
+		t.Errorf("second Resolve = %q, want the cached file-secret-value", got)
+	}
+
+	if _, err := r.Resolve("file:" + empty); err == nil {
+		t.Error("empty file resolved")
+	}
+	if _, err := r.Resolve("file:" + filepath.Join(dir, "missing")); err == nil {
+		t.Error("missing file resolved")
+	}
+}
+
+func TestResolveKeystore(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := keystore.EncryptKey(&keystore.Key{
+		Address:    crypto.PubkeyToAddress(key.PublicKey),
+		PrivateKey: key,
+	}, "right", keystore.LightScryptN, keystore.LightScryptP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "UTC--test")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	want := hex.EncodeToString(crypto.FromECDSA(key))
+	passphrase := func(p string) func() (string, error) {
+		return func() (string, error) { return p, nil }
+	}
+
+	got, err := NewResolver(passphrase("right")).Resolve("keystore:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("Resolve = %s, want the stored key", got)
+	}
+	if Redact("key 0x"+strings.ToUpper(want)) != "key 0x"+Redacted {
+		t.Error("decrypted key not registered for redaction")
+	}
+
+	if _, err := NewResolver(passphrase("wrong")).Resolve("keystore:" + path); err == nil {
+		t.Error("keystore decrypted with the wrong passphrase")
+	}
+	if _, err := NewResolver(nil).Resolve("keystore:" + path); err == nil {
+		t.Error("keystore resolved without a passphrase")
+	}
+	failing := func() (string, error) { return "", errors.New("no passphrase set") }
+	if _, err := NewResolver(failing).Resolve("keystore:" + path); err == nil {
+		t.Error("passphrase error ignored")
+	}
+}
+
+func TestResolveAll(t *testing.T) {
+	t.Setenv("SECRETS_TEST_ALL", "resolve-all-secret")
+	r := NewResolver(nil)
+	got, err := r.ResolveAll([]string{"env:SECRETS_TEST_ALL", "literal-all-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != "resolve-all-secret" || got[1] != "literal-all-secret" {
+		t.Errorf("ResolveAll = %v", got)
+	}
+	if _, err := r.ResolveAll([]string{"literal-all-secret", "env:SECRETS_TEST_UNSET"}); err == nil {
+		t.Error("ResolveAll ignored an unresolvable value")
+	}
+}
@@ -7,14 +7,31 @@ import (
 	"math"
 	"math/big"
 	"math/rand"
+	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
+	"tron_load/hdwallet"
+	"tron_load/metrics"
+	"tron_load/secrets"
 	"tron_load/trx/grpcs"
 	"tron_load/trx/sign"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/fbsobreira/gotron-sdk/pkg/address"
 )
 
 var (
 	Client *grpcs.Client
+	// Resolves the env:, file: and keystore: references of keys and the API
+	// key; keystore: files are decrypted with TRON_KEYSTORE_PASSPHRASE.
+	resolver = secrets.NewResolver(func() (string, error) {
+		if p := os.Getenv("TRON_KEYSTORE_PASSPHRASE"); p != "" {
+			return p, nil
+		}
+		return "", fmt.Errorf("set TRON_KEYSTORE_PASSPHRASE to the keystore passphrase")
+	})
 )
 
 func Initialize(rpcUrl, key string) {
@@ -42,19 +59,94 @@ func TransferTrc20(senderAddress string, receiverAddress, USDTTronContract strin
 	return hex.EncodeToString(tx.Txid), nil
 }
 
+// hdSenders derives the senders from the mnemonic in TRON_MNEMONIC or the file
+// TRON_MNEMONIC_FILE, along TRON_HD_PATH (default m/44'/195'/0'/0/{index}) for
+// TRON_HD_COUNT (default 1) indexes from TRON_HD_FROM (default 0). It returns
+// nothing when neither mnemonic variable is set.
+func hdSenders() (privateKeys, addresses []string, err error) {
+	if os.Getenv("TRON_MNEMONIC") == "" && os.Getenv("TRON_MNEMONIC_FILE") == "" {
+		return nil, nil, nil
+	}
+	cfg := hdwallet.Config{
+		MnemonicEnv:   "TRON_MNEMONIC",
+		MnemonicFile:  os.Getenv("TRON_MNEMONIC_FILE"),
+		PassphraseEnv: "TRON_MNEMONIC_PASSPHRASE",
+		Path:          os.Getenv("TRON_HD_PATH"),
+		Count:         1,
+	}
+	if v := os.Getenv("TRON_HD_FROM"); v != "" {
+		if cfg.From, err = strconv.Atoi(v); err != nil {
+			return nil, nil, fmt.Errorf("invalid TRON_HD_FROM %q", v)
+		}
+	}
+	if v := os.Getenv("TRON_HD_COUNT"); v != "" {
+		if cfg.Count, err = strconv.Atoi(v); err != nil {
+			return nil, nil, fmt.Errorf("invalid TRON_HD_COUNT %q", v)
+		}
+	}
+	keys, err := cfg.Derive(hdwallet.TronPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	fmt.Printf("HD wallet: %d sender(s)\n", len(keys))
+	for _, k := range keys {
+		addr := address.PubkeyToAddress(k.PrivateKey.PublicKey).String()
+		fmt.Printf("  %s  %s\n", k.Path, addr)
+		pk := hex.EncodeToString(crypto.FromECDSA(k.PrivateKey))
+		secrets.Register(pk)
+		privateKeys = append(privateKeys, pk)
+		addresses = append(addresses, addr)
+	}
+	return privateKeys, addresses, nil
+}
+
+// envSenders resolves the comma-separated keys (hex or env:, file:,
+// keystore: references) of TRON_PRIVATE_KEYS and derives their addresses.
+func envSenders() (privateKeys, addresses []string, err error) {
+	for i, ref := range strings.Split(os.Getenv("TRON_PRIVATE_KEYS"), ",") {
+		if strings.TrimSpace(ref) == "" {
+			continue
+		}
+		pk, err := resolver.Resolve(ref)
+		if err != nil {
+			return nil, nil, fmt.Errorf("TRON_PRIVATE_KEYS key %d: %w", i+1, err)
+		}
+		pk = strings.TrimPrefix(pk, "0x")
+		key, err := crypto.HexToECDSA(pk)
+		if err != nil {
+			return nil, nil, fmt.Errorf("TRON_PRIVATE_KEYS key %d: invalid private key: %w", i+1, err)
+		}
+		privateKeys = append(privateKeys, pk)
+		addresses = append(addresses, address.PubkeyToAddress(key.PublicKey).String())
+	}
+	return privateKeys, addresses, nil
+}
+
 func main() {
-	grpcURL := "grpc.nile.trongrid.io:50051"         // grpc url
-	apiKey := "7585872f-2797-4599-90e9-7c2a99bf3664" // tron grid api key
+	log.SetOutput(secrets.Writer(os.Stderr))
+	grpcURL := os.Getenv("TRON_GRPC_URL") // grpc url
+	if grpcURL == "" {
+		grpcURL = "grpc.nile.trongrid.io:50051"
+	}
+	apiKey, err := resolver.FromEnv("TRON_API_KEY") // tron grid api key, or a reference to it
+	if err != nil {
+		log.Fatalf("Failed to load API key: %v", err)
+	}
 	Initialize(grpcURL, apiKey)
-	privateKeys := []string{
-		"781fdd322bbfa49bd9ef37115f71621f9d6f4e5b87ec53ce871a001bd1f17233",
-		// "2c452a466a11b0a9886479c49dc1a0b122225c76a17195db92aaa6fa0b3a2b6e",
-	} // tron wallet private key
 
-	addresses := []string{
-		"TM6Z55ogJsC1p4oSA6zF3n2Aoy39ZxcLzY", // rayan wallet
-		// "TVR8XVzdFsAQQv8m22coTTFA715mrHZy95", // pannu wallet
-	} // private key wallet address same sequence
+	// Sender keys and their addresses, in the same order
+	privateKeys, addresses, err := envSenders()
+	if err != nil {
+		log.Fatalf("Failed to load senders: %v", err)
+	}
+	if keys, addrs, err := hdSenders(); err != nil {
+		log.Fatalf("Failed to derive HD senders: %v", err)
+	} else if keys != nil {
+		privateKeys, addresses = keys, addrs
+	}
+	if len(privateKeys) == 0 {
+		log.Fatalf("No senders: set TRON_PRIVATE_KEYS or TRON_MNEMONIC")
+	}
 
 	targets := []string{
 		// "TPoLuivbLuoqLRVY4iKgzJtavjYL4UneHx", // rayan SFP dev
@@ -78,6 +170,15 @@ func main() {
 	minAmount := 3.0                                     // min transaction amount
 	maxAmount := 10.0                                    // max transaction amount
 	maxGoroutines := 10                                  // concurrency
+	metricsAddr := os.Getenv("TRON_METRICS_ADDR")        // e.g. ":9101" to serve Prometheus /metrics; empty disables
+
+	srv, err := metrics.Serve(metricsAddr)
+	if err != nil {
+		log.Fatalf("Failed to start metrics listener: %v", err)
+	}
+	if srv != nil {
+		defer srv.Close()
+	}
 
 	semaphore := make(chan struct{}, maxGoroutines)
 	var wg sync.WaitGroup
@@ -89,6 +190,7 @@ func main() {
 			go func(j int, to string) {
 				defer wg.Done()
 				defer func() { <-semaphore }() // release slot
+				defer metrics.Started("tron")()
 
 				var fromAddr, pk string
 				// if j%2 == 0 {
@@ -96,7 +198,7 @@ func main() {
 				// } else {
 				// 	fromAddr, pk = addresses[0], privateKeys[0]
 				// }
-				fromAddr, pk = addresses[0], privateKeys[0]
+				fromAddr, pk = addresses[j%len(addresses)], privateKeys[j%len(privateKeys)]
 				random := rand.New(rand.NewSource(time.Now().UnixNano()))
 
 				// Random amount between min and max
@@ -113,9 +215,14 @@ func main() {
 					big.NewInt(int64(amount*1_000_000)),
 					big.NewInt(1),
 				)
+				start := time.Now()
 				txId, err := TransferTrc20(fromAddr, to, contractAddr, fromPrivateKey, amountBig)
 				if err != nil {
 					log.Printf("Error sending TRC20 TX from %s to %s: %v", fromAddr, to, err)
+					metrics.Failed("tron", metrics.ClassifyError(err.Error()))
+				} else {
+					metrics.Sent("tron", time.Since(start))
+					metrics.Succeeded("tron")
 				}
 				fmt.Printf("Count: %v, Amount %v, Sender: %v, Reciever: %v , TxId: %v\n", j, amount, fromAddr, to, txId)
 			}(j, to)